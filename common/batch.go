@@ -0,0 +1,164 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// 실패한 entry 재시도 횟수
+	max_retry_batch_request = 5
+	batch_retry_base_delay  = 100 * time.Millisecond
+	batch_retry_max_delay   = 5 * time.Second
+
+	// BATCH_FAILURE_CODE_REQUEST 는 요청 자체가 실패해서 entry 를 실패로 처리 했을 때의 code
+	BATCH_FAILURE_CODE_REQUEST = "RequestFailed"
+)
+
+// BatchFailure 는 batch 요청에서 실패한 entry 하나
+type BatchFailure struct {
+	Id          string
+	Code        string
+	Message     string
+	SenderFault bool  // true 면 요청이 잘못된 것이라 재시도 해도 실패
+	Err         error // 재시도를 다 하고도 실패했으면 ErrorRetryExhausted, 요청 자체가 실패했으면 그 오류
+}
+
+// BatchError 는 batch 요청에서 실패한 entry 들을 모아서 전달하기 위한 오류
+type BatchError struct {
+	Op     string
+	Failed []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	ids := make([]string, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		ids = append(ids, fmt.Sprintf("%s:%s", f.Id, f.Code))
+	}
+
+	return fmt.Sprintf("%s failed, entries : [%s]", e.Op, strings.Join(ids, ","))
+}
+
+// Unwrap 는 entry 별 오류를 전달, errors.Is(err, common.ErrorRetryExhausted) 로 재시도 초과 여부를 확인 할 수 있음
+func (e *BatchError) Unwrap() []error {
+	var errs []error
+	for _, f := range e.Failed {
+		if f.Err != nil {
+			errs = append(errs, f.Err)
+		}
+	}
+	return errs
+}
+
+// BatchSendFunc 는 entry 들을 batch 요청 한번으로 보내고, 응답에서 실패로 온 entry 들을 전달
+// 요청 자체가 실패하면 오류를 전달
+type BatchSendFunc[E any] func(c context.Context, entries []E) ([]BatchFailure, error)
+
+// SendBatch 는 send 로 entry 들을 보내고, 실패한 entry 만 backoff 를 주면서 다시 보냄
+// SenderFault 인 entry 는 다시 보내도 실패라서 바로 실패로 돌려 주고, 재시도를 다 하고도 실패한 entry 는 Err 가 ErrorRetryExhausted
+// 요청 자체가 실패한 경우는 retryable 이 true 인 오류만 다시 보냄
+func SendBatch[E any](c context.Context, entries []E, id func(E) string, send BatchSendFunc[E], retryable func(error) bool) []BatchFailure {
+	var failed []BatchFailure
+
+	for attempt := 0; ; attempt++ {
+		results, err := send(c, entries)
+		if err != nil {
+			if !retryable(err) || attempt >= max_retry_batch_request {
+				return append(failed, RequestFailures(entries, id, err)...)
+			}
+		} else {
+			retry := make(map[string]BatchFailure, len(results))
+			for _, f := range results {
+				if f.SenderFault {
+					failed = append(failed, f)
+					continue
+				}
+				retry[f.Id] = f
+			}
+
+			var next []E
+			for _, entry := range entries {
+				if _, ok := retry[id(entry)]; ok {
+					next = append(next, entry)
+				}
+			}
+			if len(next) == 0 {
+				return failed
+			}
+			if attempt >= max_retry_batch_request {
+				for _, entry := range next {
+					f := retry[id(entry)]
+					f.Err = ErrorRetryExhausted
+					failed = append(failed, f)
+				}
+				return failed
+			}
+			entries = next
+		}
+
+		if err := Sleep(c, Backoff(attempt, batch_retry_base_delay, batch_retry_max_delay)); err != nil {
+			return append(failed, RequestFailures(entries, id, err)...)
+		}
+	}
+}
+
+// RequestFailures 는 요청 자체가 실패 했을 때 모든 entry 를 같은 사유로 실패 처리
+func RequestFailures[E any](entries []E, id func(E) string, err error) []BatchFailure {
+	failed := make([]BatchFailure, 0, len(entries))
+	for _, entry := range entries {
+		failed = append(failed, BatchFailure{
+			Id:      id(entry),
+			Code:    BATCH_FAILURE_CODE_REQUEST,
+			Message: err.Error(),
+			Err:     err,
+		})
+	}
+	return failed
+}
+
+// ChunkBatch 는 entry 들을 순서대로 maxCount 개, size 합이 maxSize 이하가 되게 나눔
+func ChunkBatch[E any](entries []E, maxCount, maxSize int, size func(E) int) [][]E {
+	var chunks [][]E
+	var chunk []E
+	total := 0
+
+	for _, entry := range entries {
+		s := size(entry)
+		if len(chunk) > 0 && (len(chunk) == maxCount || total+s > maxSize) {
+			chunks = append(chunks, chunk)
+			chunk, total = nil, 0
+		}
+		chunk = append(chunk, entry)
+		total += s
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// IsRetryable 는 다시 보내면 성공할 수도 있는 오류인지 확인, codes 는 서비스 별로 재시도 할 api 오류 code
+// 서버 쪽 오류나 api 응답을 받지 못한 네트워크 오류는 재시도, 요청이 잘못된 오류나 context 가 끝난 경우는 재시도 안함
+func IsRetryable(err error, codes ...string) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		for _, code := range codes {
+			if apiErr.ErrorCode() == code {
+				return true
+			}
+		}
+		return apiErr.ErrorFault() == smithy.FaultServer
+	}
+
+	return true
+}
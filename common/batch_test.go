@@ -0,0 +1,62 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Test_ChunkBatch 개수, 크기 제한에 맞게 순서대로 나눠지는지 확인
+func Test_ChunkBatch(t *testing.T) {
+	entries := []int{3, 3, 3, 3, 8, 1}
+	chunks := ChunkBatch(entries, 3, 8, func(e int) int { return e })
+	if fmt.Sprint(chunks) != "[[3 3] [3 3] [8] [1]]" {
+		t.Fatalf("chunks mismatch, %v", chunks)
+	}
+
+	log.Debug().Interface("chunks", chunks).Msg("success")
+}
+
+// Test_SendBatch 실패한 entry 만 다시 보내고, sender fault 는 바로 실패, 끝까지 실패하면 재시도 초과로 알려 주는지 확인
+func Test_SendBatch(t *testing.T) {
+	sent := map[string]int{}
+	send := func(c context.Context, entries []string) ([]BatchFailure, error) {
+		var failed []BatchFailure
+		for _, e := range entries {
+			sent[e]++
+			switch {
+			case e == "invalid":
+				failed = append(failed, BatchFailure{Id: e, Code: "InvalidParameterValue", SenderFault: true})
+			case e == "flaky" && sent[e] < 3, e == "broken":
+				failed = append(failed, BatchFailure{Id: e, Code: "InternalError"})
+			}
+		}
+		return failed, nil
+	}
+
+	id := func(e string) string { return e }
+	failed := SendBatch(context.TODO(), []string{"ok", "flaky", "invalid", "broken"}, id, send, func(error) bool { return true })
+
+	result := map[string]BatchFailure{}
+	for _, f := range failed {
+		result[f.Id] = f
+	}
+	if len(failed) != 2 || !result["invalid"].SenderFault || !errors.Is(result["broken"].Err, ErrorRetryExhausted) {
+		t.Fatalf("failures mismatch, %v", failed)
+	}
+	if sent["ok"] != 1 || sent["flaky"] != 3 || sent["invalid"] != 1 || sent["broken"] != max_retry_batch_request+1 {
+		t.Fatalf("only retryable failures must be sent again, %v", sent)
+	}
+
+	failed = SendBatch(context.TODO(), []string{"a", "b"}, id, func(c context.Context, entries []string) ([]BatchFailure, error) {
+		return nil, errors.New("bad request")
+	}, func(error) bool { return false })
+	if len(failed) != 2 || failed[0].Code != BATCH_FAILURE_CODE_REQUEST {
+		t.Fatalf("request failure must fail all entries, %v", failed)
+	}
+
+	log.Debug().Interface("sent", sent).Msg("success")
+}
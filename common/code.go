@@ -7,4 +7,10 @@ import "errors"
 var (
 	ErrorNotFountItem           = errors.New("not found item")
 	ErrorRequestParameterExceed = errors.New("request parameter exceed")
+	ErrorConditionCheckFailed   = errors.New("condition check failed")
+	ErrorRetryExhausted         = errors.New("retry exhausted")
+	ErrorAlreadyInProgress      = errors.New("already in progress")
+	ErrorUnsupportedMessage     = errors.New("unsupported message")
+	ErrorNotFoundTopic          = errors.New("not found topic")
+	ErrorUnexpectedTopic        = errors.New("unexpected topic")
 )
@@ -1,10 +1,13 @@
 package common
 
 import (
+	"context"
+	"math/rand"
 	"os"
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -111,3 +114,86 @@ func IsDiffDate(t1, t2 int64) bool {
 
 	return false
 }
+
+// Backoff 는 재시도 횟수에 따라 exponential backoff 에 full jitter 를 적용한 대기 시간을 전달
+// attempt 는 0 부터 시작, base * 2^attempt 를 maxDelay 로 자른 값 안에서 랜덤으로 뽑음
+// 여러 요청이 동시에 재시도 하면서 다시 몰리는 것을 막기 위함
+func Backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	d := maxDelay
+	if attempt < 32 {
+		if exp := base << uint(attempt); exp > 0 && exp < maxDelay {
+			d = exp
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Sleep 는 d 만큼 대기 하는데, 중간에 context 가 끝나면 바로 빠져 나오면서 context 오류를 전달
+func Sleep(c context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-c.Done():
+		return c.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimiter 는 초당 rate 만큼 쓸 수 있는 token bucket
+// 요청 전에 Wait 로 여유가 생길 때 까지 기다리고, 요청 후에 실제로 사용한 양을 Consume 으로 빼는 방식
+// dynamo 의 ConsumedCapacity 처럼 요청을 해봐야 사용량을 알 수 있는 경우를 위함
+// 사용량이 남은 양보다 많으면 음수가 되고, 다시 양수가 될 때까지 Wait 에서 대기
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 는 초당 rate 만큼 쓸 수 있는 RateLimiter 를 생성, 처음에는 1초 분량을 가지고 시작
+func NewRateLimiter(rate float64) *RateLimiter {
+	return &RateLimiter{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// Wait 는 남은 양이 생길 때까지 대기, 중간에 context 가 끝나면 context 오류를 전달
+func (l *RateLimiter) Wait(c context.Context) error {
+	for {
+		l.mu.Lock()
+		l.refill()
+		if l.tokens > 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		d := time.Duration(-l.tokens/l.rate*float64(time.Second)) + time.Millisecond
+		l.mu.Unlock()
+
+		if err := Sleep(c, d); err != nil {
+			return err
+		}
+	}
+}
+
+// Consume 는 사용한 양 만큼 빼 줌
+func (l *RateLimiter) Consume(n float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	l.tokens -= n
+}
+
+// refill 는 지난 시간 만큼 채워 줌, 최대 1초 분량까지만 쌓임
+func (l *RateLimiter) refill() {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+}
@@ -1,8 +1,10 @@
 package common
 
 import (
+	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -32,3 +34,47 @@ func Test_SnakeCase(t *testing.T) {
 	log.Debug().Interface("lower", lowerCamel).Interface("camel", ToCamel(lowerCamel, true)).Msg("to camel case")
 	// return : AABBCC
 }
+
+// Test_Backoff 재시도 대기 시간이 max 를 넘지 않는지 확인
+func Test_Backoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	max := 100 * time.Millisecond
+
+	for attempt := 0; attempt < 100; attempt++ {
+		d := Backoff(attempt, base, max)
+		if d < 0 || d > max {
+			t.Fatalf("invalid backoff, attempt : %d, duration : %v", attempt, d)
+		}
+		if attempt == 0 && d > base {
+			t.Fatalf("first backoff must be less than base, duration : %v", d)
+		}
+	}
+
+	log.Debug().Msg("success")
+}
+
+// Test_RateLimiter 사용량이 초당 rate 를 넘으면 대기 하는지 확인
+func Test_RateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(100)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := limiter.Wait(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		limiter.Consume(100)
+	}
+	// 처음 1초 분량은 바로 쓰고, 다 쓴 뒤에 넘겨 쓴 만큼은 1초를 대기
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("rate limiter must wait, elapsed : %v", elapsed)
+	}
+
+	c, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if err := limiter.Wait(c); err == nil {
+		t.Fatal("canceled context must be failed")
+	}
+
+	log.Debug().Msg("success")
+}
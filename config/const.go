@@ -5,11 +5,38 @@ import (
 )
 
 const (
-	TABLE_LOG = "portfolio-log"
-	STAGE     = "STAGE"
+	TABLE_LOG         = "portfolio-log"
+	TABLE_IDEMPOTENCY = "portfolio-idempotency"
+	STAGE             = "STAGE"
+
+	// STAGE_LOCAL 은 aws 없이 local 에서 띄울 때 쓰는 stage
+	STAGE_LOCAL = "local"
 )
 
 // AccountTopicName account topic 이름을 전달
 func AccountTopicName() string {
 	return fmt.Sprintf("topic-%s-account", Config(STAGE))
 }
+
+// AccountFifoTopicName account fifo topic 이름을 전달
+// fifo queue 는 fifo topic 만 구독 할 수 있어서 stats fifo queue 는 이 topic 을 구독, topic 은 account stream stack 에서 생성
+// account stream 은 account topic 과 이 topic 에 같이 publish 하기 때문에 account topic 을 구독 하던 곳은 그대로 받음
+func AccountFifoTopicName() string {
+	return fmt.Sprintf("topic-%s-account.fifo", Config(STAGE))
+}
+
+// StatsQueueName stats queue 이름을 전달
+func StatsQueueName() string {
+	return fmt.Sprintf("portfolio-%s-stats.fifo", Config(STAGE))
+}
+
+// PayloadBucketName sqs, sns 로 보내기에 큰 payload 를 저장할 bucket 이름을 전달
+func PayloadBucketName() string {
+	return fmt.Sprintf("portfolio-%s-payload", Config(STAGE))
+}
+
+// IdempotencyTableName service 가 처리한 메시지를 기록하는 테이블 이름을 전달
+// 테이블은 service 마다 자기 stack 에서 expire 를 ttl 로 지정해서 만듦
+func IdempotencyTableName(service string) string {
+	return fmt.Sprintf("portfolio-%s-%s-idempotency", Config(STAGE), service)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
 	"github.com/google/uuid"
 )
@@ -13,7 +14,9 @@ const (
 	prefix_account_sk = "account#"
 )
 
-var ()
+var (
+	accountRepository = dynamo.NewRepository[Account](dynamo.NewDefault())
+)
 
 type Account struct {
 	// 클라에서 사용을 하기 위하여 json 파싱시 사용, pk 값이 변경이 되면 꼭 확인을 해야 함
@@ -38,6 +41,11 @@ func NewAccount() Account {
 	}
 }
 
+// Key 는 repository 에서 사용할 pk, sk 값
+func (a Account) Key() (string, string) {
+	return a.PK, a.SK
+}
+
 // SKForAccount account 의 sk 값
 func SKForAccount() string {
 	return prefix_account_sk
@@ -45,23 +53,47 @@ func SKForAccount() string {
 
 // Put item 데이터를 upsert
 func (a *Account) Put(c context.Context, item Account) error {
-	repo := dynamo.NewDefault()
-
-	return repo.PutItem(c, item)
+	_, err := accountRepository.Put(c, item)
+	return err
 }
 
 // Remove 유저 정보에 맞는 데이터 삭제
 func (a *Account) Remove(c context.Context, item Account) error {
-	repo := dynamo.NewDefault()
+	return accountRepository.Delete(c, item)
+}
+
+// UpdateLastLogin 는 last_login 만 update 하고 update 된 account 정보를 전달
+// 전체 데이터를 다시 쓰지 않기 위함, 없는 유저면 만들지 않고 common.ErrorConditionCheckFailed
+func (a Account) UpdateLastLogin(c context.Context, userId string, lastLogin int64) (Account, error) {
+	update := dynamo.NewUpdate().
+		Set("last_login", lastLogin).
+		Set("updated", time.Now().Unix()).
+		Condition(expression.AttributeExists(expression.Name("pk")))
 
-	return repo.DeleteItem(c, item.PK, item.SK)
+	return accountRepository.Update(c, userId, prefix_account_sk, update)
 }
 
 // Find pk, sk 를 이용해서 account 정보 조회
 func (a Account) Find(c context.Context, userId string) (Account, error) {
-	var r Account
-	repo := dynamo.NewDefault()
-	err := repo.MustFindOne(c, userId, prefix_account_sk, &r)
+	return accountRepository.Get(c, userId, prefix_account_sk)
+}
+
+// FindList 는 여러 유저의 account 정보를 한번에 조회, 없는 유저의 userId 들을 같이 전달
+func (a Account) FindList(c context.Context, userIds []string) ([]Account, []string, error) {
+	keys := make([]dynamo.Key, 0, len(userIds))
+	for _, userId := range userIds {
+		keys = append(keys, dynamo.Key{PK: userId, SK: prefix_account_sk})
+	}
+
+	accounts, missing, err := accountRepository.BatchGet(c, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missingIds := make([]string, 0, len(missing))
+	for _, k := range missing {
+		missingIds = append(missingIds, k.PK)
+	}
 
-	return r, err
+	return accounts, missingIds, nil
 }
@@ -2,16 +2,32 @@ package model
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 
+	"github.com/dalpengida/portfolio-go-aws/common"
 	"github.com/dalpengida/portfolio-go-aws/config"
+	"github.com/dalpengida/portfolio-go-aws/wrap/envelope"
 	"github.com/dalpengida/portfolio-go-aws/wrap/sns"
+	"github.com/dalpengida/portfolio-go-aws/wrap/sqs"
 )
 
-const ()
+const (
+	// MESSAGE_TYPE_ACCOUNT_NOTI 는 queue 로 보내는 AccountNoti 의 envelope type
+	MESSAGE_TYPE_ACCOUNT_NOTI    = "account_noti"
+	MESSAGE_VERSION_ACCOUNT_NOTI = 1
 
-var ()
+	// sns, sqs 로 보낼 때 붙이는 message attribute 이름
+	ATTRIBUTE_EVENT_TYPE = "event_type"
+	ATTRIBUTE_STAGE      = "stage"
+)
+
+var (
+	notiEncoder = envelope.NewEncoder().Compress(envelope.ENCODING_GZIP, 4*1024)
+)
 
 type AccountNoti struct {
 	UserId       string `json:"user_id"`
@@ -31,13 +47,173 @@ func NewAccountNoti() AccountNoti {
 }
 
 // Publish 는 AccountNoti 구조체의 데이터를 json 으로 marshaling 해서 sns publish 함
+// 구독하는 쪽에서 filter policy 로 거를 수 있도록 event_type, stage 를 message attribute 로 붙임
 func (a AccountNoti) Publish(c context.Context) error {
-	notiMessage, err := json.Marshal(a)
+	entry, err := a.topicEntry("")
 	if err != nil {
 		return fmt.Errorf("account noti publish failed, %w", err)
 	}
 
 	topic := sns.New(config.AccountTopicName())
 
-	return topic.Publish(c, string(notiMessage))
+	return topic.Publish(c, entry.Message, entry.Options...)
+}
+
+// topicEntry 는 account topic(standard)으로 보낼 sns.Entry 를 만들어 줌
+func (a AccountNoti) topicEntry(id string) (sns.Entry, error) {
+	notiMessage, err := json.Marshal(a)
+	if err != nil {
+		return sns.Entry{}, fmt.Errorf("account noti marshaling failed, %w", err)
+	}
+
+	return sns.Entry{
+		Id:      id,
+		Message: string(notiMessage),
+		Options: []sns.PublishOption{
+			sns.WithStringAttribute(ATTRIBUTE_EVENT_TYPE, a.EventType),
+			sns.WithStringAttribute(ATTRIBUTE_STAGE, config.Config(config.STAGE)),
+		},
+	}, nil
+}
+
+// PublishFifo 는 Publish 와 같은 메시지를 유저 별로 순서가 지켜지도록 account fifo topic 에 publish
+// deduplicationId 는 재전송 되어도 바뀌지 않는 값(stream 의 EventID 같은)을 사용, 비어 있으면 content 로 중복을 거름
+func (a AccountNoti) PublishFifo(c context.Context, deduplicationId string) error {
+	entry, err := a.Entry(deduplicationId)
+	if err != nil {
+		return err
+	}
+
+	topic := sns.New(config.AccountFifoTopicName())
+
+	return topic.Publish(c, entry.Message, entry.Options...)
+}
+
+// Entry 는 account fifo topic 에 PublishBatch 로 한번에 보낼 수 있게 AccountNoti 를 sns.Entry 로 만들어 줌
+// 유저 별로 순서가 지켜지도록 user_id 를 group id 로 사용
+func (a AccountNoti) Entry(deduplicationId string) (sns.Entry, error) {
+	notiMessage, err := json.Marshal(a)
+	if err != nil {
+		return sns.Entry{}, fmt.Errorf("account noti marshaling failed, %w", err)
+	}
+
+	dedup := sns.WithContentDeduplication()
+	if deduplicationId != "" {
+		dedup = sns.WithDeduplicationId(deduplicationId)
+	}
+
+	return sns.Entry{
+		Id:      deduplicationId,
+		Message: string(notiMessage),
+		Options: []sns.PublishOption{
+			sns.WithGroupId(a.UserId),
+			dedup,
+			sns.WithStringAttribute(ATTRIBUTE_EVENT_TYPE, a.EventType),
+			sns.WithStringAttribute(ATTRIBUTE_STAGE, config.Config(config.STAGE)),
+		},
+	}, nil
+}
+
+// AccountNotiEntry 는 PublishAccountNotis 로 보낼 noti 하나
+// Id 는 재전송 되어도 바뀌지 않는 값(stream 의 EventID 같은)을 사용, batch 의 entry Id 와 fifo topic 의 deduplication id 로 사용
+type AccountNotiEntry struct {
+	Id   string
+	Noti AccountNoti
+}
+
+// PublishAccountNotis 는 noti 들을 account topic 과 account fifo topic 에 한번에 publish
+// 기존 account topic 구독자는 그대로 받고, 순서가 필요한 구독자(stats fifo queue)는 fifo topic 에서 받음
+// 두 topic 중 하나라도 실패한 noti 는 Id 로 *sns.BatchError 에 담아서 전달
+// 다시 보내면 성공했던 topic 에도 다시 가기 때문에, 중복이 걸러지지 않는 account topic 구독자는 같은 noti 를 두번 받을 수 있음
+func PublishAccountNotis(c context.Context, notis []AccountNotiEntry) error {
+	entries := make([]sns.Entry, 0, len(notis))
+	fifoEntries := make([]sns.Entry, 0, len(notis))
+	for _, n := range notis {
+		entry, err := n.Noti.topicEntry(n.Id)
+		if err != nil {
+			return err
+		}
+		fifoEntry, err := n.Noti.Entry(n.Id)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		fifoEntries = append(fifoEntries, fifoEntry)
+	}
+
+	err := sns.New(config.AccountTopicName()).PublishBatch(c, entries)
+	fifoErr := sns.New(config.AccountFifoTopicName()).PublishBatch(c, fifoEntries)
+
+	return mergeFailures(notis, err, fifoErr)
+}
+
+// mergeFailures 는 topic 별 publish 결과를 noti Id 기준으로 합쳐서 *sns.BatchError 로 전달
+// 요청 자체가 실패한 topic 은 모든 noti 를 실패로 처리
+func mergeFailures(notis []AccountNotiEntry, errs ...error) error {
+	batchErr := &sns.BatchError{Op: "publish account noti"}
+	failed := make(map[string]struct{})
+	add := func(f sns.BatchFailure) {
+		if _, ok := failed[f.Id]; ok {
+			return
+		}
+		failed[f.Id] = struct{}{}
+		batchErr.Failed = append(batchErr.Failed, f)
+	}
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		var topicErr *sns.BatchError
+		if errors.As(err, &topicErr) {
+			for _, f := range topicErr.Failed {
+				add(f)
+			}
+			continue
+		}
+		for _, f := range common.RequestFailures(notis, func(n AccountNotiEntry) string { return n.Id }, err) {
+			add(f)
+		}
+	}
+	if len(batchErr.Failed) == 0 {
+		return nil
+	}
+
+	return batchErr
+}
+
+// Send 는 AccountNoti 를 envelope 로 감싸서 stats fifo queue 로 보냄
+// 유저 별로 순서가 지켜지도록 user_id 를 group id 로 사용
+// envelope 에는 보낼 때 마다 바뀌는 produced_at 이 있어서 content 로는 중복이 안 걸러지기 때문에, noti 값으로 만든 deduplication id 를 사용
+func (a AccountNoti) Send(c context.Context) error {
+	env, err := notiEncoder.Wrap(c, MESSAGE_TYPE_ACCOUNT_NOTI, MESSAGE_VERSION_ACCOUNT_NOTI, a)
+	if err != nil {
+		return fmt.Errorf("account noti send failed, %w", err)
+	}
+
+	deduplicationId, err := a.deduplicationId()
+	if err != nil {
+		return fmt.Errorf("account noti send failed, %w", err)
+	}
+
+	queue := sqs.New(config.StatsQueueName())
+
+	return queue.Send(c, env,
+		sqs.WithGroupId(a.UserId),
+		sqs.WithDeduplicationId(deduplicationId),
+		sqs.WithStringAttribute(ATTRIBUTE_EVENT_TYPE, a.EventType),
+	)
+}
+
+// deduplicationId 는 noti 값(json)의 sha256, 같은 noti 를 다시 보내면 같은 값이 나옴
+func (a AccountNoti) deduplicationId() (string, error) {
+	notiMessage, err := json.Marshal(a)
+	if err != nil {
+		return "", fmt.Errorf("account noti marshaling failed, %w", err)
+	}
+
+	sum := sha256.Sum256(notiMessage)
+
+	return hex.EncodeToString(sum[:]), nil
 }
@@ -2,6 +2,9 @@ package model
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 
 	"github.com/dalpengida/portfolio-go-aws/config"
 	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
@@ -11,16 +14,41 @@ const (
 	LOG_TYPE_RETENTION = "retention"
 )
 
-var ()
+var (
+	statsRepository = dynamo.NewRepository[Stats](dynamo.New(config.TABLE_LOG))
+)
 
+// Stats 는 portfolio-log 에 쌓는 로그
+// 이미 쌓여 있는 로그를 읽을 수 있도록 attribute 이름은 필드 이름 그대로 사용 (dynamodbav 태그를 붙이지 않음)
 type Stats struct {
 	TimeStamp int64  `json:"timestamp"`
 	UserId    string `json:"user_id"`
 	LogType   string `json:"log_type"`
 	Val       string `json:"val"`
+
+	// LogId 는 같은 초에 쌓인 같은 타입의 로그가 덮어 쓰이지 않도록 sk 에 붙이는 값, 비어 있으면 Put 할 때 로그 내용으로 만듦
+	LogId string `json:"log_id"`
+}
+
+// Key 는 repository 에서 사용할 pk, sk 값
+// 유저 별로 로그 타입과 시간 순서대로 조회 할 수 있도록 sk 를 log_type#timestamp#log_id 로 만듦
+func (s Stats) Key() (string, string) {
+	return s.UserId, fmt.Sprintf("%s#%d#%s", s.LogType, s.TimeStamp, s.LogId)
 }
 
+// Put 는 로그를 하나 쌓음
+// LogId 가 비어 있으면 로그 내용의 hash 를 사용해서, 같은 메시지가 다시 들어와서 한번 더 Put 해도 같은 item 을 덮어 씀
 func (s Stats) Put(c context.Context) error {
-	repo := dynamo.New(config.TABLE_LOG)
-	return repo.PutItem(c, s)
+	if s.LogId == "" {
+		s.LogId = s.hash()
+	}
+
+	_, err := statsRepository.Put(c, s)
+	return err
+}
+
+// hash 는 로그 내용으로 만든 id
+func (s Stats) hash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s#%s#%d#%s", s.UserId, s.LogType, s.TimeStamp, s.Val)))
+	return hex.EncodeToString(sum[:16])
 }
@@ -2,16 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/rs/zerolog/log"
 
+	"github.com/dalpengida/portfolio-go-aws/config"
 	"github.com/dalpengida/portfolio-go-aws/model"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
+	"github.com/dalpengida/portfolio-go-aws/wrap/idempotency"
+	"github.com/dalpengida/portfolio-go-aws/wrap/sns"
 )
 
+const (
+	prefix_idempotency_key = "stream#"
+
+	// template.yaml 의 function Timeout 과 맞춤, 처리하다 timeout 이 나도 재시도 때는 다시 잡을 수 있도록
+	idempotency_lock_timeout = 5 * time.Second
+)
+
+var (
+	// stream 은 실패하면 batch 전체를 다시 보내기 때문에 이미 publish 한 record 는 건너 뜀
+	store = idempotency.New(dynamo.New(config.IdempotencyTableName("stream"))).LockTimeout(idempotency_lock_timeout)
+)
+
+// handler 는 account record 들을 noti 로 만들어서 account topic, account fifo topic 에 한번에 publish
+// 실패한 record 만 idempotency key 를 풀어 주기 때문에 batch 가 다시 들어오면 실패한 record 만 다시 보냄
 func handler(ctx context.Context, event events.DynamoDBEvent) error {
+	var notis []model.AccountNotiEntry
 	for _, record := range event.Records {
 		sk := record.Change.Keys["sk"].String()
 
@@ -19,37 +39,105 @@ func handler(ctx context.Context, event events.DynamoDBEvent) error {
 			continue
 		}
 
-		// 유저 진입 알림 및 last login 계산을 위한 raw 데이터
-		switch record.EventName {
-		case "INSERT", "MODIFY":
-			preLastLogin, err := record.Change.OldImage["last_login"].Int64()
-			if err != nil {
-				return err
-			}
-			lastLogin, err := record.Change.NewImage["last_login"].Int64()
-			if err != nil {
-				return err
+		noti, ok, err := process(ctx, record)
+		if err != nil {
+			// batch 가 다시 들어올 때 앞에서 잡은 record 도 다시 보낼 수 있도록 key 를 풀어 줌
+			settle(ctx, notis, allFailed(notis))
+			return err
+		}
+		if ok {
+			notis = append(notis, noti)
+		}
+	}
+	if len(notis) == 0 {
+		return nil
+	}
+
+	err := model.PublishAccountNotis(ctx, notis)
+
+	failed := make(map[string]struct{})
+	var batchErr *sns.BatchError
+	if errors.As(err, &batchErr) {
+		for _, f := range batchErr.Failed {
+			failed[f.Id] = struct{}{}
+		}
+	} else if err != nil {
+		// 요청 자체가 실패한 경우는 모두 다시 보내야 함
+		failed = allFailed(notis)
+	}
+	settle(ctx, notis, failed)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().Interface("count", len(notis)).Msg("noti message publish success")
+
+	return nil
+}
+
+// settle 는 failed 에 있는 noti 의 idempotency key 는 풀어 주고, 나머지는 처리 완료로 기록
+func settle(ctx context.Context, notis []model.AccountNotiEntry, failed map[string]struct{}) {
+	c := context.WithoutCancel(ctx)
+	for _, noti := range notis {
+		key := prefix_idempotency_key + noti.Id
+		if _, ok := failed[noti.Id]; ok {
+			if err := store.Release(c, key); err != nil {
+				log.Error().Err(err).Interface("key", key).Msg("idempotency release failed")
 			}
+			continue
+		}
+		if err := store.Complete(c, key, nil); err != nil {
+			log.Error().Err(err).Interface("key", key).Msg("idempotency complete failed")
+		}
+	}
+}
 
-			notiMessage := model.AccountNoti{
+// allFailed 는 noti 전부를 실패로 표시
+func allFailed(notis []model.AccountNotiEntry) map[string]struct{} {
+	failed := make(map[string]struct{}, len(notis))
+	for _, noti := range notis {
+		failed[noti.Id] = struct{}{}
+	}
+
+	return failed
+}
+
+// process 는 account record 하나를 보고 보낼 noti 를 만듦
+// 이미 publish 한 record 거나 보낼 필요가 없는 record 면 ok 가 false
+func process(ctx context.Context, record events.DynamoDBEventRecord) (model.AccountNotiEntry, bool, error) {
+	// 유저 진입 알림 및 last login 계산을 위한 raw 데이터
+	switch record.EventName {
+	case "INSERT", "MODIFY":
+		preLastLogin, err := record.Change.OldImage["last_login"].Int64()
+		if err != nil {
+			return model.AccountNotiEntry{}, false, err
+		}
+		lastLogin, err := record.Change.NewImage["last_login"].Int64()
+		if err != nil {
+			return model.AccountNotiEntry{}, false, err
+		}
+
+		_, done, err := store.Claim(ctx, prefix_idempotency_key+record.EventID)
+		if err != nil || done {
+			return model.AccountNotiEntry{}, false, err
+		}
+
+		// EventID 는 재전송 되어도 바뀌지 않아서 sns 의 deduplication id 로도 사용
+		return model.AccountNotiEntry{
+			Id: record.EventID,
+			Noti: model.AccountNoti{
 				UserId:       record.Change.NewImage["user_id"].String(),
 				PreLastLogin: preLastLogin,
 				LastLogin:    lastLogin,
 				EventType:    record.EventName,
 				TimeStamp:    time.Now().Unix(),
-			}
-			err = notiMessage.Publish(context.TODO())
-			if err != nil {
-				return err
-			}
-
-			log.Debug().Interface("noti_message", notiMessage).Msg("noti message publish success")
+			},
+		}, true, nil
 
-		case "REMOVE":
-		}
+	case "REMOVE":
 	}
 
-	return nil
+	return model.AccountNotiEntry{}, false, nil
 }
 
 func init() {
@@ -3,45 +3,120 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/config"
 	"github.com/dalpengida/portfolio-go-aws/model"
+	"github.com/dalpengida/portfolio-go-aws/wrap/blob"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
+	"github.com/dalpengida/portfolio-go-aws/wrap/envelope"
+	"github.com/dalpengida/portfolio-go-aws/wrap/idempotency"
+	"github.com/dalpengida/portfolio-go-aws/wrap/sns"
+	"github.com/dalpengida/portfolio-go-aws/wrap/sqs"
+	"github.com/rs/zerolog/log"
 )
 
-func init() {
-	lambda.Start(handler)
-}
+const (
+	prefix_idempotency_key = "stats#"
+
+	// template.yaml 의 function Timeout 과 맞춤, 처리하다 timeout 이 나도 재시도 때는 다시 잡을 수 있도록
+	idempotency_lock_timeout = 5 * time.Second
+)
+
+var (
+	// 같은 메시지가 다시 들어와도 retention 로그가 두번 쌓이지 않도록 처리한 message id 를 기록
+	store = idempotency.New(dynamo.New(config.IdempotencyTableName("stats"))).LockTimeout(idempotency_lock_timeout)
+
+	// 256KB 가 넘어서 s3 로 offload 된 메시지를 가져오기 위함
+	// account topic 의 다른 구독자도 같은 payload 를 읽기 때문에 여기서는 지우지 않고 bucket 의 lifecycle 로 만료 시킴
+	payloads = blob.New(config.PayloadBucketName())
+
+	// account fifo topic 에서 온 sns 메시지를 풀어 줌, raw message delivery 나 queue 로 바로 보낸 메시지는 그대로 받음
+	// offload 된 메시지는 sns 메시지를 풀고 나서 payloads 에서 가져옴
+	decoder = sns.NewDecoder().Topic(config.AccountFifoTopicName()).AllowRaw().Blobs(payloads)
+)
+
+// handler 는 lambda 로 들어온 record 하나를 처리, 실패한 record 만 다시 들어옴
+var handler = idempotency.Wrap(store, func(record events.SQSMessage) string {
+	return prefix_idempotency_key + record.MessageId
+}, func(ctx context.Context, record events.SQSMessage) error {
+	return process(ctx, record.Body)
+})
+
+// consume 는 lambda 밖에서 queue 를 직접 polling 할 때 사용하는 handler
+var consume = idempotency.Wrap(store, func(m types.Message) string {
+	return prefix_idempotency_key + aws.ToString(m.MessageId)
+}, func(ctx context.Context, m types.Message) error {
+	return process(ctx, aws.ToString(m.Body))
+})
 
-func handler(ctx context.Context, sqsEvent events.SQSEvent) error {
-	for _, record := range sqsEvent.Records {
+// router 는 메시지 type, version 에 맞게 처리할 함수를 골라 줌
+// envelope 가 아닌 메시지는 sns 로 바로 받은 예전 포맷의 AccountNoti 로 처리
+var router = envelope.NewRouter().
+	Handle(model.MESSAGE_TYPE_ACCOUNT_NOTI, model.MESSAGE_VERSION_ACCOUNT_NOTI, func(ctx context.Context, env envelope.Envelope) error {
 		var noti model.AccountNoti
-		err := json.Unmarshal([]byte(record.Body), &noti)
-		if err != nil {
+		if err := env.Bind(&noti); err != nil {
 			return err
 		}
-		// 같은 날 들어온 데이터라고 하면 그냥 넘김
-		if !common.IsDiffDate(noti.PreLastLogin, noti.LastLogin) {
-			continue
-		}
-
-		// 날짜가 다르면 retention 로그를 일단 하나 남김
-		stats := model.Stats{
-			TimeStamp: time.Now().Unix(),
-			UserId:    noti.UserId,
-			LogType:   model.LOG_TYPE_RETENTION,
-			Val:       record.Body,
-		}
-		err = stats.Put(context.TODO())
-		if err != nil {
+		return processAccountNoti(ctx, noti)
+	}).
+	Raw(func(ctx context.Context, body []byte) error {
+		var noti model.AccountNoti
+		if err := json.Unmarshal(body, &noti); err != nil {
 			return err
 		}
+		return processAccountNoti(ctx, noti)
+	})
+
+// process 는 메시지 하나를 처리
+func process(ctx context.Context, body string) error {
+	return router.Route(ctx, []byte(body))
+}
+
+// processAccountNoti 는 account noti 하나를 보고 retention 로그를 남김
+func processAccountNoti(ctx context.Context, noti model.AccountNoti) error {
+	// 같은 날 들어온 데이터라고 하면 그냥 넘김
+	if !common.IsDiffDate(noti.PreLastLogin, noti.LastLogin) {
+		return nil
+	}
+
+	val, err := json.Marshal(noti)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	// 날짜가 다르면 retention 로그를 일단 하나 남김
+	// 같은 noti 가 다시 들어와도 같은 로그가 되도록 받은 시간이 아니라 noti 의 로그인 시간을 사용
+	stats := model.Stats{
+		TimeStamp: noti.LastLogin,
+		UserId:    noti.UserId,
+		LogType:   model.LOG_TYPE_RETENTION,
+		Val:       string(val),
+	}
+
+	return stats.Put(ctx)
 }
 
 func main() {
+	if common.IsAWSLambda() {
+		lambda.Start(sqs.BatchHandler(decoder.RecordHandler(handler)))
+		return
+	}
+
+	// lambda 밖에서는 consumer 로 직접 queue 를 읽음, 종료 신호가 오면 처리 중인 메시지까지 마무리 하고 끝냄
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := sqs.NewConsumer(sqs.New(config.StatsQueueName()), decoder.MessageHandler(consume)).Run(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("stats consumer failed")
+	}
 }
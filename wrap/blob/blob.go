@@ -0,0 +1,85 @@
+package blob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// POINTER_ATTRIBUTE 는 offload 한 메시지에 붙이는 message attribute 이름, 원래 payload 의 크기를 값으로 가짐
+	POINTER_ATTRIBUTE = "ExtendedPayloadSize"
+
+	pointer_prefix = `{"blob_pointer":`
+)
+
+// Store 는 큰 payload 를 대신 저장해 두는 저장소
+// 운영에서는 S3Store, 테스트나 local 에서는 FileStore 를 사용
+type Store interface {
+	Put(c context.Context, key string, data []byte) error
+	// Get 는 key 가 없으면 common.ErrorNotFountItem 을 전달
+	Get(c context.Context, key string) ([]byte, error)
+	// Delete 는 key 가 없어도 오류가 아님
+	Delete(c context.Context, key string) error
+}
+
+// Pointer 는 payload 대신 메시지 body 로 보내는 값
+type Pointer struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+}
+
+type pointerBody struct {
+	Pointer Pointer `json:"blob_pointer"`
+}
+
+// Offload 는 payload 를 store 에 저장하고 메시지 body 로 보낼 pointer 를 전달
+func Offload(c context.Context, store Store, payload []byte) (string, error) {
+	p := Pointer{Key: uuid.NewString(), Size: len(payload)}
+	if err := store.Put(c, p.Key, payload); err != nil {
+		return "", fmt.Errorf("blob offload failed, %w", err)
+	}
+
+	body, err := json.Marshal(pointerBody{Pointer: p})
+	if err != nil {
+		return "", fmt.Errorf("blob pointer json marshaling failed, %w", err)
+	}
+
+	return string(body), nil
+}
+
+// ParsePointer 는 메시지 body 가 Offload 로 만든 pointer 면 pointer 를 전달
+func ParsePointer(body string) (Pointer, bool) {
+	if !strings.HasPrefix(body, pointer_prefix) {
+		return Pointer{}, false
+	}
+
+	var p pointerBody
+	if err := json.Unmarshal([]byte(body), &p); err != nil || p.Pointer.Key == "" {
+		return Pointer{}, false
+	}
+
+	return p.Pointer, true
+}
+
+// Resolve 는 body 가 pointer 면 store 에서 원래 payload 를 가져와서 전달, pointer 가 아니면 body 그대로 전달
+// 가져온 payload 는 처리가 끝난 뒤에 Delete 로 지워야 해서 pointer 도 같이 전달
+func Resolve(c context.Context, store Store, body string) (string, *Pointer, error) {
+	p, ok := ParsePointer(body)
+	if !ok {
+		return body, nil, nil
+	}
+	if store == nil {
+		return "", nil, fmt.Errorf("blob resolve failed, store is nil, key : %s", p.Key)
+	}
+
+	payload, err := store.Get(c, p.Key)
+	if err != nil {
+		return "", nil, fmt.Errorf("blob resolve failed, key : %s, %w", p.Key, err)
+	}
+
+	return string(payload), &p, nil
+}
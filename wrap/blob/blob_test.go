@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	test_success_msg_format = "[%s] success"
+)
+
+// Test_OffloadAndResolve 는 payload 를 저장하고 pointer 로 다시 가져오는 기능 검사
+func Test_OffloadAndResolve(t *testing.T) {
+	c := context.TODO()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := strings.Repeat("a", 300*1024)
+	body, err := Offload(c, store, []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) > 1024 {
+		t.Fatalf("pointer must be small, size : %d", len(body))
+	}
+
+	resolved, pointer, err := Resolve(c, store, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != payload || pointer == nil || pointer.Size != len(payload) {
+		t.Fatalf("resolved payload mismatch, pointer : %v", pointer)
+	}
+
+	// pointer 가 아닌 body 는 그대로
+	plain, pointer, err := Resolve(c, store, `{"user_id":"1"}`)
+	if err != nil || pointer != nil || plain != `{"user_id":"1"}` {
+		t.Fatalf("plain body must be returned as is, %s, %v", plain, err)
+	}
+
+	if err := store.Delete(c, pointerKey(t, body)); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = Resolve(c, store, body)
+	if !errors.Is(err, common.ErrorNotFountItem) {
+		t.Fatalf("deleted payload must be not found, %v", err)
+	}
+	if err := store.Put(c, "../escape", nil); err == nil {
+		t.Fatal("key out of dir must fail")
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+func pointerKey(t *testing.T, body string) string {
+	p, ok := ParsePointer(body)
+	if !ok {
+		t.Fatalf("body must be pointer, %s", body)
+	}
+	return p.Key
+}
@@ -0,0 +1,51 @@
+package blob
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/dalpengida/portfolio-go-aws/config"
+)
+
+// Client 는 S3Store 에서 사용하는 s3 기능들
+// *s3.Client 가 그대로 구현하고 있고, 테스트에서는 fake 를 넣어서 사용할 수 있음
+type Client interface {
+	PutObject(c context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(c context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(c context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+var (
+	defaultClient   Client
+	defaultClientMu sync.Mutex
+)
+
+// SetDefaultClient 는 client 를 따로 지정하지 않은 S3Store 들이 사용할 client 를 변경
+func SetDefaultClient(client Client) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	defaultClient = client
+}
+
+// getDefaultClient 는 default client 를 전달, 처음 호출 될 때 config.GetAws() 로 생성
+func getDefaultClient() Client {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	if defaultClient == nil {
+		defaultClient = s3.NewFromConfig(config.GetAws())
+	}
+
+	return defaultClient
+}
+
+// api 는 S3Store 가 사용할 client 를 전달, 지정한 client 가 없으면 default client
+func (s S3Store) api() Client {
+	if s.client != nil {
+		return s.client
+	}
+
+	return getDefaultClient()
+}
@@ -0,0 +1,79 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dalpengida/portfolio-go-aws/common"
+)
+
+// FileStore 는 local 디렉토리에 payload 를 저장, 테스트나 local 에서 s3 대신 사용
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 는 dir 아래에 저장하는 FileStore 를 생성, dir 이 없으면 만듦
+func NewFileStore(dir string) (FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return FileStore{}, fmt.Errorf("make blob dir failed, %w", err)
+	}
+
+	return FileStore{dir: dir}, nil
+}
+
+// path 는 key 로 파일 경로를 만듦, dir 밖으로 나가는 key 는 오류
+func (s FileStore) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return "", fmt.Errorf("invalid blob key, %s", key)
+	}
+
+	return filepath.Join(s.dir, key), nil
+}
+
+func (s FileStore) Put(c context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write blob failed, key : %s, %w", key, err)
+	}
+
+	return nil
+}
+
+func (s FileStore) Get(c context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read blob failed, key : %s, %w", key, common.ErrorNotFountItem)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read blob failed, key : %s, %w", key, err)
+	}
+
+	return data, nil
+}
+
+func (s FileStore) Delete(c context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete blob failed, key : %s, %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// S3Store 는 s3 bucket 에 payload 를 저장
+// 지우지 못하고 남은 object 가 생길 수 있으니 bucket 에 lifecycle 으로 만료를 걸어 두는 게 좋음
+type S3Store struct {
+	bucket string
+
+	// client 는 nil 이면 default client 를 사용
+	client Client
+}
+
+func New(bucket string) S3Store {
+	return S3Store{bucket: bucket}
+}
+
+// NewWithClient 는 외부에서 만든 client 를 사용하는 S3Store 를 생성
+func NewWithClient(client Client, bucket string) S3Store {
+	return S3Store{bucket: bucket, client: client}
+}
+
+// NewFromConfig 는 aws config 로 client 를 만들어서 S3Store 를 생성
+func NewFromConfig(cfg aws.Config, bucket string) S3Store {
+	return NewWithClient(s3.NewFromConfig(cfg), bucket)
+}
+
+func (s S3Store) Put(c context.Context, key string, data []byte) error {
+	_, err := s.api().PutObject(c, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("put object failed, bucket : %s, key : %s, %w", s.bucket, key, err)
+	}
+
+	log.Debug().Interface("bucket", s.bucket).Interface("key", key).Msg("put object success")
+
+	return nil
+}
+
+func (s S3Store) Get(c context.Context, key string) ([]byte, error) {
+	r, err := s.api().GetObject(c, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("get object failed, key : %s, %w", key, common.ErrorNotFountItem)
+		}
+		return nil, fmt.Errorf("get object failed, bucket : %s, key : %s, %w", s.bucket, key, err)
+	}
+	defer r.Body.Close()
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read object failed, bucket : %s, key : %s, %w", s.bucket, key, err)
+	}
+
+	return data, nil
+}
+
+func (s S3Store) Delete(c context.Context, key string) error {
+	_, err := s.api().DeleteObject(c, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("delete object failed, bucket : %s, key : %s, %w", s.bucket, key, err)
+	}
+
+	log.Debug().Interface("bucket", s.bucket).Interface("key", key).Msg("delete object success")
+
+	return nil
+}
@@ -0,0 +1,417 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// BatchGetItem 은 한번에 최대 100개의 key 까지만 조회 가능
+	max_count_batch_get_item = 100
+
+	// 동시에 날릴 batch 요청 수, 너무 많이 날리면 throttling 이 더 심해짐
+	max_concurrency_batch_request = 4
+	// UnprocessedItems 재시도 횟수
+	max_retry_batch_request = 8
+
+	batch_retry_base_delay = 50 * time.Millisecond
+	batch_retry_max_delay  = 5 * time.Second
+)
+
+// Key 는 pk, sk 로 item 하나를 가리키기 위한 정보
+type Key struct {
+	PK string
+	SK string
+}
+
+// BatchWriteFailure 는 batch write 에서 최종적으로 실패한 item 정보
+type BatchWriteFailure struct {
+	Index int                             // 요청한 items 에서의 위치
+	Item  map[string]types.AttributeValue // 실패한 item, 필요하면 attributevalue.UnmarshalMap 으로 다시 바인딩
+	Err   error
+}
+
+// BatchWriteError 는 재시도를 하고도 실패한 item 들을 모아서 전달하기 위한 오류
+// errors.As 로 꺼내서 Failed 를 보고 다시 넣던지 로그를 남기던지 하면 됨
+type BatchWriteError struct {
+	Failed []BatchWriteFailure
+}
+
+func (e *BatchWriteError) Error() string {
+	indexes := make([]string, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		indexes = append(indexes, fmt.Sprintf("%d", f.Index))
+	}
+
+	return fmt.Sprintf("batch write failed, %d items, index : [%s], first err : %v", len(e.Failed), strings.Join(indexes, ","), e.Failed[0].Err)
+}
+
+// Unwrap 는 실패한 item 들의 오류를 전달, errors.Is 로 원인 확인 하기 위함
+func (e *BatchWriteError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		errs = append(errs, f.Err)
+	}
+	return errs
+}
+
+// putItemsWithBatch 는 marshaling 된 item 들을 25개씩 나눠서 동시에 BatchWriteItem 으로 넣어 줌
+// BatchWriteItem 은 조건을 걸 수 없어서 version 을 사용하는 테이블은 optimistic locking 을 우회하지 않도록 오류
+func (t TableBasics) putItemsWithBatch(c context.Context, items []map[string]types.AttributeValue) error {
+	if t.versionAttribute != "" {
+		return fmt.Errorf("invalid batch write, table %s uses version, use PutItem or NewTransaction", t.tableName)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []BatchWriteFailure
+	)
+
+	sem := make(chan struct{}, max_concurrency_batch_request)
+	for start := 0; start < len(items); start += max_count_bulk_item {
+		end := start + max_count_bulk_item
+		if end > len(items) {
+			end = len(items)
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			f := t.writeChunk(c, items[start:end], start)
+			if len(f) > 0 {
+				mu.Lock()
+				failed = append(failed, f...)
+				mu.Unlock()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Slice(failed, func(i, j int) bool { return failed[i].Index < failed[j].Index })
+		log.Error().Interface("request_items_count", len(items)).Interface("failed_count", len(failed)).Msg("batch write item failed")
+		return &BatchWriteError{Failed: failed}
+	}
+
+	log.Debug().Interface("request_items_count", len(items)).Msg("batch write item success")
+
+	return nil
+}
+
+// writeChunk 는 25개 이하의 item 을 BatchWriteItem 으로 넣고, UnprocessedItems 가 없어질 때까지 재시도
+// offset 은 전체 items 에서 chunk 의 시작 위치, 실패한 item 의 index 를 알려 주기 위함
+func (t TableBasics) writeChunk(c context.Context, items []map[string]types.AttributeValue, offset int) []BatchWriteFailure {
+	positions := newWritePositions(items, offset)
+	reqs := make([]types.WriteRequest, 0, len(items))
+	for _, item := range items {
+		reqs = append(reqs, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		r, err := t.api().BatchWriteItem(c, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{t.tableName: reqs}})
+		if err != nil {
+			// 요청 자체가 잘 못 된 경우는 재시도 해도 똑같기 때문에 바로 실패 처리
+			if !isRetryable(err) {
+				return writeFailures(reqs, positions, fmt.Errorf("batch write item failed, %w", err))
+			}
+			lastErr = err
+		} else {
+			reqs = r.UnprocessedItems[t.tableName]
+			if len(reqs) == 0 {
+				return nil
+			}
+			lastErr = common.ErrorRetryExhausted
+		}
+
+		if attempt >= max_retry_batch_request {
+			return writeFailures(reqs, positions, fmt.Errorf("batch write item unprocessed, %w", lastErr))
+		}
+
+		log.Debug().Interface("attempt", attempt).Interface("unprocessed_count", len(reqs)).Msg("batch write item retry")
+
+		err = common.Sleep(c, common.Backoff(attempt, batch_retry_base_delay, batch_retry_max_delay))
+		if err != nil {
+			return writeFailures(reqs, positions, err)
+		}
+	}
+}
+
+// writePositions 는 chunk 의 item 이 전체 items 에서 몇번째인지 찾기 위한 정보
+// 응답으로 오는 UnprocessedItems 는 item 만 새로 만들어서 주기 때문에 item 내용 전체로 원래 위치를 찾음
+// pk, sk 만 보면 key 가 없거나 S 가 아닌 item 끼리 겹쳐서 엉뚱한 위치를 알려 줄 수 있음
+type writePositions map[string][]int
+
+func newWritePositions(items []map[string]types.AttributeValue, offset int) writePositions {
+	p := make(writePositions, len(items))
+	for i, item := range items {
+		fp := itemFingerprint(item)
+		p[fp] = append(p[fp], offset+i)
+	}
+	return p
+}
+
+// take 는 item 의 위치를 전달, 내용이 같은 item 이 여러개면 앞에서부터 하나씩 전달, 못 찾으면 -1
+func (p writePositions) take(item map[string]types.AttributeValue) int {
+	fp := itemFingerprint(item)
+	indexes := p[fp]
+	if len(indexes) == 0 {
+		return -1
+	}
+	p[fp] = indexes[1:]
+	return indexes[0]
+}
+
+// writeFailures 는 남은 request 들을 실패 정보로 변환
+func writeFailures(reqs []types.WriteRequest, positions writePositions, err error) []BatchWriteFailure {
+	failed := make([]BatchWriteFailure, 0, len(reqs))
+	for _, req := range reqs {
+		if req.PutRequest == nil {
+			continue
+		}
+		failed = append(failed, BatchWriteFailure{
+			Index: positions.take(req.PutRequest.Item),
+			Item:  req.PutRequest.Item,
+			Err:   err,
+		})
+	}
+	return failed
+}
+
+// itemFingerprint 는 item 의 attribute 를 이름 순서대로 타입과 값까지 넣어서 문자열로 만들어 줌
+func itemFingerprint(item map[string]types.AttributeValue) string {
+	var b strings.Builder
+	writeFingerprint(&b, &types.AttributeValueMemberM{Value: item})
+	return b.String()
+}
+
+func writeFingerprint(b *strings.Builder, av types.AttributeValue) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		fmt.Fprintf(b, "S%q", v.Value)
+	case *types.AttributeValueMemberN:
+		fmt.Fprintf(b, "N%q", v.Value)
+	case *types.AttributeValueMemberB:
+		fmt.Fprintf(b, "B%q", v.Value)
+	case *types.AttributeValueMemberBOOL:
+		fmt.Fprintf(b, "BOOL%t", v.Value)
+	case *types.AttributeValueMemberNULL:
+		b.WriteString("NULL")
+	case *types.AttributeValueMemberSS:
+		fmt.Fprintf(b, "SS%q", v.Value)
+	case *types.AttributeValueMemberNS:
+		fmt.Fprintf(b, "NS%q", v.Value)
+	case *types.AttributeValueMemberBS:
+		fmt.Fprintf(b, "BS%q", v.Value)
+	case *types.AttributeValueMemberL:
+		b.WriteString("L[")
+		for _, e := range v.Value {
+			writeFingerprint(b, e)
+			b.WriteString(",")
+		}
+		b.WriteString("]")
+	case *types.AttributeValueMemberM:
+		names := make([]string, 0, len(v.Value))
+		for name := range v.Value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		b.WriteString("M{")
+		for _, name := range names {
+			fmt.Fprintf(b, "%q:", name)
+			writeFingerprint(b, v.Value[name])
+			b.WriteString(",")
+		}
+		b.WriteString("}")
+	default:
+		fmt.Fprintf(b, "%T", v)
+	}
+}
+
+// itemKey 는 item 의 pk, sk 를 꺼내서 map 의 key 로 쓸 수 있게 만들어 줌
+func itemKey(item map[string]types.AttributeValue) Key {
+	var k Key
+	if v, ok := item["pk"].(*types.AttributeValueMemberS); ok {
+		k.PK = v.Value
+	}
+	if v, ok := item["sk"].(*types.AttributeValueMemberS); ok {
+		k.SK = v.Value
+	}
+	return k
+}
+
+// isRetryable 는 잠시 후에 다시 요청하면 성공할 수도 있는 오류인지 확인
+// sdk 내부에서도 재시도를 하지만, 그걸로도 안되는 경우 한번 더 재시도 하기 위함
+func isRetryable(err error) bool {
+	var (
+		throughputEx *types.ProvisionedThroughputExceededException
+		limitEx      *types.RequestLimitExceeded
+		internalEx   *types.InternalServerError
+	)
+
+	return errors.As(err, &throughputEx) || errors.As(err, &limitEx) || errors.As(err, &internalEx)
+}
+
+// BatchGet 는 keys 에 해당 하는 item 들을 한번에 조회해서 objSlice 에 바인딩 하고, 없는 key 들을 전달
+// MustFindOne 을 key 수만큼 호출 하던 것을 줄이기 위함, key 수 제한 없이 내부에서 100개씩 나눠서 조회
+// projection 으로 attribute 이름을 넘기면 해당 attribute 만 가져옴, 없는 key 를 찾기 위해 pk, sk 는 항상 포함
+// 결과는 keys 에 넘긴 순서대로 바인딩 되고, 같은 key 가 여러번 들어 오면 한번만 조회
+func (t TableBasics) BatchGet(c context.Context, keys []Key, objSlice interface{}, projection ...string) ([]Key, error) {
+	items, missing, err := t.batchGet(c, keys, projection)
+	if err != nil {
+		return nil, err
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(items, objSlice)
+	if err != nil {
+		return nil, fmt.Errorf("attributevalue unmarshallistofmaps failed, err : %w", err)
+	}
+
+	return missing, nil
+}
+
+// batchGet 는 key 들을 100개씩 나눠서 동시에 조회하고, keys 순서대로 정렬된 item 들과 없는 key 들을 전달
+func (t TableBasics) batchGet(c context.Context, keys []Key, projection []string) ([]map[string]types.AttributeValue, []Key, error) {
+	// 같은 key 가 한 요청에 있으면 dynamo 에서 오류를 내기 때문에 중복 제거
+	uniq := make([]Key, 0, len(keys))
+	seen := make(map[Key]bool, len(keys))
+	for _, k := range keys {
+		if k.SK == "" {
+			k.SK = "#"
+		}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		uniq = append(uniq, k)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	found := make(map[Key]map[string]types.AttributeValue, len(uniq))
+
+	sem := make(chan struct{}, max_concurrency_batch_request)
+	for start := 0; start < len(uniq); start += max_count_batch_get_item {
+		end := start + max_count_batch_get_item
+		if end > len(uniq) {
+			end = len(uniq)
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk []Key) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			items, err := t.getChunk(c, chunk, projection)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for _, item := range items {
+				found[itemKey(item)] = item
+			}
+		}(uniq[start:end])
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+
+	items := make([]map[string]types.AttributeValue, 0, len(found))
+	var missing []Key
+	for _, k := range uniq {
+		item, ok := found[k]
+		if !ok {
+			missing = append(missing, k)
+			continue
+		}
+		items = append(items, item)
+	}
+
+	log.Debug().Interface("request_keys_count", len(uniq)).Interface("missing_count", len(missing)).Msg("batch get item success")
+
+	return items, missing, nil
+}
+
+// getChunk 는 100개 이하의 key 를 BatchGetItem 으로 조회하고, UnprocessedKeys 가 없어질 때까지 재시도
+func (t TableBasics) getChunk(c context.Context, keys []Key, projection []string) ([]map[string]types.AttributeValue, error) {
+	request := types.KeysAndAttributes{}
+	for _, k := range keys {
+		request.Keys = append(request.Keys, map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: k.PK},
+			"sk": &types.AttributeValueMemberS{Value: k.SK},
+		})
+	}
+
+	if len(projection) > 0 {
+		proj := expression.NamesList(expression.Name("pk"), expression.Name("sk"))
+		for _, name := range projection {
+			proj = proj.AddNames(expression.Name(name))
+		}
+		expr, err := expression.NewBuilder().WithProjection(proj).Build()
+		if err != nil {
+			return nil, fmt.Errorf("projection expression build failed, %w", err)
+		}
+		request.ProjectionExpression = expr.Projection()
+		request.ExpressionAttributeNames = expr.Names()
+	}
+
+	var items []map[string]types.AttributeValue
+	for attempt := 0; ; attempt++ {
+		r, err := t.api().BatchGetItem(c, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{t.tableName: request},
+		})
+		if err != nil && !isRetryable(err) {
+			return nil, fmt.Errorf("batch get item failed, %w", err)
+		}
+		if err == nil {
+			items = append(items, r.Responses[t.tableName]...)
+
+			unprocessed, ok := r.UnprocessedKeys[t.tableName]
+			if !ok || len(unprocessed.Keys) == 0 {
+				return items, nil
+			}
+			request = unprocessed
+			err = common.ErrorRetryExhausted
+		}
+
+		if attempt >= max_retry_batch_request {
+			return nil, fmt.Errorf("batch get item unprocessed, keys : %d, %w", len(request.Keys), err)
+		}
+
+		log.Debug().Interface("attempt", attempt).Interface("unprocessed_count", len(request.Keys)).Msg("batch get item retry")
+
+		err = common.Sleep(c, common.Backoff(attempt, batch_retry_base_delay, batch_retry_max_delay))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
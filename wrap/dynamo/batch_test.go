@@ -0,0 +1,50 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_WriteFailures 는 처리 못한 request 가 원래 요청한 위치로 찾아 지는지 검사
+// 응답에서 오는 item 은 새로 만들어진 map 이라서 복사해서 넘기고, key 가 없거나 S 가 아닌 item 도 섞음
+func Test_WriteFailures(t *testing.T) {
+	items := make([]map[string]types.AttributeValue, 0)
+	for i := 0; i < 5; i++ {
+		items = append(items, map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: "pk"},
+			"sk": &types.AttributeValueMemberS{Value: fmt.Sprintf("bulksk#%d", i)},
+		})
+	}
+	items = append(items,
+		map[string]types.AttributeValue{"val": &types.AttributeValueMemberS{Value: "a"}},
+		map[string]types.AttributeValue{"val": &types.AttributeValueMemberS{Value: "b"}},
+		map[string]types.AttributeValue{"pk": &types.AttributeValueMemberN{Value: "1"}},
+		map[string]types.AttributeValue{"pk": &types.AttributeValueMemberS{Value: "1"}},
+	)
+	positions := newWritePositions(items, 25)
+
+	var reqs []types.WriteRequest
+	for _, i := range []int{3, 1, 6, 8, 7} {
+		item := make(map[string]types.AttributeValue, len(items[i]))
+		for k, v := range items[i] {
+			item[k] = v
+		}
+		reqs = append(reqs, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+	failed := writeFailures(reqs, positions, common.ErrorRetryExhausted)
+	if len(failed) != 5 || failed[0].Index != 28 || failed[1].Index != 26 || failed[2].Index != 31 || failed[3].Index != 33 || failed[4].Index != 32 {
+		t.Fatalf("invalid failed index, %v", failed)
+	}
+
+	err := error(&BatchWriteError{Failed: failed})
+	if !errors.Is(err, common.ErrorRetryExhausted) {
+		t.Fatalf("batch write error must unwrap cause, %v", err)
+	}
+
+	log.Debug().Err(err).Msgf(test_success_msg_format, common.FunctionName())
+}
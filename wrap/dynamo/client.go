@@ -0,0 +1,63 @@
+package dynamo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/dalpengida/portfolio-go-aws/config"
+)
+
+// Client 는 TableBasics 에서 사용하는 dynamodb 기능들
+// *dynamodb.Client 가 그대로 구현하고 있고, 테스트에서는 fake 를 넣어서 사용할 수 있음
+type Client interface {
+	CreateTable(c context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTable(c context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	ListTables(c context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error)
+	PutItem(c context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(c context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	UpdateItem(c context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(c context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(c context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(c context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	BatchWriteItem(c context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItem(c context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	TransactWriteItems(c context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+var (
+	defaultClient   Client
+	defaultClientMu sync.Mutex
+)
+
+// SetDefaultClient 는 client 를 따로 지정하지 않은 TableBasics 들이 사용할 client 를 변경
+// New, NewDefault 로 만든 TableBasics 는 호출 할 때마다 default client 를 가져가기 때문에
+// model 처럼 패키지 변수로 만들어 둔 곳도 테스트에서 fake 로 바꿀 수 있음
+func SetDefaultClient(client Client) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	defaultClient = client
+}
+
+// getDefaultClient 는 default client 를 전달, 처음 호출 될 때 config.GetAws() 로 생성
+// import 할 때 init 에서 만들지 않기 위함
+func getDefaultClient() Client {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	if defaultClient == nil {
+		defaultClient = dynamodb.NewFromConfig(config.GetAws())
+	}
+
+	return defaultClient
+}
+
+// api 는 TableBasics 가 사용할 client 를 전달, 지정한 client 가 없으면 default client
+func (t TableBasics) api() Client {
+	if t.client != nil {
+		return t.client
+	}
+
+	return getDefaultClient()
+}
@@ -0,0 +1,110 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+)
+
+// WithVersion 는 optimistic locking 을 사용하는 TableBasics 를 전달
+// item 에 attribute 로 들어 있는 version 값이 테이블에 저장된 값과 같을 때만 쓰기가 되고, 쓸 때마다 1씩 올라감
+// version 이 0 이거나 없으면 새로 만드는 걸로 보고 같은 key 가 없을 때만 넣음
+// batch write 는 조건을 걸 수 없어서 PutItemsWithBatch, Repository.BatchPut 은 오류를 전달
+func (t TableBasics) WithVersion(attributeName string) TableBasics {
+	t.versionAttribute = attributeName
+	return t
+}
+
+// VersionCondition 는 version 이 expected 와 같은지 확인하는 조건을 만들어 줌
+// 다른 조건과 and 로 묶어서 직접 걸고 싶을 때 사용, WithVersion 으로 version attribute 를 지정하지 않았으면 오류
+func (t TableBasics) VersionCondition(expected int64) (expression.ConditionBuilder, error) {
+	if t.versionAttribute == "" {
+		return expression.ConditionBuilder{}, fmt.Errorf("invalid version condition, version attribute is not set, table : %s", t.tableName)
+	}
+
+	return expression.Name(t.versionAttribute).Equal(expression.Value(expected)), nil
+}
+
+// PutItemIfNotExists 는 같은 pk, sk 의 item 이 없을 때만 생성
+// 이미 있으면 common.ErrorConditionCheckFailed 를 전달
+func (t TableBasics) PutItemIfNotExists(c context.Context, item interface{}) error {
+	cond := notExistsCondition()
+	return t.putItemValue(c, item, &cond)
+}
+
+// PutItemWithCondition 는 조건을 만족할 때만 upsert
+// expr := expression.Name("status").Equal(expression.Value("ready")) 처럼 만들어서 넘기면 됨
+// 조건이 실패하면 common.ErrorConditionCheckFailed 를 전달
+func (t TableBasics) PutItemWithCondition(c context.Context, item interface{}, cond expression.ConditionBuilder) error {
+	return t.putItemValue(c, item, &cond)
+}
+
+// UpdateItemWithCondition 는 조건을 만족할 때만 pk, sk 의 item 을 update, 조건이 실패하면 common.ErrorConditionCheckFailed 를 전달
+// expression.UpdateBuilder 를 직접 만들어서 쓰는 경우를 위함, 보통은 UpdateItem 과 NewUpdate 를 사용
+// version 을 사용하는 테이블은 어떤 version 에 쓰는지 알 수 없어서 오류, UpdateItem 에 ExpectVersion 을 지정해서 사용
+func (t TableBasics) UpdateItemWithCondition(c context.Context, pk, sk string, update expression.UpdateBuilder, cond expression.ConditionBuilder) error {
+	if t.versionAttribute != "" {
+		return fmt.Errorf("invalid update, table %s uses version, use UpdateItem with ExpectVersion", t.tableName)
+	}
+
+	return t.UpdateItem(c, pk, sk, &Update{builder: update, condition: &cond}, nil)
+}
+
+// applyVersion 는 item 에 들어 있는 version 을 보고 쓰기 조건을 만들고, item 의 version 을 1 올려 줌
+func (t TableBasics) applyVersion(item map[string]types.AttributeValue) (expression.ConditionBuilder, error) {
+	var current int64
+
+	if v, ok := item[t.versionAttribute]; ok {
+		switch av := v.(type) {
+		case *types.AttributeValueMemberN:
+			n, err := strconv.ParseInt(av.Value, 10, 64)
+			if err != nil {
+				return expression.ConditionBuilder{}, fmt.Errorf("invalid version value, %s, %w", av.Value, err)
+			}
+			current = n
+		case *types.AttributeValueMemberNULL:
+		default:
+			return expression.ConditionBuilder{}, fmt.Errorf("invalid version attribute type, %T", v)
+		}
+	}
+
+	item[t.versionAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(current+1, 10)}
+
+	if current == 0 {
+		return notExistsCondition(), nil
+	}
+
+	return t.VersionCondition(current)
+}
+
+// notExistsCondition 는 같은 key 의 item 이 없는지 확인하는 조건
+// pk 는 항상 있는 값이라 pk 만 확인하면 됨
+func notExistsCondition() expression.ConditionBuilder {
+	return expression.AttributeNotExists(expression.Name("pk"))
+}
+
+// andCondition 는 기존 조건이 있으면 and 로 묶어 주고 없으면 새 조건만 전달
+func andCondition(cond *expression.ConditionBuilder, other expression.ConditionBuilder) *expression.ConditionBuilder {
+	if cond == nil {
+		return &other
+	}
+
+	merged := cond.And(other)
+	return &merged
+}
+
+// conditionError 는 조건 실패 오류를 common.ErrorConditionCheckFailed 로 확인 할 수 있도록 감싸 줌
+// 호출하는 쪽에서는 errors.Is(err, common.ErrorConditionCheckFailed) 로 충돌 여부를 확인 하면 됨
+func conditionError(err error) error {
+	var condEx *types.ConditionalCheckFailedException
+	if errors.As(err, &condEx) {
+		return fmt.Errorf("%w, %w", common.ErrorConditionCheckFailed, err)
+	}
+
+	return err
+}
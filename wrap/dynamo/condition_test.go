@@ -0,0 +1,78 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_ApplyVersion 는 version 값에 따라 조건을 만들고 version 을 올려 주는 기능 검사
+func Test_ApplyVersion(t *testing.T) {
+	table := New(test_table_name).WithVersion("version")
+
+	item := map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "pk"},
+		"sk": &types.AttributeValueMemberS{Value: "sk"},
+	}
+	_, err := table.applyVersion(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item["version"].(*types.AttributeValueMemberN).Value != "1" {
+		t.Fatalf("new item version must be 1, %v", item["version"])
+	}
+
+	_, err = table.applyVersion(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item["version"].(*types.AttributeValueMemberN).Value != "2" {
+		t.Fatalf("version must be increased, %v", item["version"])
+	}
+
+	item["version"] = &types.AttributeValueMemberS{Value: "1"}
+	_, err = table.applyVersion(item)
+	if err == nil {
+		t.Fatal("string version must be failed")
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_VersionCondition 는 version attribute 를 지정하지 않은 테이블에서 version 조건을 만들면 오류가 나는지 검사
+func Test_VersionCondition(t *testing.T) {
+	if _, err := New(test_table_name).VersionCondition(1); err == nil {
+		t.Fatal("version condition without version attribute must be failed")
+	}
+	if _, err := New(test_table_name).WithVersion("version").VersionCondition(1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := New(test_table_name).WithVersion("version").UpdateItemWithCondition(context.TODO(), "pk", "sk",
+		expression.Set(expression.Name("val"), expression.Value("val")), expression.AttributeExists(expression.Name("pk")))
+	if err == nil {
+		t.Fatal("update with condition on versioned table must be failed")
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_ConditionError 는 조건 실패 오류가 common.ErrorConditionCheckFailed 로 확인 되는지 검사
+func Test_ConditionError(t *testing.T) {
+	err := conditionError(&types.ConditionalCheckFailedException{})
+	if !errors.Is(err, common.ErrorConditionCheckFailed) {
+		t.Fatalf("condition failed error must be ErrorConditionCheckFailed, %v", err)
+	}
+
+	err = conditionError(errors.New("other"))
+	if errors.Is(err, common.ErrorConditionCheckFailed) {
+		t.Fatalf("other error must not be ErrorConditionCheckFailed, %v", err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
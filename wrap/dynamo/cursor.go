@@ -0,0 +1,81 @@
+package dynamo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// cursorValue 는 LastEvaluatedKey 의 attribute 값을 json 으로 옮겨 담기 위한 구조체
+// key 로 쓸 수 있는 타입은 S, N, B 뿐이라서 이 세가지만 처리
+type cursorValue struct {
+	S *string `json:"s,omitempty"`
+	N *string `json:"n,omitempty"`
+	B []byte  `json:"b,omitempty"`
+}
+
+// EncodeCursor 는 query 응답의 LastEvaluatedKey 를 외부에 넘겨 줄 수 있도록 url safe 한 문자열로 변환
+// 다음 페이지가 없으면 빈 문자열을 전달
+func EncodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	m := make(map[string]cursorValue, len(key))
+	for k, v := range key {
+		switch av := v.(type) {
+		case *types.AttributeValueMemberS:
+			m[k] = cursorValue{S: &av.Value}
+		case *types.AttributeValueMemberN:
+			m[k] = cursorValue{N: &av.Value}
+		case *types.AttributeValueMemberB:
+			m[k] = cursorValue{B: av.Value}
+		default:
+			return "", fmt.Errorf("invalid cursor key type, key : %s, type : %T", k, v)
+		}
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("cursor json marshal failed, %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor 는 EncodeCursor 로 만든 문자열을 다시 ExclusiveStartKey 로 사용할 수 있게 변환
+// 빈 문자열이면 첫 페이지로 보고 nil 을 전달
+func DecodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor, %w", err)
+	}
+
+	var m map[string]cursorValue
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor, %w", err)
+	}
+
+	key := make(map[string]types.AttributeValue, len(m))
+	for k, v := range m {
+		switch {
+		case v.S != nil:
+			key[k] = &types.AttributeValueMemberS{Value: *v.S}
+		case v.N != nil:
+			key[k] = &types.AttributeValueMemberN{Value: *v.N}
+		case v.B != nil:
+			key[k] = &types.AttributeValueMemberB{Value: v.B}
+		default:
+			return nil, fmt.Errorf("invalid cursor, empty value for key %s", k)
+		}
+	}
+
+	return key, nil
+}
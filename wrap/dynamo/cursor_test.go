@@ -0,0 +1,45 @@
+package dynamo
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_Cursor 는 LastEvaluatedKey 를 cursor 로 만들었다가 다시 key 로 돌려 받는 기능 검사
+func Test_Cursor(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"pk":      &types.AttributeValueMemberS{Value: "pk"},
+		"sk":      &types.AttributeValueMemberS{Value: "bulksk#1"},
+		"updated": &types.AttributeValueMemberN{Value: "1712345678"},
+	}
+
+	cursor, err := EncodeCursor(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded["sk"].(*types.AttributeValueMemberS).Value != "bulksk#1" ||
+		decoded["updated"].(*types.AttributeValueMemberN).Value != "1712345678" {
+		t.Fatalf("cursor decode mismatch, %v", decoded)
+	}
+
+	empty, err := EncodeCursor(nil)
+	if err != nil || empty != "" {
+		t.Fatalf("empty key must be empty cursor, cursor : %s, err : %v", empty, err)
+	}
+
+	_, err = DecodeCursor("!!invalid")
+	if err == nil {
+		t.Fatal("invalid cursor must be failed")
+	}
+
+	log.Debug().Interface("cursor", cursor).Msgf(test_success_msg_format, common.FunctionName())
+}
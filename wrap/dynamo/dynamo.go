@@ -13,14 +13,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/dalpengida/portfolio-go-aws/common"
-	"github.com/dalpengida/portfolio-go-aws/config"
 	"github.com/rs/zerolog/log"
 )
 
-var (
-	client *dynamodb.Client
-)
-
 const (
 	max_count_bulk_item        = 25
 	max_count_transaction_item = 100
@@ -32,10 +27,12 @@ const (
 // 여러 테이블을 사용할 수 있을 것 같아서 table 값을 초기화 할때 받아서 사용하게 하기 위함
 type TableBasics struct {
 	tableName string
-}
 
-func init() {
-	client = dynamodb.NewFromConfig(config.GetAws())
+	// client 는 nil 이면 default client 를 사용
+	client Client
+
+	// versionAttribute 는 optimistic locking 에 사용할 attribute 이름, 빈 값이면 사용 안함
+	versionAttribute string
 }
 
 func New(tablename string) TableBasics {
@@ -44,6 +41,21 @@ func New(tablename string) TableBasics {
 	}
 }
 
+// NewWithClient 는 외부에서 만든 client 를 사용하는 TableBasics 를 생성
+// local dynamodb 나 테스트용 fake 를 넣을 때 사용
+func NewWithClient(client Client, tablename string) TableBasics {
+	return TableBasics{
+		tableName: tablename,
+		client:    client,
+	}
+}
+
+// NewFromConfig 는 aws config 로 client 를 만들어서 TableBasics 를 생성
+// 다른 region, endpoint 를 사용해야 할 때 사용
+func NewFromConfig(cfg aws.Config, tablename string) TableBasics {
+	return NewWithClient(dynamodb.NewFromConfig(cfg), tablename)
+}
+
 func NewDefault() TableBasics {
 	return TableBasics{tableName: default_tablename}
 }
@@ -59,12 +71,12 @@ func (t TableBasics) CreateTable(c context.Context, createTableSchema *dynamodb.
 	}
 	createTableSchema.TableName = aws.String(t.tableName)
 
-	r, err := client.CreateTable(c, createTableSchema)
+	r, err := t.api().CreateTable(c, createTableSchema)
 	if err != nil {
 		return nil, fmt.Errorf("create table %v failed, %w", t.tableName, err)
 
 	} else {
-		waiter := dynamodb.NewTableExistsWaiter(client)
+		waiter := dynamodb.NewTableExistsWaiter(t.api())
 		// 생성을 요청하고 바로 올라오는게 아니다 보니 좀 대기
 		err = waiter.Wait(c, &dynamodb.DescribeTableInput{
 			TableName: aws.String(t.tableName)}, 5*time.Minute)
@@ -80,7 +92,7 @@ func (t TableBasics) CreateTable(c context.Context, createTableSchema *dynamodb.
 
 // IsExist 테이블 존재 여부 확인
 func (t TableBasics) IsExist(c context.Context) (bool, error) {
-	_, err := client.DescribeTable(
+	_, err := t.api().DescribeTable(
 		c, &dynamodb.DescribeTableInput{TableName: aws.String(t.tableName)},
 	)
 	if err != nil {
@@ -97,8 +109,8 @@ func (t TableBasics) IsExist(c context.Context) (bool, error) {
 }
 
 // ListTables 테이블 리스트 조회
-func (TableBasics) ListTables(c context.Context) ([]string, error) {
-	r, err := client.ListTables(c, &dynamodb.ListTablesInput{})
+func (t TableBasics) ListTables(c context.Context) ([]string, error) {
+	r, err := t.api().ListTables(c, &dynamodb.ListTablesInput{})
 	if err != nil {
 		return nil, fmt.Errorf("table list lookup failed, %w", err)
 	}
@@ -110,16 +122,61 @@ func (TableBasics) ListTables(c context.Context) ([]string, error) {
 // PutItem 는 item interface를 받아서 데이터를 추가
 // dynamo 에서 putitem 은 upsert 인것으로 확인
 // response 값은 쓸일이 없을 것 같아서 생략
+// WithVersion 으로 만든 TableBasics 면 version 조건을 걸고 넣음, item 이 pointer 면 올라간 version 을 반영해 줌
 func (t TableBasics) PutItem(c context.Context, item interface{}) error {
+	return t.putItemValue(c, item, nil)
+}
+
+// putItemValue 는 item 을 marshaling 해서 조건과 함께 넣고, item 이 pointer 면 최종 저장된 값을 다시 바인딩 해 줌
+func (t TableBasics) putItemValue(c context.Context, item interface{}, cond *expression.ConditionBuilder) error {
 	i, err := attributevalue.MarshalMap(item)
 	if err != nil {
 		return fmt.Errorf("attribute marshal map failed, %w", err)
 	}
-	response, err := client.PutItem(c, &dynamodb.PutItemInput{
+
+	err = t.putItem(c, i, cond)
+	if err != nil {
+		return err
+	}
+
+	if t.versionAttribute != "" && reflect.ValueOf(item).Kind() == reflect.Pointer {
+		err = attributevalue.UnmarshalMap(i, item)
+		if err != nil {
+			return fmt.Errorf("attribute unmarshal map failed, %w", err)
+		}
+	}
+
+	return nil
+}
+
+// putItem 는 이미 marshaling 된 item 을 그대로 넣어 줌
+// Repository 처럼 key 를 따로 채워 넣어야 하는 곳에서 같이 쓰기 위해 분리
+// cond 가 nil 이 아니면 조건을 걸고, version 을 사용하면 version 조건도 같이 걸어 줌
+func (t TableBasics) putItem(c context.Context, i map[string]types.AttributeValue, cond *expression.ConditionBuilder) error {
+	if t.versionAttribute != "" {
+		versionCond, err := t.applyVersion(i)
+		if err != nil {
+			return err
+		}
+		cond = andCondition(cond, versionCond)
+	}
+
+	input := &dynamodb.PutItemInput{
 		TableName: aws.String(t.tableName), Item: i,
-	})
+	}
+	if cond != nil {
+		expr, err := expression.NewBuilder().WithCondition(*cond).Build()
+		if err != nil {
+			return fmt.Errorf("condition expression build failed, %w", err)
+		}
+		input.ConditionExpression = expr.Condition()
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+	}
+
+	response, err := t.api().PutItem(c, input)
 	if err != nil {
-		return fmt.Errorf("put item failed, %w", err)
+		return fmt.Errorf("put item failed, %w", conditionError(err))
 	}
 
 	log.Debug().Interface("response", response).Msg("put item success")
@@ -129,20 +186,12 @@ func (t TableBasics) PutItem(c context.Context, item interface{}) error {
 
 // FindWithPK 는 pk 를 기준으로 데이터를 모두 조회 , 입력한 구조체로 바인딩을 해서 전달
 // pk 를 기준으로 조회를 하다 보면 메시지는 여러건이 나오기 때문에 slice obj 형태로 인자를 받아야 함
+// query 한번의 결과만 전달하기 때문에 1MB 가 넘어가는 나머지는 잘림, 전부 필요하면 FindAllWithPK 를 사용
 func (t TableBasics) FindWithPK(c context.Context, pk string, sliceObj interface{}) error {
-	// TODO: sliceObj 검사 로직을 넣어야 함
-	// 내부 UnmarshalListOfMaps 에서 걸러질 수 있지만, 고민
+	// sliceObj 검사는 내부 UnmarshalListOfMaps 에서 걸러지는 걸로 두고
+	// 타입을 컴파일 때 확인하고 싶으면 Repository[T] 를 사용
 
-	response, err := client.Query(c, &dynamodb.QueryInput{
-		TableName: aws.String(t.tableName),
-
-		KeyConditionExpression: aws.String("pk = :pk"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk": &types.AttributeValueMemberS{
-				Value: pk,
-			},
-		},
-	})
+	response, err := t.api().Query(c, t.pkQueryInput(pk))
 	if err != nil {
 		return fmt.Errorf("find with pk failed, %w", err)
 	}
@@ -165,20 +214,10 @@ func (t TableBasics) FindWithPK(c context.Context, pk string, sliceObj interface
 // 'begins_with' function 은 대소문자 구분함, 괜히 예약어라고 해서 upper case 로 섰다가 망함
 // limit 값으로 한건만 찾아야 하는 경우, 그리고 여러건을 찾아야 하는 경우를 함수를 나눠서 사용할까 했지만 어차피 binding 할 때 slice 로 돌려 주기 때문에 의미 없음
 func (t TableBasics) FindBeginsWith(c context.Context, pk, prefixSk string, objSlice interface{}, limit int) error {
-	response, err := client.Query(c, &dynamodb.QueryInput{
-		TableName: aws.String(t.tableName),
+	input := t.beginsWithQueryInput(pk, prefixSk)
+	input.Limit = aws.Int32(int32(limit))
 
-		KeyConditionExpression: aws.String("pk = :pk and begins_with(sk, :beginsWith)"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk": &types.AttributeValueMemberS{
-				Value: pk,
-			},
-			":beginsWith": &types.AttributeValueMemberS{
-				Value: prefixSk,
-			},
-		},
-		Limit: aws.Int32(int32(limit)),
-	})
+	response, err := t.api().Query(c, input)
 	if err != nil {
 		return fmt.Errorf("find beginswith failed, %w", err)
 	}
@@ -202,7 +241,7 @@ func (t TableBasics) MustFindOne(c context.Context, pk, sk string, obj interface
 		sk = "#"
 	}
 
-	response, err := client.GetItem(context.TODO(), &dynamodb.GetItemInput{
+	response, err := t.api().GetItem(context.TODO(), &dynamodb.GetItemInput{
 		TableName: aws.String(t.tableName),
 		Key: map[string]types.AttributeValue{
 			"pk": &types.AttributeValueMemberS{Value: pk},
@@ -228,7 +267,7 @@ func (t TableBasics) MustFindOne(c context.Context, pk, sk string, obj interface
 
 // DeleteItem 는 pk, sk 를 인자로 받아서 item 삭제
 func (t TableBasics) DeleteItem(c context.Context, pk, sk string) error {
-	_, err := client.DeleteItem(c, &dynamodb.DeleteItemInput{
+	_, err := t.api().DeleteItem(c, &dynamodb.DeleteItemInput{
 		TableName: aws.String(t.tableName),
 		Key: map[string]types.AttributeValue{
 			"pk": &types.AttributeValueMemberS{Value: pk},
@@ -244,27 +283,24 @@ func (t TableBasics) DeleteItem(c context.Context, pk, sk string) error {
 	return nil
 }
 
-// PutItemsWithBatch 는 한번에 여러 데이터를 넣을 수 있는 함수, 최대 25개의 아이템까지만 지원
+// PutItemsWithBatch 는 한번에 여러 데이터를 넣을 수 있는 함수
 // 문서 상에서는 최대 25개의 아이템까지만 쓰라고 함
 // dynamodb 의 BatchWriteItem 코드상의 주석을 보면 아래와 같이 제한이 있다고 함
 //   - There are more than 25 requests in the batch.
 //   - Any individual item in a batch exceeds 400 KB.
 //   - The total request size exceeds 16 MB.
+//
+// 밖에서 25개씩 잘라서 넣다 보니 매번 같은 코드가 생겨서, 내부에서 25개씩 나눠서 동시에 요청 하도록 변경
+// 처리 못한 UnprocessedItems 는 backoff 를 주면서 재시도 하고, 그래도 실패한 item 은 *BatchWriteError 로 전달
 func (t TableBasics) PutItemsWithBatch(c context.Context, items interface{}) error {
 	var err error
 	var item map[string]types.AttributeValue
-	var writeReqs []types.WriteRequest
+	var avs []map[string]types.AttributeValue
 
 	rf := reflect.ValueOf(items)
 	if rf.Kind() != reflect.Slice {
 		return fmt.Errorf("invalid items, items is not slice")
 	}
-	// 최대 지원 수 이상으로 요청을 할 경우 오류 처리
-	// 외부에서 알아서 걸러서 넣도록 함 , 내부에서 처리를 할 수도 있지만, 외부에서 컨트롤 하는게 낫다고 생각되어
-	if rf.Len() > max_count_bulk_item {
-		log.Error().Interface("request_items_count", rf.Len()).Msg(common.ErrorRequestParameterExceed.Error())
-		return common.ErrorRequestParameterExceed
-	}
 
 	rv := reflect.ValueOf(items)
 	for i := 0; i < rv.Len(); i++ {
@@ -272,23 +308,11 @@ func (t TableBasics) PutItemsWithBatch(c context.Context, items interface{}) err
 		item, err = attributevalue.MarshalMap(v)
 		if err != nil {
 			return fmt.Errorf("attribute value marshal failed, v : %v, err : %w", v, err)
-
-		} else {
-			writeReqs = append(
-				writeReqs,
-				types.WriteRequest{PutRequest: &types.PutRequest{Item: item}},
-			)
 		}
+		avs = append(avs, item)
 	}
-	response, err := client.BatchWriteItem(c, &dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]types.WriteRequest{t.tableName: writeReqs}})
-	if err != nil {
-		return fmt.Errorf("batch write item failed, %w", err)
-	}
-
-	log.Debug().Interface("rseponse", response).Msg("batch write item success")
 
-	return nil
+	return t.putItemsWithBatch(c, avs)
 }
 
 // PutItemsWithTransaction 는 트랜잭션을 걸고 여러건의 request 를 함
@@ -297,6 +321,7 @@ func (t TableBasics) PutItemsWithBatch(c context.Context, items interface{}) err
 // 실제로 테스트 해보면 그룹이 아니라도 100건 이상 하면 초과 오류 남
 // 'transactItems' failed to satisfy constraint: Member must have length less than or equal to 100","time":"2024-04-05T02:58:46+09:00","message":"failed"
 // 중간에 롤백이 되는지 확인 하고 싶으나, 쉽사리 재현이 안됨
+// Put 말고 Update, Delete, ConditionCheck 를 섞어야 하면 NewTransaction 을 사용
 func (t TableBasics) PutItemsWithTransaction(c context.Context, items interface{}) error {
 	var err error
 	var item map[string]types.AttributeValue
@@ -329,12 +354,12 @@ func (t TableBasics) PutItemsWithTransaction(c context.Context, items interface{
 		)
 	}
 
-	response, err := client.TransactWriteItems(
+	response, err := t.api().TransactWriteItems(
 		c,
 		&dynamodb.TransactWriteItemsInput{TransactItems: txPutRequests},
 	)
 	if err != nil {
-		return fmt.Errorf("transaction write items failed, err : %w", err)
+		return fmt.Errorf("transaction write items failed, err : %w", transactionError(err))
 	}
 
 	log.Debug().Interface("rseponse", response).Msg("put item with transaction success")
@@ -348,24 +373,209 @@ func (t TableBasics) PutItemsWithTransaction(c context.Context, items interface{
 // query 이기 떄문에 slice 형태로 결과값이 전달이 될 것이기 때문에 slice 형태로 바인딩하는 곳에서 사용을 해서 넘겨야 함
 // 대신 해당 unmarshaling 은 해서 전달 해줌
 // 해당 기능은 1MB제한이 있는 거 같음 테스트가 필요
+// 1MB 제한이 있는게 맞음, 다음 페이지가 필요하면 FindWithGSIPaging, 전부 필요하면 FindAllWithGSI 를 사용
 func (t TableBasics) FindWithGSI(c context.Context, gsi string, expr expression.Expression, obj interface{}) error {
-	r, err := client.Query(c, &dynamodb.QueryInput{
+	r, err := t.api().Query(c, t.gsiQueryInput(gsi, expr))
+	if err != nil {
+		return fmt.Errorf("finde with gsi failed, err : %w", err)
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(r.Items, obj)
+	if err != nil {
+		return fmt.Errorf("find with gsi failed, attributevalue.UnmarshalListOfMaps err : %w", err)
+	}
+
+	log.Debug().Interface("items", obj).Msg("find with gsi success")
+
+	return nil
+}
+
+// pkQueryInput 는 pk 로 조회하는 query input 을 만들어 줌
+func (t TableBasics) pkQueryInput(pk string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName: aws.String(t.tableName),
+
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{
+				Value: pk,
+			},
+		},
+	}
+}
+
+// beginsWithQueryInput 는 pk, sk 의 prefix 로 조회하는 query input 을 만들어 줌
+func (t TableBasics) beginsWithQueryInput(pk, prefixSk string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName: aws.String(t.tableName),
+
+		KeyConditionExpression: aws.String("pk = :pk and begins_with(sk, :beginsWith)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{
+				Value: pk,
+			},
+			":beginsWith": &types.AttributeValueMemberS{
+				Value: prefixSk,
+			},
+		},
+	}
+}
+
+// gsiQueryInput 는 gsi 와 외부에서 만든 expression 으로 query input 을 만들어 줌
+func (t TableBasics) gsiQueryInput(gsi string, expr expression.Expression) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
 		TableName:                 aws.String(t.tableName),
 		IndexName:                 aws.String(gsi),
 		KeyConditionExpression:    expr.KeyCondition(),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
-	})
+	}
+}
+
+// queryPage 는 cursor 위치부터 한 페이지만 조회하고, 다음 페이지를 조회할 수 있는 cursor 를 같이 전달
+// 마지막 페이지 였으면 cursor 는 빈 문자열
+func (t TableBasics) queryPage(c context.Context, input *dynamodb.QueryInput, cursor string, limit int) ([]map[string]types.AttributeValue, string, error) {
+	startKey, err := DecodeCursor(cursor)
 	if err != nil {
-		return fmt.Errorf("finde with gsi failed, err : %w", err)
+		return nil, "", err
 	}
 
-	err = attributevalue.UnmarshalListOfMaps(r.Items, obj)
+	input.ExclusiveStartKey = startKey
+	if limit > 0 {
+		input.Limit = aws.Int32(int32(limit))
+	}
+
+	r, err := t.api().Query(c, input)
 	if err != nil {
-		return fmt.Errorf("find with gsi failed, attributevalue.UnmarshalListOfMaps err : %w", err)
+		return nil, "", fmt.Errorf("query page failed, %w", err)
 	}
 
-	log.Debug().Interface("items", obj).Msg("find with gsi success")
+	next, err := EncodeCursor(r.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return r.Items, next, nil
+}
+
+// queryAll 는 LastEvaluatedKey 가 없을 때까지 query 를 반복해서 모든 페이지의 item 을 모아서 전달
+// 파티션이 아주 크면 메모리를 많이 먹을 수 있으니 배치 작업 같은 데서만 쓰는 걸로
+func (t TableBasics) queryAll(c context.Context, input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+
+	p := dynamodb.NewQueryPaginator(t.api(), input)
+	for p.HasMorePages() {
+		r, err := p.NextPage(c)
+		if err != nil {
+			return nil, fmt.Errorf("query all pages failed, %w", err)
+		}
+		items = append(items, r.Items...)
+	}
+
+	return items, nil
+}
+
+// FindWithPKPaging 는 pk 를 기준으로 cursor 위치부터 limit 만큼 조회하고 다음 페이지 cursor 를 전달
+// 첫 페이지는 cursor 를 빈 문자열로 넘기면 되고, 돌려 받은 cursor 가 빈 문자열이면 마지막 페이지
+// limit 가 0 이하이면 dynamo 에서 한번에 주는 만큼(1MB) 가져옴
+func (t TableBasics) FindWithPKPaging(c context.Context, pk, cursor string, limit int, sliceObj interface{}) (string, error) {
+	items, next, err := t.queryPage(c, t.pkQueryInput(pk), cursor, limit)
+	if err != nil {
+		return "", fmt.Errorf("find with pk paging failed, %w", err)
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(items, sliceObj)
+	if err != nil {
+		return "", fmt.Errorf("attributevalue unmarshallistofmaps failed, err : %w", err)
+	}
+
+	log.Debug().Interface("pk", pk).Interface("count", len(items)).Interface("next_cursor", next).Msg("find with pk paging success")
+
+	return next, nil
+}
+
+// FindAllWithPK 는 pk 를 기준으로 모든 페이지를 따라가면서 전부 조회
+func (t TableBasics) FindAllWithPK(c context.Context, pk string, sliceObj interface{}) error {
+	items, err := t.queryAll(c, t.pkQueryInput(pk))
+	if err != nil {
+		return fmt.Errorf("find all with pk failed, %w", err)
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(items, sliceObj)
+	if err != nil {
+		return fmt.Errorf("attributevalue unmarshallistofmaps failed, err : %w", err)
+	}
+
+	log.Debug().Interface("pk", pk).Interface("count", len(items)).Msg("find all with pk success")
+
+	return nil
+}
+
+// FindBeginsWithPaging 는 FindBeginsWith 와 동일한 조건으로 cursor 위치부터 limit 만큼 조회하고 다음 페이지 cursor 를 전달
+func (t TableBasics) FindBeginsWithPaging(c context.Context, pk, prefixSk, cursor string, limit int, objSlice interface{}) (string, error) {
+	items, next, err := t.queryPage(c, t.beginsWithQueryInput(pk, prefixSk), cursor, limit)
+	if err != nil {
+		return "", fmt.Errorf("find beginswith paging failed, %w", err)
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(items, objSlice)
+	if err != nil {
+		return "", fmt.Errorf("attributevalue unmarshallistofmaps failed, err : %w", err)
+	}
+
+	log.Debug().Interface("pk", pk).Interface("prefix_sk", prefixSk).Interface("count", len(items)).Interface("next_cursor", next).Msg("find begins with paging success")
+
+	return next, nil
+}
+
+// FindAllBeginsWith 는 pk, sk의 prefix 값으로 모든 페이지를 따라가면서 전부 조회
+func (t TableBasics) FindAllBeginsWith(c context.Context, pk, prefixSk string, objSlice interface{}) error {
+	items, err := t.queryAll(c, t.beginsWithQueryInput(pk, prefixSk))
+	if err != nil {
+		return fmt.Errorf("find all beginswith failed, %w", err)
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(items, objSlice)
+	if err != nil {
+		return fmt.Errorf("attributevalue unmarshallistofmaps failed, err : %w", err)
+	}
+
+	log.Debug().Interface("pk", pk).Interface("prefix_sk", prefixSk).Interface("count", len(items)).Msg("find all begins with success")
+
+	return nil
+}
+
+// FindWithGSIPaging 는 gsi 로 cursor 위치부터 limit 만큼 조회하고 다음 페이지 cursor 를 전달
+// gsi 의 LastEvaluatedKey 에는 테이블 key 와 index key 가 같이 들어 있어서 cursor 도 그대로 같이 담김
+func (t TableBasics) FindWithGSIPaging(c context.Context, gsi string, expr expression.Expression, cursor string, limit int, obj interface{}) (string, error) {
+	items, next, err := t.queryPage(c, t.gsiQueryInput(gsi, expr), cursor, limit)
+	if err != nil {
+		return "", fmt.Errorf("find with gsi paging failed, %w", err)
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(items, obj)
+	if err != nil {
+		return "", fmt.Errorf("find with gsi paging failed, attributevalue.UnmarshalListOfMaps err : %w", err)
+	}
+
+	log.Debug().Interface("gsi", gsi).Interface("count", len(items)).Interface("next_cursor", next).Msg("find with gsi paging success")
+
+	return next, nil
+}
+
+// FindAllWithGSI 는 gsi 로 모든 페이지를 따라가면서 전부 조회
+func (t TableBasics) FindAllWithGSI(c context.Context, gsi string, expr expression.Expression, obj interface{}) error {
+	items, err := t.queryAll(c, t.gsiQueryInput(gsi, expr))
+	if err != nil {
+		return fmt.Errorf("find all with gsi failed, %w", err)
+	}
+
+	err = attributevalue.UnmarshalListOfMaps(items, obj)
+	if err != nil {
+		return fmt.Errorf("find all with gsi failed, attributevalue.UnmarshalListOfMaps err : %w", err)
+	}
+
+	log.Debug().Interface("gsi", gsi).Interface("count", len(items)).Msg("find all with gsi success")
 
 	return nil
 }
@@ -0,0 +1,623 @@
+package dynamotest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// expression 은 expression.Builder 로 만든 식(#0, :0 형태)과 직접 쓴 식(pk = :pk and begins_with(sk, :v)) 둘 다 해석할 수 있도록 만듦
+// dynamo 문법 전체가 아니라 wrap/dynamo 에서 사용하는 정도만 지원
+
+type tokenKind int
+
+const (
+	token_eof tokenKind = iota
+	token_ident
+	token_name  // #name
+	token_value // :value
+	token_number
+	token_symbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize 는 expression 문자열을 token 으로 나눠 줌
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+
+	rs := []rune(expr)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '#' || r == ':' || unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			kind := token_ident
+			if r == '#' {
+				kind = token_name
+			} else if r == ':' {
+				kind = token_value
+			}
+			tokens = append(tokens, token{kind: kind, text: string(rs[i:j])})
+			i = j
+
+		case unicode.IsDigit(r):
+			j := i + 1
+			for j < len(rs) && unicode.IsDigit(rs[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: token_number, text: string(rs[i:j])})
+			i = j
+
+		case r == '<' || r == '>':
+			if i+1 < len(rs) && (rs[i+1] == '=' || (r == '<' && rs[i+1] == '>')) {
+				tokens = append(tokens, token{kind: token_symbol, text: string(rs[i : i+2])})
+				i += 2
+				continue
+			}
+			tokens = append(tokens, token{kind: token_symbol, text: string(r)})
+			i++
+
+		case strings.ContainsRune("()[],.=+-", r):
+			tokens = append(tokens, token{kind: token_symbol, text: string(r)})
+			i++
+
+		default:
+			return nil, fmt.Errorf("invalid expression, unexpected character %q in %q", r, expr)
+		}
+	}
+
+	return append(tokens, token{kind: token_eof}), nil
+}
+
+// pathElement 는 attribute 경로의 한 단계, map 의 key 이거나 list 의 index
+type pathElement struct {
+	name  string
+	index int
+	list  bool
+}
+
+type path []pathElement
+
+// top 는 경로의 최상위 attribute 이름
+func (p path) top() string {
+	return p[0].name
+}
+
+func (p path) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.list {
+			fmt.Fprintf(&sb, "[%d]", e.index)
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(e.name)
+	}
+	return sb.String()
+}
+
+// operand 는 item 에서 값을 꺼내는 식, 값이 없으면 false
+type operand func(item map[string]types.AttributeValue) (types.AttributeValue, bool, error)
+
+// condition 는 item 이 조건을 만족하는지 확인하는 식
+type condition func(item map[string]types.AttributeValue) (bool, error)
+
+type parser struct {
+	tokens []token
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newParser(expr string, names map[string]string, values map[string]types.AttributeValue) (*parser, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parser{tokens: tokens, names: names, values: values}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != token_eof {
+		p.pos++
+	}
+	return t
+}
+
+// keyword 는 다음 token 이 대소문자 구분 없이 word 와 같으면 넘기고 true
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == token_ident && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol 는 다음 token 이 s 이면 넘기고 true
+func (p *parser) symbol(s string) bool {
+	t := p.peek()
+	if t.kind == token_symbol && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.symbol(s) {
+		return fmt.Errorf("invalid expression, expected %q but got %q", s, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) end() error {
+	if t := p.peek(); t.kind != token_eof {
+		return fmt.Errorf("invalid expression, unexpected token %q", t.text)
+	}
+	return nil
+}
+
+// isFunction 는 다음 token 이 name 함수 호출인지 확인
+func (p *parser) isFunction(name string) bool {
+	t := p.tokens[p.pos]
+	if t.kind != token_ident || !strings.EqualFold(t.text, name) {
+		return false
+	}
+	n := p.tokens[p.pos+1]
+	return n.kind == token_symbol && n.text == "("
+}
+
+// parsePath 는 #name.#child[0] 형태의 attribute 경로를 읽음
+func (p *parser) parsePath() (path, error) {
+	var result path
+
+	for {
+		t := p.next()
+		var name string
+		switch t.kind {
+		case token_name:
+			n, ok := p.names[t.text]
+			if !ok {
+				return nil, fmt.Errorf("invalid expression, attribute name %s is not defined", t.text)
+			}
+			name = n
+		case token_ident:
+			name = t.text
+		default:
+			return nil, fmt.Errorf("invalid expression, expected attribute name but got %q", t.text)
+		}
+		result = append(result, pathElement{name: name})
+
+		for p.symbol("[") {
+			t := p.next()
+			if t.kind != token_number {
+				return nil, fmt.Errorf("invalid expression, expected list index but got %q", t.text)
+			}
+			index, _ := strconv.Atoi(t.text)
+			result = append(result, pathElement{index: index, list: true})
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+
+		if !p.symbol(".") {
+			return result, nil
+		}
+	}
+}
+
+// parseValue 는 :value 를 ExpressionAttributeValues 에서 찾음
+func (p *parser) parseValue() (types.AttributeValue, error) {
+	t := p.next()
+	v, ok := p.values[t.text]
+	if !ok {
+		return nil, fmt.Errorf("invalid expression, attribute value %s is not defined", t.text)
+	}
+	return v, nil
+}
+
+// parseOperand 는 조건식에서 사용하는 값, attribute 경로, :value, size(path)
+func (p *parser) parseOperand() (operand, error) {
+	if p.isFunction("size") {
+		p.next()
+		p.next()
+		inner, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+			v, ok, err := inner(item)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			n, ok := sizeOf(v)
+			if !ok {
+				return nil, false, nil
+			}
+			return &types.AttributeValueMemberN{Value: strconv.Itoa(n)}, true, nil
+		}, nil
+	}
+
+	if p.peek().kind == token_value {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return func(map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+			return v, true, nil
+		}, nil
+	}
+
+	target, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+		v, ok := getPath(item, target)
+		return v, ok, nil
+	}, nil
+}
+
+// parseCondition 는 condition, key condition, filter expression 을 읽음
+// 우선 순위는 dynamo 와 동일하게 OR < AND < NOT
+func (p *parser) parseCondition() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item map[string]types.AttributeValue) (bool, error) {
+			ok, err := l(item)
+			if err != nil || ok {
+				return ok, err
+			}
+			return right(item)
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item map[string]types.AttributeValue) (bool, error) {
+			ok, err := l(item)
+			if err != nil || !ok {
+				return ok, err
+			}
+			return right(item)
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.keyword("NOT") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) (bool, error) {
+			ok, err := inner(item)
+			return !ok, err
+		}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.symbol("(") {
+		inner, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	for _, name := range []string{"attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains"} {
+		if p.isFunction(name) {
+			return p.parseFunction(name)
+		}
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("BETWEEN") {
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, fmt.Errorf("invalid expression, expected AND in BETWEEN")
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) (bool, error) {
+			vs, ok, err := evalOperands(item, left, low, high)
+			if err != nil || !ok {
+				return false, err
+			}
+			lo, ok1 := compare(vs[0], vs[1])
+			hi, ok2 := compare(vs[0], vs[2])
+			return ok1 && ok2 && lo >= 0 && hi <= 0, nil
+		}, nil
+	}
+
+	if p.keyword("IN") {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var candidates []operand
+		for {
+			o, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, o)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) (bool, error) {
+			v, ok, err := left(item)
+			if err != nil || !ok {
+				return false, err
+			}
+			for _, o := range candidates {
+				c, ok, err := o(item)
+				if err != nil {
+					return false, err
+				}
+				if ok && equal(v, c) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}
+
+	t := p.next()
+	if t.kind != token_symbol {
+		return nil, fmt.Errorf("invalid expression, expected comparator but got %q", t.text)
+	}
+	op := t.text
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "=", "<>":
+		return func(item map[string]types.AttributeValue) (bool, error) {
+			vs, ok, err := evalOperands(item, left, right)
+			if err != nil {
+				return false, err
+			}
+			// attribute 가 없으면 = 은 false, <> 는 true
+			if !ok {
+				return op == "<>", nil
+			}
+			return equal(vs[0], vs[1]) == (op == "="), nil
+		}, nil
+	case "<", "<=", ">", ">=":
+		return func(item map[string]types.AttributeValue) (bool, error) {
+			vs, ok, err := evalOperands(item, left, right)
+			if err != nil || !ok {
+				return false, err
+			}
+			n, ok := compare(vs[0], vs[1])
+			if !ok {
+				return false, nil
+			}
+			switch op {
+			case "<":
+				return n < 0, nil
+			case "<=":
+				return n <= 0, nil
+			case ">":
+				return n > 0, nil
+			default:
+				return n >= 0, nil
+			}
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid expression, unknown comparator %q", op)
+}
+
+// parseFunction 는 조건 함수들을 읽음
+func (p *parser) parseFunction(name string) (condition, error) {
+	p.next()
+	p.next()
+
+	target, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	var arg operand
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		arg, err = p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return func(item map[string]types.AttributeValue) (bool, error) {
+		v, exists, err := target(item)
+		if err != nil {
+			return false, err
+		}
+
+		switch name {
+		case "attribute_exists":
+			return exists, nil
+		case "attribute_not_exists":
+			return !exists, nil
+		}
+
+		a, ok, err := arg(item)
+		if err != nil || !exists || !ok {
+			return false, err
+		}
+
+		switch name {
+		case "attribute_type":
+			s, ok := a.(*types.AttributeValueMemberS)
+			return ok && typeOf(v) == s.Value, nil
+		case "begins_with":
+			return beginsWith(v, a), nil
+		default:
+			return contains(v, a), nil
+		}
+	}, nil
+}
+
+// evalOperands 는 operand 들의 값을 꺼냄, 하나라도 없으면 false
+func evalOperands(item map[string]types.AttributeValue, operands ...operand) ([]types.AttributeValue, bool, error) {
+	vs := make([]types.AttributeValue, 0, len(operands))
+	for _, o := range operands {
+		v, ok, err := o(item)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, true, nil
+}
+
+// parseConditionExpression 는 조건식 문자열을 condition 으로 만들어 줌, 빈 식이면 항상 true
+func parseConditionExpression(expr *string, names map[string]string, values map[string]types.AttributeValue) (condition, error) {
+	if expr == nil || strings.TrimSpace(*expr) == "" {
+		return func(map[string]types.AttributeValue) (bool, error) { return true, nil }, nil
+	}
+
+	p, err := newParser(*expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+
+	return cond, nil
+}
+
+// parseProjection 는 projection expression 을 경로 목록으로 만들어 줌, 빈 식이면 nil
+func parseProjection(expr *string, names map[string]string) ([]path, error) {
+	if expr == nil || strings.TrimSpace(*expr) == "" {
+		return nil, nil
+	}
+
+	p, err := newParser(*expr, names, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []path
+	for {
+		target, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, target)
+		if !p.symbol(",") {
+			break
+		}
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// project 는 item 에서 paths 에 해당 하는 attribute 만 남긴 복사본을 전달
+func project(item map[string]types.AttributeValue, paths []path) map[string]types.AttributeValue {
+	if paths == nil {
+		return cloneItem(item)
+	}
+
+	result := map[string]types.AttributeValue{}
+	for _, target := range paths {
+		v, ok := getPath(item, target)
+		if !ok {
+			continue
+		}
+		// list index 로 꺼낸 값은 projection 결과에서 list 뒤에 붙음
+		projected := make(path, 0, len(target))
+		for _, e := range target {
+			if e.list {
+				e.index = -1
+			}
+			projected = append(projected, e)
+		}
+		_ = setPath(result, projected, clone(v), true)
+	}
+
+	return result
+}
@@ -0,0 +1,1157 @@
+// dynamotest 는 dynamo.Client 를 메모리에서 구현한 fake 를 제공
+// 실제 테이블 없이 model, service 테스트를 돌리기 위함
+//
+//	fake := dynamotest.New().AddTable("portfolio")
+//	dynamo.SetDefaultClient(fake)
+//	// 또는 dynamo.NewWithClient(fake, "portfolio")
+package dynamotest
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
+)
+
+// fault 를 걸 수 있는 api 이름
+const (
+	OP_CREATE_TABLE         = "CreateTable"
+	OP_DESCRIBE_TABLE       = "DescribeTable"
+	OP_LIST_TABLES          = "ListTables"
+	OP_PUT_ITEM             = "PutItem"
+	OP_GET_ITEM             = "GetItem"
+	OP_UPDATE_ITEM          = "UpdateItem"
+	OP_DELETE_ITEM          = "DeleteItem"
+	OP_QUERY                = "Query"
+	OP_SCAN                 = "Scan"
+	OP_BATCH_WRITE_ITEM     = "BatchWriteItem"
+	OP_BATCH_GET_ITEM       = "BatchGetItem"
+	OP_TRANSACT_WRITE_ITEMS = "TransactWriteItems"
+)
+
+const (
+	max_count_batch_write_item = 25
+	max_count_batch_get_item   = 100
+	max_count_transaction_item = 100
+
+	// read capacity unit 하나로 읽을 수 있는 크기
+	read_capacity_unit_size = 4096
+
+	reason_none                   = "None"
+	reason_condition_check_failed = "ConditionalCheckFailed"
+	reason_throttling             = "ThrottlingError"
+)
+
+var _ dynamo.Client = (*Fake)(nil)
+
+// Fake 는 dynamo.Client 를 메모리에서 구현한 것
+// key 는 CreateTable 이나 AddTable 로 지정한 key schema 를 따르고, 조건, update, projection expression 을 실제로 해석해서 적용
+// 모든 요청은 하나의 lock 안에서 처리 되기 때문에 트랜잭션과 조건부 쓰기는 항상 원자적으로 동작
+type Fake struct {
+	mu     sync.Mutex
+	tables map[string]*table
+	faults map[string][]*fault
+	calls  map[string]int
+
+	// 트랜잭션 ClientRequestToken, 같은 token 은 다시 적용하지 않음
+	tokens map[string]struct{}
+}
+
+// Index 는 AddTable 로 테이블을 만들 때 같이 만들 gsi 정보, SK 는 없어도 됨
+type Index struct {
+	Name string
+	PK   string
+	SK   string
+}
+
+type keySchema struct {
+	hash  string
+	rng   string
+	index string
+}
+
+type table struct {
+	description types.TableDescription
+	key         keySchema
+	indexes     map[string]keySchema
+	items       map[string]map[string]types.AttributeValue
+}
+
+type faultKind int
+
+const (
+	fault_throttle faultKind = iota
+	fault_condition
+	fault_error
+)
+
+type fault struct {
+	kind   faultKind
+	err    error
+	remain int
+}
+
+func New() *Fake {
+	return &Fake{
+		tables: map[string]*table{},
+		faults: map[string][]*fault{},
+		calls:  map[string]int{},
+		tokens: map[string]struct{}{},
+	}
+}
+
+// AddTable 는 dynamo.CREATE_TABLE_SCHEMA 와 동일하게 pk, sk 를 key 로 하는 테이블을 만들어 줌
+// FindWithGSI 를 테스트 하려면 indexes 로 gsi 를 같이 넘겨야 함
+func (f *Fake) AddTable(name string, indexes ...Index) *Fake {
+	input := &dynamodb.CreateTableInput{
+		TableName: aws.String(name),
+		KeySchema: keySchemaElements("pk", "sk"),
+	}
+	for _, index := range indexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:  aws.String(index.Name),
+			KeySchema:  keySchemaElements(index.PK, index.SK),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+
+	if _, err := f.CreateTable(context.TODO(), input); err != nil {
+		panic(err)
+	}
+
+	return f
+}
+
+// Throttle 는 op 의 다음 times 번 요청을 처리량 초과로 실패 시킴
+// BatchWriteItem, BatchGetItem 은 오류 대신 요청 전부를 UnprocessedItems, UnprocessedKeys 로 돌려 주고
+// TransactWriteItems 는 모든 item 의 취소 사유가 ThrottlingError 인 TransactionCanceledException 을 돌려 줌
+func (f *Fake) Throttle(op string, times int) *Fake {
+	return f.addFault(op, &fault{kind: fault_throttle, remain: times})
+}
+
+// FailCondition 는 op 의 다음 times 번 요청을 실제 조건과 상관 없이 조건 실패로 만듦
+// TransactWriteItems 는 첫번째 item 의 취소 사유가 ConditionalCheckFailed 가 됨
+func (f *Fake) FailCondition(op string, times int) *Fake {
+	return f.addFault(op, &fault{kind: fault_condition, remain: times})
+}
+
+// FailWith 는 op 의 다음 times 번 요청을 err 로 실패 시킴
+func (f *Fake) FailWith(op string, times int, err error) *Fake {
+	return f.addFault(op, &fault{kind: fault_error, err: err, remain: times})
+}
+
+// Calls 는 op 가 호출된 횟수, 재시도 횟수 확인 할 때 사용
+func (f *Fake) Calls(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[op]
+}
+
+// Items 는 테이블에 저장된 item 들을 key 순서대로 복사해서 전달
+func (f *Fake) Items(tableName string) []map[string]types.AttributeValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tables[tableName]
+	if !ok {
+		return nil
+	}
+
+	var items []map[string]types.AttributeValue
+	for _, item := range t.sorted(t.key) {
+		items = append(items, cloneItem(item))
+	}
+	return items
+}
+
+func (f *Fake) addFault(op string, ft *fault) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults[op] = append(f.faults[op], ft)
+	return f
+}
+
+// begin 는 요청 횟수를 세고, 걸려 있는 fault 가 있으면 하나 꺼내 줌
+func (f *Fake) begin(c context.Context, op string) (*fault, error) {
+	f.calls[op]++
+
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+
+	faults := f.faults[op]
+	if len(faults) == 0 {
+		return nil, nil
+	}
+
+	ft := faults[0]
+	ft.remain--
+	if ft.remain <= 0 {
+		f.faults[op] = faults[1:]
+	}
+
+	return ft, nil
+}
+
+// beginSingle 는 item 하나를 다루는 요청의 시작, fault 가 있으면 바로 오류로 바꿔 줌
+func (f *Fake) beginSingle(c context.Context, op string) error {
+	ft, err := f.begin(c, op)
+	if err != nil || ft == nil {
+		return err
+	}
+
+	switch ft.kind {
+	case fault_throttle:
+		return &types.ProvisionedThroughputExceededException{Message: aws.String("The level of configured provisioned throughput for the table was exceeded.")}
+	case fault_condition:
+		return conditionFailed()
+	default:
+		return ft.err
+	}
+}
+
+func (f *Fake) table(name *string) (*table, error) {
+	t, ok := f.tables[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: Table: " + aws.ToString(name) + " not found")}
+	}
+	return t, nil
+}
+
+func (f *Fake) CreateTable(c context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_CREATE_TABLE); err != nil {
+		return nil, err
+	}
+
+	name := aws.ToString(params.TableName)
+	if _, ok := f.tables[name]; ok {
+		return nil, &types.ResourceInUseException{Message: aws.String("Table already exists: " + name)}
+	}
+
+	key, err := parseKeySchema(params.KeySchema, "")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &table{
+		key:     key,
+		indexes: map[string]keySchema{},
+		items:   map[string]map[string]types.AttributeValue{},
+		description: types.TableDescription{
+			TableName:            aws.String(name),
+			TableArn:             aws.String("arn:aws:dynamodb:local:000000000000:table/" + name),
+			TableStatus:          types.TableStatusActive,
+			KeySchema:            params.KeySchema,
+			AttributeDefinitions: params.AttributeDefinitions,
+			CreationDateTime:     aws.Time(time.Now()),
+		},
+	}
+
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		index, err := parseKeySchema(gsi.KeySchema, aws.ToString(gsi.IndexName))
+		if err != nil {
+			return nil, err
+		}
+		t.indexes[index.index] = index
+		t.description.GlobalSecondaryIndexes = append(t.description.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   gsi.IndexName,
+			KeySchema:   gsi.KeySchema,
+			Projection:  gsi.Projection,
+			IndexStatus: types.IndexStatusActive,
+		})
+	}
+	for _, lsi := range params.LocalSecondaryIndexes {
+		index, err := parseKeySchema(lsi.KeySchema, aws.ToString(lsi.IndexName))
+		if err != nil {
+			return nil, err
+		}
+		t.indexes[index.index] = index
+	}
+
+	f.tables[name] = t
+
+	description := t.description
+	return &dynamodb.CreateTableOutput{TableDescription: &description}, nil
+}
+
+func (f *Fake) DescribeTable(c context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_DESCRIBE_TABLE); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	description := t.description
+	description.ItemCount = aws.Int64(int64(len(t.items)))
+
+	return &dynamodb.DescribeTableOutput{Table: &description}, nil
+}
+
+func (f *Fake) ListTables(c context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_LIST_TABLES); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(f.tables))
+	for name := range f.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &dynamodb.ListTablesOutput{TableNames: names}, nil
+}
+
+func (f *Fake) PutItem(c context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_PUT_ITEM); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.itemKey(params.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	err = checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	t.items[key] = cloneItem(params.Item)
+
+	out := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = cloneItem(old)
+	}
+	return out, nil
+}
+
+func (f *Fake) GetItem(c context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_GET_ITEM); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.keyOf(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := parseProjection(params.ProjectionExpression, params.ExpressionAttributeNames)
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	out := &dynamodb.GetItemOutput{}
+	if item, ok := t.items[key]; ok {
+		out.Item = project(item, paths)
+	}
+	return out, nil
+}
+
+func (f *Fake) UpdateItem(c context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_UPDATE_ITEM); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.keyOf(params.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	err = checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	item, u, err := t.update(old, params.Key, params.UpdateExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	t.items[key] = item
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueAllOld:
+		out.Attributes = cloneItem(old)
+	case types.ReturnValueAllNew:
+		out.Attributes = cloneItem(item)
+	case types.ReturnValueUpdatedOld:
+		out.Attributes = updatedAttributes(old, u)
+	case types.ReturnValueUpdatedNew:
+		out.Attributes = updatedAttributes(item, u)
+	}
+	return out, nil
+}
+
+func (f *Fake) DeleteItem(c context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_DELETE_ITEM); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.keyOf(params.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	err = checkCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, old, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(t.items, key)
+
+	out := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = cloneItem(old)
+	}
+	return out, nil
+}
+
+// Query 는 key condition 을 만족하는 item 들을 sort key 순서로 전달
+// Limit 만큼 읽었는데 뒤에 item 이 더 남아 있을 때만 LastEvaluatedKey 를 넣어 줌
+// 실제 dynamo 는 1MB 단위로도 잘라서 주지만 fake 는 Limit 만 적용
+func (f *Fake) Query(c context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_QUERY); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := t.key
+	if params.IndexName != nil {
+		index, ok := t.indexes[*params.IndexName]
+		if !ok {
+			return nil, validationError(fmt.Errorf("the table does not have the specified index: %s", *params.IndexName))
+		}
+		schema = index
+	}
+
+	if params.KeyConditionExpression == nil {
+		return nil, validationError(fmt.Errorf("either the KeyConditions or KeyConditionExpression parameter must be specified in the request"))
+	}
+	keyCond, err := parseConditionExpression(params.KeyConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	filter, err := parseConditionExpression(params.FilterExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	paths, err := parseProjection(params.ProjectionExpression, params.ExpressionAttributeNames)
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	items := t.sorted(schema)
+	forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+	if !forward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	var matched []map[string]types.AttributeValue
+	for _, item := range items {
+		ok, err := keyCond(item)
+		if err != nil {
+			return nil, validationError(err)
+		}
+		if !ok {
+			continue
+		}
+		if params.ExclusiveStartKey != nil {
+			n := t.compare(schema, item, params.ExclusiveStartKey)
+			if (forward && n <= 0) || (!forward && n >= 0) {
+				continue
+			}
+		}
+		matched = append(matched, item)
+	}
+
+	out := &dynamodb.QueryOutput{}
+	if params.Limit != nil && int(*params.Limit) < len(matched) {
+		matched = matched[:*params.Limit]
+		out.LastEvaluatedKey = t.lastEvaluatedKey(schema, matched[len(matched)-1])
+	}
+
+	out.ScannedCount = int32(len(matched))
+	for _, item := range matched {
+		ok, err := filter(item)
+		if err != nil {
+			return nil, validationError(err)
+		}
+		if !ok {
+			continue
+		}
+		out.Count++
+		if params.Select != types.SelectCount {
+			out.Items = append(out.Items, project(item, paths))
+		}
+	}
+
+	return out, nil
+}
+
+// Scan 는 key 순서대로 item 을 훑음, TotalSegments 를 주면 key 의 hash 로 segment 를 나눠서 해당 segment 의 item 만 전달
+// Query 와 동일하게 Limit 만 적용하고, ReturnConsumedCapacity 를 주면 읽은 item 크기로 계산한 ConsumedCapacity 를 넣어 줌
+func (f *Fake) Scan(c context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.beginSingle(c, OP_SCAN); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := t.key
+	if params.IndexName != nil {
+		index, ok := t.indexes[*params.IndexName]
+		if !ok {
+			return nil, validationError(fmt.Errorf("the table does not have the specified index: %s", *params.IndexName))
+		}
+		schema = index
+	}
+
+	segment, total := aws.ToInt32(params.Segment), aws.ToInt32(params.TotalSegments)
+	if (params.Segment == nil) != (params.TotalSegments == nil) || (total > 0 && (segment < 0 || segment >= total)) {
+		return nil, validationError(fmt.Errorf("invalid segment, segment : %d, total segments : %d", segment, total))
+	}
+
+	filter, err := parseConditionExpression(params.FilterExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, validationError(err)
+	}
+	paths, err := parseProjection(params.ProjectionExpression, params.ExpressionAttributeNames)
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	var scanned []map[string]types.AttributeValue
+	for _, item := range t.sorted(schema) {
+		if total > 0 {
+			key, _ := t.itemKey(item)
+			h := fnv.New32a()
+			h.Write([]byte(key))
+			if int32(h.Sum32()%uint32(total)) != segment {
+				continue
+			}
+		}
+		if params.ExclusiveStartKey != nil && t.compare(schema, item, params.ExclusiveStartKey) <= 0 {
+			continue
+		}
+		scanned = append(scanned, item)
+	}
+
+	out := &dynamodb.ScanOutput{}
+	if params.Limit != nil && int(*params.Limit) < len(scanned) {
+		scanned = scanned[:*params.Limit]
+		out.LastEvaluatedKey = t.lastEvaluatedKey(schema, scanned[len(scanned)-1])
+	}
+
+	size := 0
+	out.ScannedCount = int32(len(scanned))
+	for _, item := range scanned {
+		size += itemSize(item)
+
+		ok, err := filter(item)
+		if err != nil {
+			return nil, validationError(err)
+		}
+		if !ok {
+			continue
+		}
+		out.Count++
+		if params.Select != types.SelectCount {
+			out.Items = append(out.Items, project(item, paths))
+		}
+	}
+
+	if params.ReturnConsumedCapacity != "" && params.ReturnConsumedCapacity != types.ReturnConsumedCapacityNone {
+		// eventually consistent read 는 절반만 사용
+		units := math.Ceil(float64(size) / read_capacity_unit_size)
+		if !aws.ToBool(params.ConsistentRead) {
+			units /= 2
+		}
+		out.ConsumedCapacity = &types.ConsumedCapacity{TableName: params.TableName, CapacityUnits: aws.Float64(units)}
+	}
+
+	return out, nil
+}
+
+// BatchWriteItem 는 요청 전체를 한번에 처리, Throttle 이 걸려 있으면 전부 UnprocessedItems 로 돌려 줌
+func (f *Fake) BatchWriteItem(c context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ft, err := f.begin(c, OP_BATCH_WRITE_ITEM)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for name, reqs := range params.RequestItems {
+		t, err := f.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		keys := map[string]struct{}{}
+		for _, req := range reqs {
+			var key string
+			switch {
+			case req.PutRequest != nil:
+				key, err = t.itemKey(req.PutRequest.Item)
+			case req.DeleteRequest != nil:
+				key, err = t.keyOf(req.DeleteRequest.Key)
+			default:
+				err = validationError(fmt.Errorf("write request must have put or delete request"))
+			}
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := keys[key]; ok {
+				return nil, validationError(fmt.Errorf("provided list of item keys contains duplicates"))
+			}
+			keys[key] = struct{}{}
+		}
+		count += len(reqs)
+	}
+	if count == 0 || count > max_count_batch_write_item {
+		return nil, validationError(fmt.Errorf("member must have length less than or equal to %d", max_count_batch_write_item))
+	}
+
+	if ft != nil {
+		switch ft.kind {
+		case fault_throttle:
+			return &dynamodb.BatchWriteItemOutput{UnprocessedItems: params.RequestItems}, nil
+		case fault_condition:
+			return nil, validationError(fmt.Errorf("batch write item does not support condition"))
+		default:
+			return nil, ft.err
+		}
+	}
+
+	for name, reqs := range params.RequestItems {
+		t := f.tables[name]
+		for _, req := range reqs {
+			if req.PutRequest != nil {
+				key, _ := t.itemKey(req.PutRequest.Item)
+				t.items[key] = cloneItem(req.PutRequest.Item)
+				continue
+			}
+			key, _ := t.keyOf(req.DeleteRequest.Key)
+			delete(t.items, key)
+		}
+	}
+
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{}}, nil
+}
+
+// BatchGetItem 는 있는 item 만 Responses 로 돌려 줌, Throttle 이 걸려 있으면 전부 UnprocessedKeys 로 돌려 줌
+func (f *Fake) BatchGetItem(c context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ft, err := f.begin(c, OP_BATCH_GET_ITEM)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for name, request := range params.RequestItems {
+		t, err := f.table(aws.String(name))
+		if err != nil {
+			return nil, err
+		}
+
+		keys := map[string]struct{}{}
+		for _, k := range request.Keys {
+			key, err := t.keyOf(k)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := keys[key]; ok {
+				return nil, validationError(fmt.Errorf("provided list of item keys contains duplicates"))
+			}
+			keys[key] = struct{}{}
+		}
+		count += len(request.Keys)
+	}
+	if count == 0 || count > max_count_batch_get_item {
+		return nil, validationError(fmt.Errorf("too many items requested for the BatchGetItem call"))
+	}
+
+	if ft != nil {
+		switch ft.kind {
+		case fault_throttle:
+			return &dynamodb.BatchGetItemOutput{
+				Responses:       map[string][]map[string]types.AttributeValue{},
+				UnprocessedKeys: params.RequestItems,
+			}, nil
+		case fault_condition:
+			return nil, validationError(fmt.Errorf("batch get item does not support condition"))
+		default:
+			return nil, ft.err
+		}
+	}
+
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]types.AttributeValue{},
+		UnprocessedKeys: map[string]types.KeysAndAttributes{},
+	}
+	for name, request := range params.RequestItems {
+		t := f.tables[name]
+		paths, err := parseProjection(request.ProjectionExpression, request.ExpressionAttributeNames)
+		if err != nil {
+			return nil, validationError(err)
+		}
+
+		items := []map[string]types.AttributeValue{}
+		for _, k := range request.Keys {
+			key, _ := t.keyOf(k)
+			if item, ok := t.items[key]; ok {
+				items = append(items, project(item, paths))
+			}
+		}
+		out.Responses[name] = items
+	}
+
+	return out, nil
+}
+
+// staged 는 트랜잭션에서 적용 대기 중인 item, item 이 nil 이면 삭제
+type staged struct {
+	table *table
+	key   string
+	item  map[string]types.AttributeValue
+	write bool
+}
+
+// TransactWriteItems 는 모든 조건을 먼저 확인하고, 전부 통과 했을 때만 한번에 적용
+// 하나라도 실패하면 아무것도 바뀌지 않고 item 별 사유가 담긴 TransactionCanceledException 을 돌려 줌
+func (f *Fake) TransactWriteItems(c context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ft, err := f.begin(c, OP_TRANSACT_WRITE_ITEMS)
+	if err != nil {
+		return nil, err
+	}
+
+	count := len(params.TransactItems)
+	if count == 0 || count > max_count_transaction_item {
+		return nil, validationError(fmt.Errorf("member must have length less than or equal to %d", max_count_transaction_item))
+	}
+
+	if ft != nil {
+		reasons := make([]types.CancellationReason, count)
+		switch ft.kind {
+		case fault_throttle:
+			for i := range reasons {
+				reasons[i] = types.CancellationReason{Code: aws.String(reason_throttling), Message: aws.String("Throughput exceeds the current capacity for one or more global secondary indexes.")}
+			}
+		case fault_condition:
+			for i := range reasons {
+				reasons[i] = types.CancellationReason{Code: aws.String(reason_none)}
+			}
+			reasons[0] = types.CancellationReason{Code: aws.String(reason_condition_check_failed), Message: aws.String("The conditional request failed")}
+		default:
+			return nil, ft.err
+		}
+		return nil, transactionCanceled(reasons)
+	}
+
+	token := aws.ToString(params.ClientRequestToken)
+	if _, ok := f.tokens[token]; ok && token != "" {
+		return &dynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	stages := make([]staged, 0, count)
+	reasons := make([]types.CancellationReason, count)
+	keys := map[string]struct{}{}
+	canceled := false
+
+	for i, ti := range params.TransactItems {
+		s, cond, err := f.stage(ti)
+		if err != nil {
+			return nil, err
+		}
+
+		id := aws.ToString(s.table.description.TableName) + "\x00" + s.key
+		if _, ok := keys[id]; ok {
+			return nil, validationError(fmt.Errorf("transaction request cannot include multiple operations on one item"))
+		}
+		keys[id] = struct{}{}
+
+		reasons[i] = types.CancellationReason{Code: aws.String(reason_none)}
+		if err := checkCondition(cond.expr, cond.names, cond.values, s.table.items[s.key], cond.onFailure); err != nil {
+			ccf, ok := err.(*types.ConditionalCheckFailedException)
+			if !ok {
+				return nil, err
+			}
+			reasons[i] = types.CancellationReason{Code: aws.String(reason_condition_check_failed), Message: ccf.Message, Item: ccf.Item}
+			canceled = true
+		}
+
+		stages = append(stages, s)
+	}
+
+	if canceled {
+		return nil, transactionCanceled(reasons)
+	}
+
+	for _, s := range stages {
+		if !s.write {
+			continue
+		}
+		if s.item == nil {
+			delete(s.table.items, s.key)
+			continue
+		}
+		s.table.items[s.key] = s.item
+	}
+	if token != "" {
+		f.tokens[token] = struct{}{}
+	}
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// transactCondition 는 트랜잭션 item 의 조건
+type transactCondition struct {
+	expr      *string
+	names     map[string]string
+	values    map[string]types.AttributeValue
+	onFailure types.ReturnValuesOnConditionCheckFailure
+}
+
+// stage 는 트랜잭션 item 하나를 적용했을 때의 결과를 미리 만들어 둠, 저장된 값은 건드리지 않음
+func (f *Fake) stage(ti types.TransactWriteItem) (staged, transactCondition, error) {
+	switch {
+	case ti.Put != nil:
+		t, err := f.table(ti.Put.TableName)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		key, err := t.itemKey(ti.Put.Item)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		return staged{table: t, key: key, item: cloneItem(ti.Put.Item), write: true},
+			transactCondition{ti.Put.ConditionExpression, ti.Put.ExpressionAttributeNames, ti.Put.ExpressionAttributeValues, ti.Put.ReturnValuesOnConditionCheckFailure}, nil
+
+	case ti.Update != nil:
+		t, err := f.table(ti.Update.TableName)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		key, err := t.keyOf(ti.Update.Key)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		item, _, err := t.update(t.items[key], ti.Update.Key, ti.Update.UpdateExpression, ti.Update.ExpressionAttributeNames, ti.Update.ExpressionAttributeValues)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		return staged{table: t, key: key, item: item, write: true},
+			transactCondition{ti.Update.ConditionExpression, ti.Update.ExpressionAttributeNames, ti.Update.ExpressionAttributeValues, ti.Update.ReturnValuesOnConditionCheckFailure}, nil
+
+	case ti.Delete != nil:
+		t, err := f.table(ti.Delete.TableName)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		key, err := t.keyOf(ti.Delete.Key)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		return staged{table: t, key: key, write: true},
+			transactCondition{ti.Delete.ConditionExpression, ti.Delete.ExpressionAttributeNames, ti.Delete.ExpressionAttributeValues, ti.Delete.ReturnValuesOnConditionCheckFailure}, nil
+
+	case ti.ConditionCheck != nil:
+		t, err := f.table(ti.ConditionCheck.TableName)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		key, err := t.keyOf(ti.ConditionCheck.Key)
+		if err != nil {
+			return staged{}, transactCondition{}, err
+		}
+		return staged{table: t, key: key},
+			transactCondition{ti.ConditionCheck.ConditionExpression, ti.ConditionCheck.ExpressionAttributeNames, ti.ConditionCheck.ExpressionAttributeValues, ti.ConditionCheck.ReturnValuesOnConditionCheckFailure}, nil
+	}
+
+	return staged{}, transactCondition{}, validationError(fmt.Errorf("transact write item must have one of put, update, delete, condition check"))
+}
+
+// update 는 old 에 update expression 을 적용한 새 item 을 만들어 줌, old 는 바꾸지 않음
+func (t *table) update(old, key map[string]types.AttributeValue, expr *string, names map[string]string, values map[string]types.AttributeValue) (map[string]types.AttributeValue, *updateExpression, error) {
+	item := cloneItem(old)
+	if item == nil {
+		item = cloneItem(key)
+	}
+	if expr == nil {
+		return item, &updateExpression{}, nil
+	}
+
+	u, err := parseUpdateExpression(*expr, names, values)
+	if err != nil {
+		return nil, nil, validationError(err)
+	}
+	for _, p := range u.paths {
+		if p.top() == t.key.hash || p.top() == t.key.rng {
+			return nil, nil, validationError(fmt.Errorf("cannot update attribute %s. this attribute is part of the key", p.top()))
+		}
+	}
+	if err := u.apply(item); err != nil {
+		return nil, nil, validationError(err)
+	}
+
+	return item, u, nil
+}
+
+// updatedAttributes 는 update 로 바뀐 최상위 attribute 만 골라서 전달
+func updatedAttributes(item map[string]types.AttributeValue, u *updateExpression) map[string]types.AttributeValue {
+	result := map[string]types.AttributeValue{}
+	for _, p := range u.paths {
+		if v, ok := item[p.top()]; ok {
+			result[p.top()] = clone(v)
+		}
+	}
+	return result
+}
+
+// checkCondition 는 저장된 item 이 조건을 만족하는지 확인, 만족하지 않으면 ConditionalCheckFailedException
+func checkCondition(expr *string, names map[string]string, values map[string]types.AttributeValue, item map[string]types.AttributeValue, onFailure types.ReturnValuesOnConditionCheckFailure) error {
+	cond, err := parseConditionExpression(expr, names, values)
+	if err != nil {
+		return validationError(err)
+	}
+
+	if item == nil {
+		item = map[string]types.AttributeValue{}
+	}
+	ok, err := cond(item)
+	if err != nil {
+		return validationError(err)
+	}
+	if ok {
+		return nil
+	}
+
+	ex := conditionFailed()
+	if onFailure == types.ReturnValuesOnConditionCheckFailureAllOld && len(item) > 0 {
+		ex.Item = cloneItem(item)
+	}
+	return ex
+}
+
+func conditionFailed() *types.ConditionalCheckFailedException {
+	return &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+}
+
+func transactionCanceled(reasons []types.CancellationReason) error {
+	codes := make([]string, 0, len(reasons))
+	for _, r := range reasons {
+		codes = append(codes, aws.ToString(r.Code))
+	}
+
+	return &types.TransactionCanceledException{
+		Message:             aws.String(fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for specific reasons [%s]", strings.Join(codes, ", "))),
+		CancellationReasons: reasons,
+	}
+}
+
+// validationError 는 잘못된 요청을 dynamo 와 같은 ValidationException 으로 만들어 줌
+func validationError(err error) error {
+	return &smithy.GenericAPIError{Code: "ValidationException", Message: err.Error(), Fault: smithy.FaultClient}
+}
+
+func keySchemaElements(pk, sk string) []types.KeySchemaElement {
+	elements := []types.KeySchemaElement{{AttributeName: aws.String(pk), KeyType: types.KeyTypeHash}}
+	if sk != "" {
+		elements = append(elements, types.KeySchemaElement{AttributeName: aws.String(sk), KeyType: types.KeyTypeRange})
+	}
+	return elements
+}
+
+func parseKeySchema(elements []types.KeySchemaElement, index string) (keySchema, error) {
+	key := keySchema{index: index}
+	for _, e := range elements {
+		switch e.KeyType {
+		case types.KeyTypeHash:
+			key.hash = aws.ToString(e.AttributeName)
+		case types.KeyTypeRange:
+			key.rng = aws.ToString(e.AttributeName)
+		}
+	}
+	if key.hash == "" {
+		return keySchema{}, validationError(fmt.Errorf("key schema must have hash key"))
+	}
+	return key, nil
+}
+
+// keyPart 는 key attribute 값을 map 의 key 로 쓸 수 있는 문자열로 바꿔 줌
+func keyPart(v types.AttributeValue) (string, bool) {
+	switch av := v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S" + av.Value, true
+	case *types.AttributeValueMemberN:
+		n, err := number(av.Value)
+		if err != nil {
+			return "", false
+		}
+		return "N" + n.Text('g', -1), true
+	case *types.AttributeValueMemberB:
+		return "B" + string(av.Value), true
+	}
+	return "", false
+}
+
+// itemKey 는 put 하려는 item 의 key, key attribute 가 없으면 ValidationException
+func (t *table) itemKey(item map[string]types.AttributeValue) (string, error) {
+	return t.schemaKey(t.key, item)
+}
+
+// keyOf 는 Key 파라미터의 key, key attribute 외의 값이 있어도 ValidationException
+func (t *table) keyOf(key map[string]types.AttributeValue) (string, error) {
+	expected := 1
+	if t.key.rng != "" {
+		expected = 2
+	}
+	if len(key) != expected {
+		return "", validationError(fmt.Errorf("the provided key element does not match the schema"))
+	}
+	return t.schemaKey(t.key, key)
+}
+
+func (t *table) schemaKey(schema keySchema, item map[string]types.AttributeValue) (string, error) {
+	hash, ok := keyPart(item[schema.hash])
+	if !ok {
+		return "", validationError(fmt.Errorf("the provided key element does not match the schema, missing %s", schema.hash))
+	}
+	if schema.rng == "" {
+		return hash, nil
+	}
+
+	rng, ok := keyPart(item[schema.rng])
+	if !ok {
+		return "", validationError(fmt.Errorf("the provided key element does not match the schema, missing %s", schema.rng))
+	}
+	return hash + "\x00" + rng, nil
+}
+
+// sorted 는 schema 의 key 가 있는 item 들만 골라서 key 순서대로 전달
+// gsi 는 key attribute 가 없는 item 은 index 에 안들어가는 것과 동일
+func (t *table) sorted(schema keySchema) []map[string]types.AttributeValue {
+	items := make([]map[string]types.AttributeValue, 0, len(t.items))
+	for _, item := range t.items {
+		if _, err := t.schemaKey(schema, item); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return t.compare(schema, items[i], items[j]) < 0
+	})
+
+	return items
+}
+
+// compare 는 schema 의 hash, range key 순서로 비교하고, gsi 처럼 key 가 겹칠 수 있으면 테이블 key 로 한번 더 비교
+func (t *table) compare(schema keySchema, a, b map[string]types.AttributeValue) int {
+	ah, _ := keyPart(a[schema.hash])
+	bh, _ := keyPart(b[schema.hash])
+	if n := strings.Compare(ah, bh); n != 0 {
+		return n
+	}
+
+	if schema.rng != "" {
+		if n, ok := compare(a[schema.rng], b[schema.rng]); ok && n != 0 {
+			return n
+		}
+	}
+
+	if schema.index == "" {
+		return 0
+	}
+
+	ak, _ := t.schemaKey(t.key, a)
+	bk, _ := t.schemaKey(t.key, b)
+	return strings.Compare(ak, bk)
+}
+
+// lastEvaluatedKey 는 다음 페이지를 이어서 조회 할 수 있도록 테이블 key 와 index key 를 담아 줌
+func (t *table) lastEvaluatedKey(schema keySchema, item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	key := map[string]types.AttributeValue{}
+	for _, name := range []string{t.key.hash, t.key.rng, schema.hash, schema.rng} {
+		if v, ok := item[name]; ok && name != "" {
+			key[name] = clone(v)
+		}
+	}
+	return key
+}
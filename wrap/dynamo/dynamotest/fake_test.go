@@ -0,0 +1,326 @@
+package dynamotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	test_table_name         = "portfolio-test"
+	test_success_msg_format = "[%s] success"
+)
+
+type testItem struct {
+	PK      string `dynamodbav:"pk"`
+	SK      string `dynamodbav:"sk"`
+	Val     string `dynamodbav:"val"`
+	Nick    string `dynamodbav:"nick,omitempty"`
+	Count   int64  `dynamodbav:"count"`
+	Version int64  `dynamodbav:"version"`
+}
+
+func (i testItem) Key() (string, string) {
+	return i.PK, i.SK
+}
+
+func newTestTable() (*Fake, dynamo.TableBasics) {
+	fake := New().AddTable(test_table_name, Index{Name: "nick-index", PK: "nick"})
+	return fake, dynamo.NewWithClient(fake, test_table_name)
+}
+
+// Test_PutAndFind 는 put 한 item 을 pk, begins_with, gsi 로 조회하는 기능 검사
+func Test_PutAndFind(t *testing.T) {
+	c := context.TODO()
+	_, table := newTestTable()
+
+	for i := 0; i < 5; i++ {
+		err := table.PutItem(c, testItem{PK: "pk", SK: fmt.Sprintf("log#%d", i), Val: "val"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := table.PutItem(c, testItem{PK: "pk", SK: "profile", Nick: "헤롱"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var one testItem
+	err = table.MustFindOne(c, "pk", "log#3", &one)
+	if err != nil || one.Val != "val" {
+		t.Fatalf("find one failed, %v, %v", one, err)
+	}
+	err = table.MustFindOne(c, "pk", "none", &one)
+	if !errors.Is(err, common.ErrorNotFountItem) {
+		t.Fatalf("not exists item must be not found, %v", err)
+	}
+
+	var logs []testItem
+	cursor, err := table.FindBeginsWithPaging(c, "pk", "log#", "", 3, &logs)
+	if err != nil || len(logs) != 3 || cursor == "" {
+		t.Fatalf("first page failed, %v, %s, %v", logs, cursor, err)
+	}
+	cursor, err = table.FindBeginsWithPaging(c, "pk", "log#", cursor, 3, &logs)
+	if err != nil || len(logs) != 2 || cursor != "" || logs[1].SK != "log#4" {
+		t.Fatalf("last page failed, %v, %s, %v", logs, cursor, err)
+	}
+
+	expr, err := expression.NewBuilder().WithKeyCondition(expression.Key("nick").Equal(expression.Value("헤롱"))).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var profiles []testItem
+	err = table.FindWithGSI(c, "nick-index", expr, &profiles)
+	if err != nil || len(profiles) != 1 || profiles[0].SK != "profile" {
+		t.Fatalf("find with gsi failed, %v, %v", profiles, err)
+	}
+
+	err = table.DeleteItem(c, "pk", "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles = nil
+	err = table.FindWithGSI(c, "nick-index", expr, &profiles)
+	if err != nil || len(profiles) != 0 {
+		t.Fatalf("deleted item must not be found, %v, %v", profiles, err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_ConditionAndUpdate 는 조건부 쓰기, version, update expression 이 실제 dynamo 처럼 동작하는지 검사
+func Test_ConditionAndUpdate(t *testing.T) {
+	c := context.TODO()
+	fake, table := newTestTable()
+	versioned := table.WithVersion("version")
+
+	item := &testItem{PK: "pk", SK: "sk", Val: "first"}
+	err := versioned.PutItem(c, item)
+	if err != nil || item.Version != 1 {
+		t.Fatalf("first put failed, %v, %v", item, err)
+	}
+	stale := testItem{PK: "pk", SK: "sk", Val: "stale"}
+	err = versioned.PutItem(c, &stale)
+	if !errors.Is(err, common.ErrorConditionCheckFailed) {
+		t.Fatalf("stale put must be failed, %v", err)
+	}
+
+	var updated testItem
+	err = versioned.UpdateItem(c, "pk", "sk", dynamo.NewUpdate().Set("val", "second").Increment("count", 3).ExpectVersion(1), &updated)
+	if err != nil || updated.Val != "second" || updated.Count != 3 || updated.Version != 2 {
+		t.Fatalf("update failed, %v, %v", updated, err)
+	}
+	err = versioned.UpdateItem(c, "pk", "sk", dynamo.NewUpdate().Set("val", "third").ExpectVersion(1), nil)
+	if !errors.Is(err, common.ErrorConditionCheckFailed) {
+		t.Fatalf("update with old version must be failed, %v", err)
+	}
+
+	err = table.PutItemIfNotExists(c, testItem{PK: "pk", SK: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.FailCondition(OP_PUT_ITEM, 1)
+	err = table.PutItem(c, testItem{PK: "pk", SK: "forced"})
+	if !errors.Is(err, common.ErrorConditionCheckFailed) {
+		t.Fatalf("forced condition failure must be failed, %v", err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_TransactionRollback 는 트랜잭션 중 하나라도 조건이 실패하면 아무것도 반영되지 않는지 검사
+func Test_TransactionRollback(t *testing.T) {
+	c := context.TODO()
+	fake, table := newTestTable()
+
+	err := table.PutItem(c, testItem{PK: "pk", SK: "exists"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dynamo.NewTransaction().
+		Put(table, testItem{PK: "pk", SK: "tx#1"}).
+		Update(table, "pk", "counter", dynamo.NewUpdate().Increment("count", 1)).
+		PutIfNotExists(table, testItem{PK: "pk", SK: "exists"}).
+		Commit(c)
+
+	var txErr *dynamo.TransactionError
+	if !errors.As(err, &txErr) || len(txErr.Reasons) != 1 || txErr.Reasons[0].Index != 2 {
+		t.Fatalf("transaction must be canceled by third item, %v", err)
+	}
+	if !errors.Is(err, common.ErrorConditionCheckFailed) {
+		t.Fatalf("transaction error must be condition check failed, %v", err)
+	}
+	if items := fake.Items(test_table_name); len(items) != 1 {
+		t.Fatalf("canceled transaction must not write, %v", items)
+	}
+
+	err = dynamo.NewTransaction().
+		Put(table, testItem{PK: "pk", SK: "tx#1"}).
+		Update(table, "pk", "counter", dynamo.NewUpdate().Increment("count", 1)).
+		Delete(table, "pk", "exists").
+		Commit(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := fake.Items(test_table_name); len(items) != 2 {
+		t.Fatalf("committed transaction must write, %v", items)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_BatchWithThrottle 는 처리량 초과로 UnprocessedItems 가 와도 재시도 해서 모두 처리 되는지 검사
+func Test_BatchWithThrottle(t *testing.T) {
+	c := context.TODO()
+	fake, table := newTestTable()
+
+	var items []testItem
+	var keys []dynamo.Key
+	for i := 0; i < 30; i++ {
+		items = append(items, testItem{PK: "pk", SK: fmt.Sprintf("batch#%02d", i)})
+		keys = append(keys, dynamo.Key{PK: "pk", SK: fmt.Sprintf("batch#%02d", i)})
+	}
+	keys = append(keys, dynamo.Key{PK: "pk", SK: "none"})
+
+	fake.Throttle(OP_BATCH_WRITE_ITEM, 2)
+	err := table.PutItemsWithBatch(c, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 25개, 5개 두 번 요청 하는데 throttle 로 두 번 더 요청
+	if calls := fake.Calls(OP_BATCH_WRITE_ITEM); calls != 4 {
+		t.Fatalf("batch write must be retried, calls : %d", calls)
+	}
+
+	fake.Throttle(OP_BATCH_GET_ITEM, 1)
+	var found []testItem
+	missing, err := table.BatchGet(c, keys, &found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 30 || len(missing) != 1 || missing[0].SK != "none" {
+		t.Fatalf("batch get failed, found : %d, missing : %v", len(found), missing)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_Expression 는 expression builder 로 만든 조건식과 update 식을 해석하는 기능 검사
+func Test_Expression(t *testing.T) {
+	c := context.TODO()
+	_, table := newTestTable()
+
+	err := table.PutItem(c, testItem{PK: "pk", SK: "sk", Val: "hello world", Count: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conds := []struct {
+		cond expression.ConditionBuilder
+		ok   bool
+	}{
+		{expression.Name("count").Between(expression.Value(5), expression.Value(10)), true},
+		{expression.Name("count").GreaterThan(expression.Value(10)), false},
+		{expression.Name("val").In(expression.Value("a"), expression.Value("hello world")), true},
+		{expression.Contains(expression.Name("val"), "world").And(expression.BeginsWith(expression.Name("val"), "hello")), true},
+		{expression.Name("nick").NotEqual(expression.Value("헤롱")), true},
+		{expression.Not(expression.AttributeExists(expression.Name("pk"))).Or(expression.Name("val").Size().Equal(expression.Value(11))), true},
+		{expression.AttributeType(expression.Name("count"), expression.String), false},
+	}
+	for i, cond := range conds {
+		err := table.UpdateItemWithCondition(c, "pk", "sk", expression.Set(expression.Name("checked"), expression.Value(i)), cond.cond)
+		if cond.ok != (err == nil) {
+			t.Fatalf("condition %d must be %v, %v", i, cond.ok, err)
+		}
+	}
+
+	var updated map[string]interface{}
+	err = table.UpdateItem(c, "pk", "sk", dynamo.NewUpdate().
+		Add("tags", []string{"a", "b"}).
+		AppendList("history", []string{"login"}).
+		SetIfNotExists("nick", "헤롱").
+		Remove("checked"), &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := updated["checked"]; ok || updated["nick"] != "헤롱" || len(updated["history"].([]interface{})) != 1 {
+		t.Fatalf("update expression failed, %v", updated)
+	}
+
+	updated = nil
+	err = table.UpdateItem(c, "pk", "sk", dynamo.NewUpdate().Delete("tags", []string{"a", "b"}), &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := updated["tags"]; ok {
+		t.Fatalf("empty set must be removed, %v", updated)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_Scan 는 segment 를 나눠서 scan 해도 모든 item 을 한번씩만 받는지, filter, projection 이 적용 되는지 검사
+func Test_Scan(t *testing.T) {
+	c := context.TODO()
+	_, table := newTestTable()
+
+	var items []testItem
+	for i := 0; i < 200; i++ {
+		items = append(items, testItem{PK: fmt.Sprintf("user#%d", i%7), SK: fmt.Sprintf("log#%03d", i), Val: "val", Count: int64(i)})
+	}
+	err := table.PutItemsWithBatch(c, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]int{}
+	scan := dynamo.NewScan().Segments(4).PageSize(10).ReadCapacity(1000).
+		Filter(expression.Name("count").GreaterThanEqual(expression.Value(50))).
+		Project("pk", "sk")
+	err = dynamo.NewRepository[testItem](table).ScanEach(c, scan, func(item testItem) error {
+		if item.Val != "" {
+			return fmt.Errorf("projection must be applied, %v", item)
+		}
+		seen[item.SK]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 150 {
+		t.Fatalf("scan must find 150 items, %d", len(seen))
+	}
+	for sk, n := range seen {
+		if n != 1 {
+			t.Fatalf("item must be scanned once, %s : %d", sk, n)
+		}
+	}
+
+	// callback 이 오류를 주면 멈추고 그 오류를 전달
+	stop := errors.New("stop")
+	err = table.ScanEach(c, dynamo.NewScan().Segments(2).PageSize(5), func(item map[string]types.AttributeValue) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("callback error must be returned, %v", err)
+	}
+
+	scanned, errc := table.ScanChan(c, nil)
+	count := 0
+	for range scanned {
+		count++
+	}
+	if err := <-errc; err != nil || count != 200 {
+		t.Fatalf("scan chan failed, count : %d, %v", count, err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
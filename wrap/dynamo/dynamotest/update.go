@@ -0,0 +1,317 @@
+package dynamotest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// updateAction 는 update expression 의 SET, REMOVE, ADD, DELETE 하나
+// 값은 dynamo 와 동일하게 update 전의 item(old) 기준으로 계산
+type updateAction func(old, item map[string]types.AttributeValue) error
+
+// updateExpression 는 해석한 update expression, paths 는 UPDATED_OLD, UPDATED_NEW 에서 돌려 줄 attribute 경로
+type updateExpression struct {
+	actions []updateAction
+	paths   []path
+}
+
+// apply 는 item 에 update 를 적용
+func (u *updateExpression) apply(item map[string]types.AttributeValue) error {
+	old := cloneItem(item)
+	for _, action := range u.actions {
+		if err := action(old, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseUpdateExpression 는 update expression 문자열을 해석
+// expression.Builder 는 절 마다 줄바꿈을 넣어서 만들어 주지만, 직접 쓴 한 줄 짜리 식도 읽을 수 있음
+func parseUpdateExpression(expr string, names map[string]string, values map[string]types.AttributeValue) (*updateExpression, error) {
+	p, err := newParser(expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &updateExpression{}
+	for p.peek().kind != token_eof {
+		clause := strings.ToUpper(p.next().text)
+		switch clause {
+		case "SET", "REMOVE", "ADD", "DELETE":
+		default:
+			return nil, fmt.Errorf("invalid update expression, unknown clause %q", clause)
+		}
+
+		for {
+			target, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			u.paths = append(u.paths, target)
+
+			action, err := p.parseAction(clause, target)
+			if err != nil {
+				return nil, err
+			}
+			u.actions = append(u.actions, action)
+
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if len(u.actions) == 0 {
+		return nil, fmt.Errorf("invalid update expression, empty expression")
+	}
+
+	return u, nil
+}
+
+func (p *parser) parseAction(clause string, target path) (updateAction, error) {
+	switch clause {
+	case "REMOVE":
+		return func(old, item map[string]types.AttributeValue) error {
+			removePath(item, target)
+			return nil
+		}, nil
+
+	case "SET":
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.parseSetValue()
+		if err != nil {
+			return nil, err
+		}
+		return func(old, item map[string]types.AttributeValue) error {
+			v, err := value(old)
+			if err != nil {
+				return err
+			}
+			return setPath(item, target, v, false)
+		}, nil
+	}
+
+	if p.peek().kind != token_value {
+		return nil, fmt.Errorf("invalid update expression, %s needs attribute value", clause)
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if clause == "ADD" {
+		return func(old, item map[string]types.AttributeValue) error {
+			current, ok := getPath(old, target)
+			if !ok {
+				return setPath(item, target, clone(value), false)
+			}
+			v, err := add(current, value)
+			if err != nil {
+				return err
+			}
+			return setPath(item, target, v, false)
+		}, nil
+	}
+
+	return func(old, item map[string]types.AttributeValue) error {
+		current, ok := getPath(old, target)
+		if !ok {
+			return nil
+		}
+		if typeOf(current) != typeOf(value) {
+			return fmt.Errorf("an operand in the update expression has an incorrect data type, %s", target)
+		}
+
+		var remain []types.AttributeValue
+		removes := setElements(value)
+		for _, e := range setElements(current) {
+			if indexOf(removes, e) < 0 {
+				remain = append(remain, e)
+			}
+		}
+		// 빈 set 은 저장할 수 없어서 attribute 를 지움
+		if len(remain) == 0 {
+			removePath(item, target)
+			return nil
+		}
+		return setPath(item, target, newSet(typeOf(current), remain), false)
+	}, nil
+}
+
+// setValue 는 SET 의 오른쪽 값, update 전 item 을 기준으로 계산
+type setValue func(old map[string]types.AttributeValue) (types.AttributeValue, error)
+
+// parseSetValue 는 operand [+|- operand]
+func (p *parser) parseSetValue() (setValue, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	var op string
+	switch {
+	case p.symbol("+"):
+		op = "+"
+	case p.symbol("-"):
+		op = "-"
+	default:
+		return left, nil
+	}
+
+	right, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(old map[string]types.AttributeValue) (types.AttributeValue, error) {
+		a, err := left(old)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right(old)
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic(a, b, op)
+	}, nil
+}
+
+// parseSetOperand 는 path, :value, if_not_exists(path, value), list_append(value, value)
+func (p *parser) parseSetOperand() (setValue, error) {
+	switch {
+	case p.isFunction("if_not_exists"):
+		p.next()
+		p.next()
+		target, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(old map[string]types.AttributeValue) (types.AttributeValue, error) {
+			if v, ok := getPath(old, target); ok {
+				return clone(v), nil
+			}
+			return fallback(old)
+		}, nil
+
+	case p.isFunction("list_append"):
+		p.next()
+		p.next()
+		first, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		second, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(old map[string]types.AttributeValue) (types.AttributeValue, error) {
+			a, err := first(old)
+			if err != nil {
+				return nil, err
+			}
+			b, err := second(old)
+			if err != nil {
+				return nil, err
+			}
+			al, ok1 := a.(*types.AttributeValueMemberL)
+			bl, ok2 := b.(*types.AttributeValueMemberL)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("an operand in the update expression has an incorrect data type, list_append needs list")
+			}
+			l := make([]types.AttributeValue, 0, len(al.Value)+len(bl.Value))
+			for _, v := range append(append([]types.AttributeValue{}, al.Value...), bl.Value...) {
+				l = append(l, clone(v))
+			}
+			return &types.AttributeValueMemberL{Value: l}, nil
+		}, nil
+
+	case p.peek().kind == token_value:
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return func(map[string]types.AttributeValue) (types.AttributeValue, error) {
+			return clone(v), nil
+		}, nil
+	}
+
+	target, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	return func(old map[string]types.AttributeValue) (types.AttributeValue, error) {
+		v, ok := getPath(old, target)
+		if !ok {
+			return nil, fmt.Errorf("the provided expression refers to an attribute that does not exist in the item, %s", target)
+		}
+		return clone(v), nil
+	}, nil
+}
+
+// arithmetic 는 SET 에서 숫자 더하기, 빼기
+func arithmetic(a, b types.AttributeValue, op string) (types.AttributeValue, error) {
+	an, ok1 := a.(*types.AttributeValueMemberN)
+	bn, ok2 := b.(*types.AttributeValueMemberN)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type, %s needs number", op)
+	}
+
+	x, err := number(an.Value)
+	if err != nil {
+		return nil, err
+	}
+	y, err := number(bn.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if op == "-" {
+		y.Neg(y)
+	}
+
+	return &types.AttributeValueMemberN{Value: x.Add(x, y).Text('f', -1)}, nil
+}
+
+// add 는 ADD 절의 동작, 숫자면 더하고 set 이면 합집합
+func add(current, value types.AttributeValue) (types.AttributeValue, error) {
+	if _, ok := current.(*types.AttributeValueMemberN); ok {
+		return arithmetic(current, value, "+")
+	}
+
+	switch current.(type) {
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		if typeOf(current) != typeOf(value) {
+			break
+		}
+		elements := setElements(current)
+		for _, e := range setElements(value) {
+			if indexOf(elements, e) < 0 {
+				elements = append(elements, e)
+			}
+		}
+		return newSet(typeOf(current), elements), nil
+	}
+
+	return nil, fmt.Errorf("an operand in the update expression has an incorrect data type, ADD needs number or set")
+}
@@ -0,0 +1,469 @@
+package dynamotest
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// getPath 는 item 에서 경로에 해당 하는 값을 꺼냄
+func getPath(item map[string]types.AttributeValue, target path) (types.AttributeValue, bool) {
+	v, ok := item[target.top()]
+	if !ok {
+		return nil, false
+	}
+
+	for _, e := range target[1:] {
+		switch av := v.(type) {
+		case *types.AttributeValueMemberM:
+			if e.list {
+				return nil, false
+			}
+			if v, ok = av.Value[e.name]; !ok {
+				return nil, false
+			}
+		case *types.AttributeValueMemberL:
+			if !e.list || e.index >= len(av.Value) {
+				return nil, false
+			}
+			v = av.Value[e.index]
+		default:
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// setPath 는 item 의 경로에 값을 넣음, 중간 경로가 없으면 오류
+// create 가 true 이면 중간 경로를 만들어 주고, index 가 -1 이거나 list 길이를 넘으면 뒤에 붙임
+func setPath(item map[string]types.AttributeValue, target path, value types.AttributeValue, create bool) error {
+	if len(target) == 1 {
+		item[target.top()] = value
+		return nil
+	}
+
+	parent, ok := item[target.top()]
+	if !ok {
+		if !create {
+			return fmt.Errorf("the document path provided in the update expression is invalid for update, %s", target)
+		}
+		parent = emptyContainer(target[1])
+	}
+
+	child, err := setChild(parent, target[1:], value, create)
+	if err != nil {
+		return err
+	}
+	item[target.top()] = child
+
+	return nil
+}
+
+func setChild(parent types.AttributeValue, target path, value types.AttributeValue, create bool) (types.AttributeValue, error) {
+	e := target[0]
+
+	switch av := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if e.list {
+			break
+		}
+		if len(target) == 1 {
+			av.Value[e.name] = value
+			return av, nil
+		}
+		next, ok := av.Value[e.name]
+		if !ok {
+			if !create {
+				return nil, fmt.Errorf("the document path provided in the update expression is invalid for update, %s", target)
+			}
+			next = emptyContainer(target[1])
+		}
+		child, err := setChild(next, target[1:], value, create)
+		if err != nil {
+			return nil, err
+		}
+		av.Value[e.name] = child
+		return av, nil
+
+	case *types.AttributeValueMemberL:
+		if !e.list {
+			break
+		}
+		index := e.index
+		// list 길이를 넘는 index 로 SET 하면 dynamo 도 뒤에 붙여 줌
+		if index < 0 || index >= len(av.Value) {
+			if len(target) > 1 && !create {
+				return nil, fmt.Errorf("the document path provided in the update expression is invalid for update, %s", target)
+			}
+			var next types.AttributeValue = value
+			if len(target) > 1 {
+				child, err := setChild(emptyContainer(target[1]), target[1:], value, create)
+				if err != nil {
+					return nil, err
+				}
+				next = child
+			}
+			av.Value = append(av.Value, next)
+			return av, nil
+		}
+		if len(target) == 1 {
+			av.Value[index] = value
+			return av, nil
+		}
+		child, err := setChild(av.Value[index], target[1:], value, create)
+		if err != nil {
+			return nil, err
+		}
+		av.Value[index] = child
+		return av, nil
+	}
+
+	return nil, fmt.Errorf("the document path provided in the update expression is invalid for update, %s", target)
+}
+
+// emptyContainer 는 경로의 다음 단계에 맞는 빈 map 이나 list 를 만들어 줌
+func emptyContainer(e pathElement) types.AttributeValue {
+	if e.list {
+		return &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+	}
+	return &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+}
+
+// removePath 는 item 에서 경로에 해당 하는 값을 지움, 없으면 아무것도 안함
+func removePath(item map[string]types.AttributeValue, target path) {
+	if len(target) == 1 {
+		delete(item, target.top())
+		return
+	}
+
+	parent, ok := getPath(item, target[:len(target)-1])
+	if !ok {
+		return
+	}
+
+	last := target[len(target)-1]
+	switch av := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if !last.list {
+			delete(av.Value, last.name)
+		}
+	case *types.AttributeValueMemberL:
+		if last.list && last.index < len(av.Value) {
+			av.Value = append(av.Value[:last.index], av.Value[last.index+1:]...)
+		}
+	}
+}
+
+// cloneItem 는 저장된 item 을 밖에서 바꿔도 영향이 없도록 깊은 복사
+func cloneItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+
+	result := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		result[k] = clone(v)
+	}
+	return result
+}
+
+func clone(v types.AttributeValue) types.AttributeValue {
+	switch av := v.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: av.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: av.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: bytes.Clone(av.Value)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: av.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: av.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string{}, av.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string{}, av.Value...)}
+	case *types.AttributeValueMemberBS:
+		bs := make([][]byte, 0, len(av.Value))
+		for _, b := range av.Value {
+			bs = append(bs, bytes.Clone(b))
+		}
+		return &types.AttributeValueMemberBS{Value: bs}
+	case *types.AttributeValueMemberL:
+		l := make([]types.AttributeValue, 0, len(av.Value))
+		for _, e := range av.Value {
+			l = append(l, clone(e))
+		}
+		return &types.AttributeValueMemberL{Value: l}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: cloneItem(av.Value)}
+	}
+
+	return v
+}
+
+// typeOf 는 attribute_type 에서 사용하는 타입 이름
+func typeOf(v types.AttributeValue) string {
+	switch v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	}
+
+	return ""
+}
+
+// number 는 N 타입 값을 정밀도 손실 없이 비교하기 위해 big.Float 로 바꿈
+func number(s string) (*big.Float, error) {
+	f, _, err := big.ParseFloat(s, 10, 128, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number value, %s, %w", s, err)
+	}
+	return f, nil
+}
+
+// compare 는 S, N, B 타입 값의 크기를 비교, 타입이 다르거나 비교할 수 없으면 false
+func compare(a, b types.AttributeValue) (int, bool) {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS:
+		if bv, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(av.Value, bv.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if bv, ok := b.(*types.AttributeValueMemberN); ok {
+			x, err1 := number(av.Value)
+			y, err2 := number(bv.Value)
+			if err1 != nil || err2 != nil {
+				return 0, false
+			}
+			return x.Cmp(y), true
+		}
+	case *types.AttributeValueMemberB:
+		if bv, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(av.Value, bv.Value), true
+		}
+	}
+
+	return 0, false
+}
+
+// equal 는 두 값이 같은지 확인, set 은 순서와 상관 없이 비교
+func equal(a, b types.AttributeValue) bool {
+	if n, ok := compare(a, b); ok {
+		return n == 0
+	}
+
+	switch av := a.(type) {
+	case *types.AttributeValueMemberBOOL:
+		bv, ok := b.(*types.AttributeValueMemberBOOL)
+		return ok && av.Value == bv.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := b.(*types.AttributeValueMemberNULL)
+		return ok
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		if typeOf(a) != typeOf(b) {
+			return false
+		}
+		x, y := setElements(a), setElements(b)
+		if len(x) != len(y) {
+			return false
+		}
+		for _, e := range x {
+			if indexOf(y, e) < 0 {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberL:
+		bv, ok := b.(*types.AttributeValueMemberL)
+		if !ok || len(av.Value) != len(bv.Value) {
+			return false
+		}
+		for i := range av.Value {
+			if !equal(av.Value[i], bv.Value[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberM:
+		bv, ok := b.(*types.AttributeValueMemberM)
+		if !ok || len(av.Value) != len(bv.Value) {
+			return false
+		}
+		for k, v := range av.Value {
+			other, ok := bv.Value[k]
+			if !ok || !equal(v, other) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// setElements 는 set 의 원소들을 하나씩 비교할 수 있게 단일 값으로 풀어 줌
+func setElements(v types.AttributeValue) []types.AttributeValue {
+	var elements []types.AttributeValue
+
+	switch av := v.(type) {
+	case *types.AttributeValueMemberSS:
+		for _, s := range av.Value {
+			elements = append(elements, &types.AttributeValueMemberS{Value: s})
+		}
+	case *types.AttributeValueMemberNS:
+		for _, n := range av.Value {
+			elements = append(elements, &types.AttributeValueMemberN{Value: n})
+		}
+	case *types.AttributeValueMemberBS:
+		for _, b := range av.Value {
+			elements = append(elements, &types.AttributeValueMemberB{Value: b})
+		}
+	}
+
+	return elements
+}
+
+// newSet 는 단일 값 원소들로 set 타입 값을 만들어 줌, setType 은 SS, NS, BS
+func newSet(setType string, elements []types.AttributeValue) types.AttributeValue {
+	switch setType {
+	case "SS":
+		ss := make([]string, 0, len(elements))
+		for _, e := range elements {
+			ss = append(ss, e.(*types.AttributeValueMemberS).Value)
+		}
+		sort.Strings(ss)
+		return &types.AttributeValueMemberSS{Value: ss}
+	case "NS":
+		ns := make([]string, 0, len(elements))
+		for _, e := range elements {
+			ns = append(ns, e.(*types.AttributeValueMemberN).Value)
+		}
+		return &types.AttributeValueMemberNS{Value: ns}
+	default:
+		bs := make([][]byte, 0, len(elements))
+		for _, e := range elements {
+			bs = append(bs, e.(*types.AttributeValueMemberB).Value)
+		}
+		return &types.AttributeValueMemberBS{Value: bs}
+	}
+}
+
+func indexOf(elements []types.AttributeValue, v types.AttributeValue) int {
+	for i, e := range elements {
+		if equal(e, v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sizeOf 는 size 함수의 결과, 문자열과 binary 는 길이, set, list, map 은 원소 수
+func sizeOf(v types.AttributeValue) (int, bool) {
+	switch av := v.(type) {
+	case *types.AttributeValueMemberS:
+		return len(av.Value), true
+	case *types.AttributeValueMemberB:
+		return len(av.Value), true
+	case *types.AttributeValueMemberSS:
+		return len(av.Value), true
+	case *types.AttributeValueMemberNS:
+		return len(av.Value), true
+	case *types.AttributeValueMemberBS:
+		return len(av.Value), true
+	case *types.AttributeValueMemberL:
+		return len(av.Value), true
+	case *types.AttributeValueMemberM:
+		return len(av.Value), true
+	}
+
+	return 0, false
+}
+
+func beginsWith(v, prefix types.AttributeValue) bool {
+	switch av := v.(type) {
+	case *types.AttributeValueMemberS:
+		p, ok := prefix.(*types.AttributeValueMemberS)
+		return ok && strings.HasPrefix(av.Value, p.Value)
+	case *types.AttributeValueMemberB:
+		p, ok := prefix.(*types.AttributeValueMemberB)
+		return ok && bytes.HasPrefix(av.Value, p.Value)
+	}
+
+	return false
+}
+
+// contains 는 문자열이면 부분 문자열, set 이나 list 면 원소로 가지고 있는지 확인
+func contains(v, element types.AttributeValue) bool {
+	switch av := v.(type) {
+	case *types.AttributeValueMemberS:
+		e, ok := element.(*types.AttributeValueMemberS)
+		return ok && strings.Contains(av.Value, e.Value)
+	case *types.AttributeValueMemberB:
+		e, ok := element.(*types.AttributeValueMemberB)
+		return ok && bytes.Contains(av.Value, e.Value)
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		return indexOf(setElements(v), element) >= 0
+	case *types.AttributeValueMemberL:
+		return indexOf(av.Value, element) >= 0
+	}
+
+	return false
+}
+
+// itemSize 는 consumed capacity 계산에 사용할 item 크기, attribute 이름과 값의 길이를 더한 대략적인 값
+func itemSize(item map[string]types.AttributeValue) int {
+	size := 0
+	for name, v := range item {
+		size += len(name) + valueSize(v)
+	}
+	return size
+}
+
+func valueSize(v types.AttributeValue) int {
+	switch av := v.(type) {
+	case *types.AttributeValueMemberS:
+		return len(av.Value)
+	case *types.AttributeValueMemberN:
+		return len(av.Value)
+	case *types.AttributeValueMemberB:
+		return len(av.Value)
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, e := range av.Value {
+			size += 1 + valueSize(e)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(av.Value)
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		size := 0
+		for _, e := range setElements(v) {
+			size += valueSize(e)
+		}
+		return size
+	}
+	return 1
+}
@@ -0,0 +1,255 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Item 은 Repository 에 넣을 구조체가 구현을 해야 하는 interface
+// pk, sk 를 구조체 스스로 알려 주게 해서 밖에서 key 를 따로 조립하지 않도록 함
+type Item interface {
+	Key() (pk, sk string)
+}
+
+// Repository 는 TableBasics 를 감싸서 interface{} 대신 T 타입으로 주고 받기 위함
+// sliceObj 를 잘 못 넘겨서 unmarshal 에서 터지던 것을 컴파일 할 때 잡을 수 있음
+type Repository[T Item] struct {
+	table TableBasics
+}
+
+// NewRepository 는 T 타입 전용 repository 를 생성
+// ex) dynamo.NewRepository[model.Account](dynamo.NewDefault())
+func NewRepository[T Item](table TableBasics) Repository[T] {
+	return Repository[T]{table: table}
+}
+
+// Table 은 repository 가 사용하는 TableBasics 를 전달, repository 에 없는 기능을 쓸 때 사용
+func (r Repository[T]) Table() TableBasics {
+	return r.table
+}
+
+// marshal 는 item 을 marshaling 하고 Key() 에서 받은 pk, sk 를 채워 넣음
+// 구조체에 pk, sk 필드가 없어도 Key() 만 구현하면 저장이 되게 하기 위함
+func (r Repository[T]) marshal(item T) (map[string]types.AttributeValue, error) {
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, fmt.Errorf("attribute marshal map failed, %w", err)
+	}
+
+	pk, sk := item.Key()
+	if pk == "" {
+		return nil, fmt.Errorf("invalid item, pk is empty, item : %v", item)
+	}
+	// MustFindOne 과 동일하게 sk 가 없으면 # 으로 저장
+	if sk == "" {
+		sk = "#"
+	}
+	av["pk"] = &types.AttributeValueMemberS{Value: pk}
+	av["sk"] = &types.AttributeValueMemberS{Value: sk}
+
+	return av, nil
+}
+
+// unmarshalList 는 query 결과를 []T 로 바인딩
+func (r Repository[T]) unmarshalList(items []map[string]types.AttributeValue) ([]T, error) {
+	list := make([]T, 0, len(items))
+	err := attributevalue.UnmarshalListOfMaps(items, &list)
+	if err != nil {
+		return nil, fmt.Errorf("attributevalue unmarshallistofmaps failed, err : %w", err)
+	}
+
+	return list, nil
+}
+
+// Get 는 pk, sk 로 하나의 item 을 조회, 없으면 common.ErrorNotFountItem
+func (r Repository[T]) Get(c context.Context, pk, sk string) (T, error) {
+	var item T
+	err := r.table.MustFindOne(c, pk, sk, &item)
+
+	return item, err
+}
+
+// Put 는 item 을 upsert 하고 저장된 item 을 전달
+// version 을 사용하는 테이블이면 올라간 version 이 반영 되어 있어서, 전달 받은 item 으로 다시 Put 하면 됨
+func (r Repository[T]) Put(c context.Context, item T) (T, error) {
+	av, err := r.marshal(item)
+	if err != nil {
+		return item, err
+	}
+
+	if err := r.table.putItem(c, av, nil); err != nil {
+		return item, err
+	}
+	return r.stored(item, av)
+}
+
+// PutIfNotExists 는 같은 key 의 item 이 없을 때만 생성하고 저장된 item 을 전달, 이미 있으면 common.ErrorConditionCheckFailed
+func (r Repository[T]) PutIfNotExists(c context.Context, item T) (T, error) {
+	av, err := r.marshal(item)
+	if err != nil {
+		return item, err
+	}
+
+	cond := notExistsCondition()
+	if err := r.table.putItem(c, av, &cond); err != nil {
+		return item, err
+	}
+	return r.stored(item, av)
+}
+
+// PutWithCondition 는 조건을 만족할 때만 upsert 하고 저장된 item 을 전달, 조건이 실패하면 common.ErrorConditionCheckFailed
+func (r Repository[T]) PutWithCondition(c context.Context, item T, cond expression.ConditionBuilder) (T, error) {
+	av, err := r.marshal(item)
+	if err != nil {
+		return item, err
+	}
+
+	if err := r.table.putItem(c, av, &cond); err != nil {
+		return item, err
+	}
+	return r.stored(item, av)
+}
+
+// stored 는 item 에 putItem 에서 바뀐 값을 반영해서 전달, 지금은 version 을 사용할 때 올라간 version 만 바뀜
+func (r Repository[T]) stored(item T, av map[string]types.AttributeValue) (T, error) {
+	attr := r.table.versionAttribute
+	if attr == "" {
+		return item, nil
+	}
+
+	err := attributevalue.UnmarshalMap(map[string]types.AttributeValue{attr: av[attr]}, &item)
+	if err != nil {
+		return item, fmt.Errorf("attribute unmarshal map failed, %w", err)
+	}
+
+	return item, nil
+}
+
+// Update 는 pk, sk 의 item 에서 지정한 attribute 만 update 하고 update 후의 값을 전달
+// Return 을 따로 지정했으면 지정한 값이 바인딩 됨
+func (r Repository[T]) Update(c context.Context, pk, sk string, u *Update) (T, error) {
+	var item T
+	err := r.table.UpdateItem(c, pk, sk, u, &item)
+
+	return item, err
+}
+
+// Delete 는 item 의 Key() 를 이용해서 삭제
+func (r Repository[T]) Delete(c context.Context, item T) error {
+	pk, sk := item.Key()
+	if sk == "" {
+		sk = "#"
+	}
+
+	return r.table.DeleteItem(c, pk, sk)
+}
+
+// Query 는 pk 의 모든 item 을 조회
+func (r Repository[T]) Query(c context.Context, pk string) ([]T, error) {
+	items, err := r.table.queryAll(c, r.table.pkQueryInput(pk))
+	if err != nil {
+		return nil, fmt.Errorf("repository query failed, %w", err)
+	}
+
+	return r.unmarshalList(items)
+}
+
+// QueryBeginsWith 는 pk 와 sk 의 prefix 로 모든 item 을 조회
+func (r Repository[T]) QueryBeginsWith(c context.Context, pk, prefixSk string) ([]T, error) {
+	items, err := r.table.queryAll(c, r.table.beginsWithQueryInput(pk, prefixSk))
+	if err != nil {
+		return nil, fmt.Errorf("repository query begins with failed, %w", err)
+	}
+
+	return r.unmarshalList(items)
+}
+
+// QueryPage 는 pk 로 cursor 위치부터 limit 만큼 조회하고 다음 페이지 cursor 를 같이 전달
+// 돌려 받은 cursor 가 빈 문자열이면 마지막 페이지
+func (r Repository[T]) QueryPage(c context.Context, pk, cursor string, limit int) ([]T, string, error) {
+	items, next, err := r.table.queryPage(c, r.table.pkQueryInput(pk), cursor, limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("repository query page failed, %w", err)
+	}
+
+	list, err := r.unmarshalList(items)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return list, next, nil
+}
+
+// QueryWithGSI 는 gsi 로 모든 item 을 조회
+func (r Repository[T]) QueryWithGSI(c context.Context, gsi string, expr expression.Expression) ([]T, error) {
+	items, err := r.table.queryAll(c, r.table.gsiQueryInput(gsi, expr))
+	if err != nil {
+		return nil, fmt.Errorf("repository query with gsi failed, %w", err)
+	}
+
+	return r.unmarshalList(items)
+}
+
+// ScanEach 는 테이블 전체를 scan 해서 T 로 바인딩 한 item 을 하나씩 fn 으로 전달
+func (r Repository[T]) ScanEach(c context.Context, s *Scan, fn func(item T) error) error {
+	return r.table.ScanEach(c, s, func(av map[string]types.AttributeValue) error {
+		var item T
+		err := attributevalue.UnmarshalMap(av, &item)
+		if err != nil {
+			return fmt.Errorf("attributevalue unmarshalmap failed, err : %w", err)
+		}
+
+		return fn(item)
+	})
+}
+
+// BatchPut 는 여러 item 을 한번에 upsert, PutItemsWithBatch 와 동일하게 25개씩 나눠서 넣고 실패한 item 은 *BatchWriteError 로 전달
+func (r Repository[T]) BatchPut(c context.Context, items []T) error {
+	avs := make([]map[string]types.AttributeValue, 0, len(items))
+	for _, item := range items {
+		av, err := r.marshal(item)
+		if err != nil {
+			return err
+		}
+		avs = append(avs, av)
+	}
+
+	return r.table.putItemsWithBatch(c, avs)
+}
+
+// BatchGet 는 keys 에 해당 하는 item 들을 keys 순서대로 조회하고 없는 key 들을 같이 전달
+// projection 으로 attribute 이름을 넘기면 해당 attribute 만 가져옴
+func (r Repository[T]) BatchGet(c context.Context, keys []Key, projection ...string) ([]T, []Key, error) {
+	items, missing, err := r.table.batchGet(c, keys, projection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list, err := r.unmarshalList(items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return list, missing, nil
+}
+
+// TxPut 는 트랜잭션에 item 을 넣는 작업을 추가, Put 과 동일하게 Key() 의 pk, sk 를 채워 넣음
+func (r Repository[T]) TxPut(tx *Transaction, item T, conds ...expression.ConditionBuilder) *Transaction {
+	av, err := r.marshal(item)
+	if err != nil {
+		tx.setErr(err)
+		return tx
+	}
+
+	return tx.putItem(r.table, av, conds)
+}
+
+// TxDelete 는 트랜잭션에 item 을 삭제하는 작업을 추가
+func (r Repository[T]) TxDelete(tx *Transaction, item T, conds ...expression.ConditionBuilder) *Transaction {
+	pk, sk := item.Key()
+	return tx.Delete(r.table, pk, sk, conds...)
+}
@@ -0,0 +1,270 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// dynamo 에서 허용하는 TotalSegments 최대 값
+	max_scan_segments = 1000000
+
+	// scan 결과를 전달하는 channel 크기, 받는 쪽이 느리면 여기서 막혀서 메모리가 더 늘지 않음
+	scan_buffer_size = 100
+)
+
+// Scan 는 테이블 전체를 훑을 때 사용할 scan 옵션 builder
+// backfill 처럼 테이블 전체를 봐야 하는 작업에서만 사용하고, 일반적인 조회는 Query 를 사용
+// ex) dynamo.NewScan().Segments(4).Filter(expression.Name("log_type").Equal(expression.Value("login"))).ReadCapacity(100)
+type Scan struct {
+	segments     int
+	index        string
+	filter       *expression.ConditionBuilder
+	projection   []string
+	pageSize     int32
+	readCapacity float64
+	consistent   bool
+}
+
+func NewScan() *Scan {
+	return &Scan{segments: 1}
+}
+
+// Segments 는 테이블을 n 개로 나눠서 동시에 scan, 테이블이 클수록 빨라지지만 그만큼 read capacity 를 빨리 씀
+func (s *Scan) Segments(n int) *Scan {
+	s.segments = n
+	return s
+}
+
+// Index 는 테이블 대신 gsi 를 scan
+func (s *Scan) Index(name string) *Scan {
+	s.index = name
+	return s
+}
+
+// Filter 는 조건을 만족하는 item 만 전달, 여러번 호출하면 and 로 묶임
+// 필터링은 읽은 다음에 하는 것이라 read capacity 는 필터와 상관 없이 읽은 만큼 사용 됨
+func (s *Scan) Filter(cond expression.ConditionBuilder) *Scan {
+	s.filter = andCondition(s.filter, cond)
+	return s
+}
+
+// Project 는 지정한 attribute 만 가져옴
+func (s *Scan) Project(names ...string) *Scan {
+	s.projection = append(s.projection, names...)
+	return s
+}
+
+// PageSize 는 요청 한번에 읽을 item 수, 0 이면 dynamo 에서 한번에 주는 만큼(1MB)
+func (s *Scan) PageSize(n int) *Scan {
+	s.pageSize = int32(n)
+	return s
+}
+
+// ReadCapacity 는 초당 사용할 read capacity unit 을 제한, 0 이면 제한 없음
+// 운영 중인 테이블을 backfill 할 때 서비스 트래픽에 쓸 capacity 를 남겨 두기 위함
+// 응답의 ConsumedCapacity 를 보고 모든 segment 가 같이 나눠 씀
+func (s *Scan) ReadCapacity(unitsPerSecond float64) *Scan {
+	s.readCapacity = unitsPerSecond
+	return s
+}
+
+// ConsistentRead 는 strongly consistent read 로 scan, read capacity 를 두 배로 사용
+func (s *Scan) ConsistentRead() *Scan {
+	s.consistent = true
+	return s
+}
+
+// input 는 segment 들이 공통으로 사용할 scan input 을 만들어 줌
+func (s *Scan) input(tableName string) (*dynamodb.ScanInput, error) {
+	if s.segments < 1 || s.segments > max_scan_segments {
+		return nil, fmt.Errorf("invalid scan segments, %d", s.segments)
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(tableName),
+		ConsistentRead:         aws.Bool(s.consistent),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+	if s.index != "" {
+		input.IndexName = aws.String(s.index)
+	}
+	if s.pageSize > 0 {
+		input.Limit = aws.Int32(s.pageSize)
+	}
+
+	if s.filter == nil && len(s.projection) == 0 {
+		return input, nil
+	}
+
+	builder := expression.NewBuilder()
+	if s.filter != nil {
+		builder = builder.WithFilter(*s.filter)
+	}
+	if len(s.projection) > 0 {
+		projection := expression.NamesList(expression.Name(s.projection[0]))
+		for _, name := range s.projection[1:] {
+			projection = projection.AddNames(expression.Name(name))
+		}
+		builder = builder.WithProjection(projection)
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("scan expression build failed, %w", err)
+	}
+
+	input.FilterExpression = expr.Filter()
+	input.ProjectionExpression = expr.Projection()
+	input.ExpressionAttributeNames = expr.Names()
+	input.ExpressionAttributeValues = expr.Values()
+
+	return input, nil
+}
+
+// ScanChan 는 scan 결과를 channel 로 하나씩 전달
+// items 가 닫히고 나면 errc 로 결과(nil 이거나 오류)가 한번 전달 됨
+// items 를 다 읽지 않고 그만 두려면 c 를 cancel 해야 goroutine 이 정리 됨
+//
+//	items, errc := table.ScanChan(c, dynamo.NewScan().Segments(4))
+//	for item := range items { ... }
+//	err := <-errc
+func (t TableBasics) ScanChan(c context.Context, s *Scan) (<-chan map[string]types.AttributeValue, <-chan error) {
+	items := make(chan map[string]types.AttributeValue, scan_buffer_size)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(items)
+
+		errc <- t.scan(c, s, items)
+	}()
+
+	return items, errc
+}
+
+// ScanEach 는 scan 결과를 하나씩 fn 으로 전달, 여러 segment 를 scan 해도 fn 은 동시에 호출 되지 않음
+// fn 이 오류를 주면 scan 을 멈추고 그 오류를 전달
+func (t TableBasics) ScanEach(c context.Context, s *Scan, fn func(item map[string]types.AttributeValue) error) error {
+	c, cancel := context.WithCancel(c)
+	defer cancel()
+
+	items, errc := t.ScanChan(c, s)
+	for item := range items {
+		if err := fn(item); err != nil {
+			cancel()
+			for range items {
+			}
+			<-errc
+			return err
+		}
+	}
+
+	return <-errc
+}
+
+// scan 는 segment 별로 goroutine 을 띄워서 out 으로 item 을 전달, 하나라도 실패하면 전부 멈춤
+func (t TableBasics) scan(c context.Context, s *Scan, out chan<- map[string]types.AttributeValue) error {
+	if s == nil {
+		s = NewScan()
+	}
+	input, err := s.input(t.tableName)
+	if err != nil {
+		return err
+	}
+
+	var limiter *common.RateLimiter
+	if s.readCapacity > 0 {
+		limiter = common.NewRateLimiter(s.readCapacity)
+	}
+
+	c, cancel := context.WithCancel(c)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		count atomic.Int64
+		errs  = make([]error, s.segments)
+	)
+	for segment := 0; segment < s.segments; segment++ {
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+
+			n, err := t.scanSegment(c, *input, segment, s.segments, limiter, out)
+			count.Add(n)
+			if err != nil {
+				errs[segment] = err
+				cancel()
+			}
+		}(segment)
+	}
+	wg.Wait()
+
+	// 다른 segment 가 실패해서 cancel 된 것 보다 처음 실패한 원인을 전달
+	var canceled error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return err
+		}
+		canceled = err
+	}
+	if canceled != nil {
+		return canceled
+	}
+
+	log.Debug().Interface("table", t.tableName).Interface("segments", s.segments).Interface("count", count.Load()).Msg("scan success")
+
+	return nil
+}
+
+// scanSegment 는 segment 하나를 LastEvaluatedKey 가 없을 때까지 scan
+func (t TableBasics) scanSegment(c context.Context, input dynamodb.ScanInput, segment, total int, limiter *common.RateLimiter, out chan<- map[string]types.AttributeValue) (int64, error) {
+	if total > 1 {
+		input.Segment = aws.Int32(int32(segment))
+		input.TotalSegments = aws.Int32(int32(total))
+	}
+
+	var count int64
+	for {
+		if limiter != nil {
+			if err := limiter.Wait(c); err != nil {
+				return count, err
+			}
+		}
+
+		r, err := t.api().Scan(c, &input)
+		if err != nil {
+			return count, fmt.Errorf("scan segment %d failed, %w", segment, err)
+		}
+		if limiter != nil && r.ConsumedCapacity != nil {
+			limiter.Consume(aws.ToFloat64(r.ConsumedCapacity.CapacityUnits))
+		}
+
+		for _, item := range r.Items {
+			select {
+			case out <- item:
+				count++
+			case <-c.Done():
+				return count, c.Err()
+			}
+		}
+
+		if len(r.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		input.ExclusiveStartKey = r.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,440 @@
+package dynamo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo/dynamotest"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	test_table_name         = "portfolio-test"
+	test_success_msg_format = "[%s] success"
+)
+
+const (
+	// dynamo 의 batch write, transaction 한번에 넣을 수 있는 최대 item 수
+	max_count_bulk_item        = 25
+	max_count_transaction_item = 100
+)
+
+type testItem struct {
+	PK      string `dynamodbav:"pk" json:"pk"`
+	SK      string `dynamodbav:"sk" json:"sk"`
+	Val     string `dynamodbav:"val" json:"val"`
+	Updated int64  `dynamodbav:"updated" json:"updated"`
+}
+
+func (i testItem) Key() (string, string) {
+	return i.PK, i.SK
+}
+
+// newTestTable 는 aws 없이 돌릴 수 있도록 메모리 fake 로 만든 테이블을 전달
+func newTestTable() (*dynamotest.Fake, dynamo.TableBasics) {
+	fake := dynamotest.New().AddTable(test_table_name)
+	return fake, dynamo.NewWithClient(fake, test_table_name)
+}
+
+// putTestItems 는 pk 에 prefix#0 ~ prefix#n-1 를 sk 로 하는 item 을 넣음
+func putTestItems(t *testing.T, table dynamo.TableBasics, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		err := table.PutItem(context.TODO(), testItem{PK: "pk", SK: fmt.Sprintf("%s#%d", prefix, i), Val: "val"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test_ListTable 는 테이블 리스트 조회 기능 테스트
+func Test_ListTable(t *testing.T) {
+	_, dynamoClient := newTestTable()
+	tables, err := dynamoClient.ListTables(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0] != test_table_name {
+		t.Fatalf("table list mismatch, %v", tables)
+	}
+
+	log.Debug().Interface("tables", tables).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_CreateTable 는 테이블 생성 확인
+func Test_CreateTable(t *testing.T) {
+	dynamoClient := dynamo.NewWithClient(dynamotest.New(), test_table_name)
+	tableDesc, err := dynamoClient.CreateTable(context.TODO(), dynamo.CREATE_TABLE_SCHEMA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *tableDesc.TableName != test_table_name {
+		t.Fatalf("created table name mismatch, %s", *tableDesc.TableName)
+	}
+
+	log.Debug().Interface("table_desc", tableDesc).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// PutItem 는 아이템을 dynamo 에 upsert
+func Test_PutItem(t *testing.T) {
+	item := testItem{
+		PK:      "pk",
+		SK:      "sk",
+		Val:     "val",
+		Updated: time.Now().Unix(),
+	}
+
+	fake, dynamoClient := newTestTable()
+	err := dynamoClient.PutItem(context.TODO(), item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Items(test_table_name)) != 1 {
+		t.Fatalf("item must be put, %v", fake.Items(test_table_name))
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_FindWithPK 는 pk 를 가지고 검색 기능 검사
+func Test_FindWithPK(t *testing.T) {
+	_, dynamoClient := newTestTable()
+	putTestItems(t, dynamoClient, "sk", 3)
+
+	pk := "pk"
+	var sliceObj []testItem
+	err := dynamoClient.FindWithPK(context.TODO(), pk, &sliceObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sliceObj) != 3 {
+		t.Fatalf("find with pk count mismatch, %v", sliceObj)
+	}
+
+	log.Debug().Interface("find_obj", sliceObj).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_FindBeginsWith 는 pk, prefixSK를 이용하여 데이터를 조회 하는 기능 검사
+func Test_FindBeginsWith(t *testing.T) {
+	_, dynamoClient := newTestTable()
+	putTestItems(t, dynamoClient, "sk", 3)
+	putTestItems(t, dynamoClient, "other", 3)
+
+	pk := "pk"
+	prefixSk := "sk"
+	var sliceObj []testItem
+	err := dynamoClient.FindBeginsWith(context.TODO(), pk, prefixSk, &sliceObj, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sliceObj) != 2 {
+		t.Fatalf("find begins with must be limited, %v", sliceObj)
+	}
+
+	log.Debug().Interface("find_items", sliceObj).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_MustFindOne 는 하나의 데이터가 있을 꺼라고 믿고 조회를 시도
+func Test_MustFindOne(t *testing.T) {
+	_, dynamoClient := newTestTable()
+	err := dynamoClient.PutItem(context.TODO(), testItem{PK: "pk", SK: "sk", Val: "val"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pk := "pk"
+	sk := "sk"
+
+	var obj testItem
+	err = dynamoClient.MustFindOne(context.TODO(), pk, sk, &obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Val != "val" {
+		t.Fatalf("find one mismatch, %v", obj)
+	}
+
+	err = dynamoClient.MustFindOne(context.TODO(), pk, "none", &obj)
+	if !errors.Is(err, common.ErrorNotFountItem) {
+		t.Fatalf("not exists item must be not found, %v", err)
+	}
+
+	log.Debug().Interface("item", obj).Msgf(test_success_msg_format, common.FunctionName())
+
+}
+
+// Test_DeleteItem 는 pk, sk 를 이용하여 item 삭제
+func Test_DeleteItem(t *testing.T) {
+	fake, dynamoClient := newTestTable()
+	putTestItems(t, dynamoClient, "sk", 1)
+
+	pk := "pk"
+	sk := "sk#0"
+
+	err := dynamoClient.DeleteItem(context.TODO(), pk, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Items(test_table_name)) != 0 {
+		t.Fatalf("item must be deleted, %v", fake.Items(test_table_name))
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_BulkPutItems 는 한번에 여러건 넣을 수 있는 기능 검사
+// 25개가 넘어도 내부에서 나눠서 넣기 때문에 성공 해야 함
+func Test_BulkPutItems(t *testing.T) {
+	items := make([]testItem, 0)
+	for i := 0; i < 60; i++ {
+		items = append(items, testItem{
+			PK: "pk",
+			SK: fmt.Sprintf("bulksk#%d", i),
+		})
+	}
+
+	fake, dynamoClient := newTestTable()
+	err := dynamoClient.PutItemsWithBatch(context.TODO(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Items(test_table_name)) != 60 || fake.Calls(dynamotest.OP_BATCH_WRITE_ITEM) != 3 {
+		t.Fatalf("60 items must be put with 3 batch requests, items : %d, calls : %d", len(fake.Items(test_table_name)), fake.Calls(dynamotest.OP_BATCH_WRITE_ITEM))
+	}
+
+	err = dynamoClient.PutItemsWithBatch(context.TODO(), items[:max_count_bulk_item])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_PutItemsWithTx 는 트랜잭션을 걸고 여러 item 을 넣을 경우 기능 검사
+func Test_PutItemsWithTx(t *testing.T) {
+	items := make([]testItem, 0)
+	for i := 0; i < 101; i++ {
+		items = append(items, testItem{
+			PK: "pk",
+			SK: fmt.Sprintf("bulksk#%d", i),
+		})
+	}
+
+	fake, dynamoClient := newTestTable()
+	err := dynamoClient.PutItemsWithTransaction(context.TODO(), items)
+	if err == nil {
+		t.Fatal("transaction over 100 items must be failed")
+	}
+	err = dynamoClient.PutItemsWithTransaction(context.TODO(), items[:max_count_transaction_item])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Items(test_table_name)) != max_count_transaction_item {
+		t.Fatalf("transaction items must be put, %d", len(fake.Items(test_table_name)))
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_FindWithPKPaging 는 cursor 를 이용하여 pk 의 데이터를 페이지 단위로 끝까지 조회하는 기능 검사
+func Test_FindWithPKPaging(t *testing.T) {
+	_, dynamoClient := newTestTable()
+	putTestItems(t, dynamoClient, "sk", 25)
+
+	pk := "pk"
+	cursor := ""
+	total := 0
+	for {
+		var sliceObj []testItem
+		next, err := dynamoClient.FindWithPKPaging(context.TODO(), pk, cursor, 10, &sliceObj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(sliceObj)
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if total != 25 {
+		t.Fatalf("paging total mismatch, %d", total)
+	}
+
+	log.Debug().Interface("total", total).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_FindAllWithPK 는 pk 를 가지고 모든 페이지를 조회하는 기능 검사
+func Test_FindAllWithPK(t *testing.T) {
+	_, dynamoClient := newTestTable()
+	putTestItems(t, dynamoClient, "sk", 25)
+
+	pk := "pk"
+	var sliceObj []testItem
+	err := dynamoClient.FindAllWithPK(context.TODO(), pk, &sliceObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sliceObj) != 25 {
+		t.Fatalf("find all count mismatch, %d", len(sliceObj))
+	}
+
+	log.Debug().Interface("count", len(sliceObj)).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_Repository 는 타입이 지정된 repository 로 put, get, query, delete 기능 검사
+func Test_Repository(t *testing.T) {
+	_, table := newTestTable()
+	repo := dynamo.NewRepository[testItem](table)
+
+	item := testItem{
+		PK:      "pk",
+		SK:      "repo#1",
+		Val:     "val",
+		Updated: time.Now().Unix(),
+	}
+	saved, err := repo.Put(context.TODO(), item)
+	if err != nil || saved != item {
+		t.Fatalf("repository put must return item, %v, %v", saved, err)
+	}
+
+	found, err := repo.Get(context.TODO(), item.PK, item.SK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != item {
+		t.Fatalf("repository get mismatch, %v", found)
+	}
+
+	items, err := repo.QueryBeginsWith(context.TODO(), item.PK, "repo#")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("repository query mismatch, %v", items)
+	}
+
+	err = repo.Delete(context.TODO(), item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(context.TODO(), item.PK, item.SK); !errors.Is(err, common.ErrorNotFountItem) {
+		t.Fatalf("deleted item must be not found, %v", err)
+	}
+
+	log.Debug().Interface("items", items).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+type testVersionItem struct {
+	PK      string `dynamodbav:"pk" json:"pk"`
+	SK      string `dynamodbav:"sk" json:"sk"`
+	Val     string `dynamodbav:"val" json:"val"`
+	Version int64  `dynamodbav:"version" json:"version"`
+}
+
+func (i testVersionItem) Key() (string, string) {
+	return i.PK, i.SK
+}
+
+// Test_PutItemWithVersion 는 version 을 이용한 optimistic locking 기능 검사
+// 같은 version 으로 두번 쓰면 두번째는 충돌이 나야 함
+func Test_PutItemWithVersion(t *testing.T) {
+	_, table := newTestTable()
+	dynamoClient := table.WithVersion("version")
+
+	item := testVersionItem{PK: "pk", SK: fmt.Sprintf("version#%d", time.Now().UnixNano()), Val: "val"}
+	err := dynamoClient.PutItem(context.TODO(), &item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := item
+	item.Val = "updated"
+	err = dynamoClient.PutItem(context.TODO(), &item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = dynamoClient.PutItem(context.TODO(), &stale)
+	if !errors.Is(err, common.ErrorConditionCheckFailed) {
+		t.Fatalf("stale version must be conflict, %v", err)
+	}
+
+	// batch write 는 version 조건을 걸 수 없어서 거절 해야 함
+	err = dynamoClient.PutItemsWithBatch(context.TODO(), []testVersionItem{stale})
+	if err == nil {
+		t.Fatal("batch write must be rejected on versioned table")
+	}
+
+	err = dynamoClient.DeleteItem(context.TODO(), item.PK, item.SK)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// repository 로 넣으면 올라간 version 을 돌려 줘서 그대로 다시 Put 할 수 있어야 함
+	repo := dynamo.NewRepository[testVersionItem](dynamoClient)
+	saved, err := repo.Put(context.TODO(), testVersionItem{PK: "pk", SK: item.SK + "#repo", Val: "val"})
+	if err != nil || saved.Version != 1 || saved.Val != "val" {
+		t.Fatalf("repository put must return incremented version, %v, %v", saved, err)
+	}
+	saved.Val = "updated"
+	saved, err = repo.Put(context.TODO(), saved)
+	if err != nil || saved.Version != 2 {
+		t.Fatalf("returned item must be put again, %v, %v", saved, err)
+	}
+
+	log.Debug().Interface("item", item).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_UpdateItem 는 일부 attribute 만 update 하고 update 된 값을 돌려 받는 기능 검사
+func Test_UpdateItem(t *testing.T) {
+	_, dynamoClient := newTestTable()
+	err := dynamoClient.PutItem(context.TODO(), testItem{PK: "pk", SK: "sk", Val: "val"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pk := "pk"
+	sk := "sk"
+
+	var updated testItem
+	err = dynamoClient.UpdateItem(context.TODO(), pk, sk, dynamo.NewUpdate().Set("val", "updated").Set("updated", time.Now().Unix()), &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Val != "updated" {
+		t.Fatalf("update item mismatch, %v", updated)
+	}
+
+	log.Debug().Interface("item", updated).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_BatchGet 는 여러 key 를 한번에 조회하고 없는 key 를 알려 주는 기능 검사
+// 100개가 넘는 key 도 내부에서 나눠서 조회 해야 함
+func Test_BatchGet(t *testing.T) {
+	fake, dynamoClient := newTestTable()
+	putTestItems(t, dynamoClient, "bulksk", 100)
+
+	keys := make([]dynamo.Key, 0)
+	for i := 0; i < 120; i++ {
+		keys = append(keys, dynamo.Key{PK: "pk", SK: fmt.Sprintf("bulksk#%d", i)})
+	}
+
+	var items []testItem
+	missing, err := dynamoClient.BatchGet(context.TODO(), keys, &items, "val")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 100 || len(missing) != 20 {
+		t.Fatalf("batch get count mismatch, items : %d, missing : %d", len(items), len(missing))
+	}
+	if fake.Calls(dynamotest.OP_BATCH_GET_ITEM) != 2 {
+		t.Fatalf("120 keys must be requested with 2 batch requests, calls : %d", fake.Calls(dynamotest.OP_BATCH_GET_ITEM))
+	}
+
+	log.Debug().Interface("count", len(items)).Interface("missing", missing).Msgf(test_success_msg_format, common.FunctionName())
+}
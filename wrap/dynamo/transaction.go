@@ -0,0 +1,322 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ClientRequestToken 은 최대 36자 까지만 가능
+	max_length_idempotency_token = 36
+
+	// cancellation reason 에서 문제가 없던 item 은 None 으로 옴
+	cancellation_reason_none              = "None"
+	cancellation_reason_condition_failure = "ConditionalCheckFailed"
+)
+
+// Transaction 는 여러 테이블에 걸쳐서 Put, Update, Delete, ConditionCheck 를 한번에 처리하기 위한 builder
+// 중간에 하나라도 실패하면 전부 롤백됨
+// ex) 계정 생성 + 유저 이름 선점을 한번에 하는 경우
+//
+//	tx := dynamo.NewTransaction().
+//		PutIfNotExists(accountTable, account).
+//		PutIfNotExists(accountTable, userNameReservation)
+//	err := tx.Commit(c)
+type Transaction struct {
+	items []types.TransactWriteItem
+	token string
+
+	// 트랜잭션 요청은 한번만 하기 때문에 처음 추가된 테이블의 client 를 사용, nil 이면 default client
+	// client 가 다른 테이블을 섞으면 다른 계정이나 region 에 보낼 수 있어서 오류
+	client Client
+	used   bool
+
+	// builder 형태로 이어서 호출을 하기 때문에 조립 중에 난 오류는 모아 뒀다가 Commit 에서 전달
+	err error
+}
+
+// TransactionReason 는 트랜잭션이 취소 되었을 때 item 별 취소 사유
+type TransactionReason struct {
+	Index   int // 트랜잭션에 추가한 순서
+	Code    string
+	Message string
+	Err     error // ConditionalCheckFailed 면 common.ErrorConditionCheckFailed
+}
+
+// TransactionError 는 TransactionCanceledException 의 취소 사유를 item 별로 풀어서 전달하기 위한 오류
+type TransactionError struct {
+	Reasons []TransactionReason
+	Err     error
+}
+
+func (e *TransactionError) Error() string {
+	reasons := make([]string, 0, len(e.Reasons))
+	for _, r := range e.Reasons {
+		reasons = append(reasons, fmt.Sprintf("%d:%s", r.Index, r.Code))
+	}
+
+	return fmt.Sprintf("transaction canceled, reasons : [%s], %v", strings.Join(reasons, ","), e.Err)
+}
+
+// Unwrap 는 item 별 오류와 원래 오류를 같이 전달
+// errors.Is(err, common.ErrorConditionCheckFailed) 로 조건 실패 여부를 확인 할 수 있음
+func (e *TransactionError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Reasons)+1)
+	for _, r := range e.Reasons {
+		errs = append(errs, r.Err)
+	}
+	return append(errs, e.Err)
+}
+
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// Len 는 트랜잭션에 추가된 작업 수
+func (tx *Transaction) Len() int {
+	return len(tx.items)
+}
+
+// IdempotencyToken 는 같은 요청이 여러번 들어와도 한번만 처리 되도록 token 을 지정
+// 같은 token 으로 10분 안에 다시 요청하면 dynamo 에서 이전 결과를 그대로 돌려 줌
+func (tx *Transaction) IdempotencyToken(token string) *Transaction {
+	if len(token) > max_length_idempotency_token {
+		tx.setErr(fmt.Errorf("invalid idempotency token, length must be less than or equal to %d", max_length_idempotency_token))
+		return tx
+	}
+
+	tx.token = token
+	return tx
+}
+
+// Put 는 item 을 upsert 하는 작업을 추가, 조건을 넘기면 조건을 만족할 때만 들어감
+func (tx *Transaction) Put(t TableBasics, item interface{}, conds ...expression.ConditionBuilder) *Transaction {
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		tx.setErr(fmt.Errorf("attribute marshal map failed, %w", err))
+		return tx
+	}
+
+	return tx.putItem(t, av, conds)
+}
+
+// PutIfNotExists 는 같은 key 의 item 이 없을 때만 생성 하는 작업을 추가
+func (tx *Transaction) PutIfNotExists(t TableBasics, item interface{}) *Transaction {
+	return tx.Put(t, item, notExistsCondition())
+}
+
+// Update 는 pk, sk 의 item 의 일부 attribute 를 update 하는 작업을 추가
+// 트랜잭션 안에서는 update 결과를 돌려 받을 수 없어서 Return 은 무시됨
+func (tx *Transaction) Update(t TableBasics, pk, sk string, u *Update) *Transaction {
+	if u == nil {
+		tx.setErr(fmt.Errorf("invalid update, update is nil"))
+		return tx
+	}
+
+	expr, err := t.buildUpdate(u)
+	if err != nil {
+		tx.setErr(err)
+		return tx
+	}
+
+	tx.use(t)
+	tx.items = append(tx.items, types.TransactWriteItem{Update: &types.Update{
+		TableName:                 aws.String(t.tableName),
+		Key:                       keyAttributes(pk, sk),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}})
+
+	return tx
+}
+
+// Delete 는 pk, sk 의 item 을 삭제하는 작업을 추가, 조건을 넘기면 조건을 만족할 때만 삭제
+func (tx *Transaction) Delete(t TableBasics, pk, sk string, conds ...expression.ConditionBuilder) *Transaction {
+	del := &types.Delete{
+		TableName: aws.String(t.tableName),
+		Key:       keyAttributes(pk, sk),
+	}
+
+	if cond := mergeConditions(conds); cond != nil {
+		expr, err := expression.NewBuilder().WithCondition(*cond).Build()
+		if err != nil {
+			tx.setErr(fmt.Errorf("condition expression build failed, %w", err))
+			return tx
+		}
+		del.ConditionExpression = expr.Condition()
+		del.ExpressionAttributeNames = expr.Names()
+		del.ExpressionAttributeValues = expr.Values()
+	}
+
+	tx.use(t)
+	tx.items = append(tx.items, types.TransactWriteItem{Delete: del})
+
+	return tx
+}
+
+// ConditionCheck 는 데이터를 바꾸지 않고 pk, sk 의 item 이 조건을 만족하는지만 확인하는 작업을 추가
+// 다른 테이블의 값을 보고 쓰기 여부를 정해야 할 때 사용
+func (tx *Transaction) ConditionCheck(t TableBasics, pk, sk string, cond expression.ConditionBuilder) *Transaction {
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		tx.setErr(fmt.Errorf("condition expression build failed, %w", err))
+		return tx
+	}
+
+	tx.use(t)
+	tx.items = append(tx.items, types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+		TableName:                 aws.String(t.tableName),
+		Key:                       keyAttributes(pk, sk),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}})
+
+	return tx
+}
+
+// Commit 는 모아 놓은 작업들을 TransactWriteItems 로 한번에 처리
+// 취소 되면 item 별 사유가 담긴 *TransactionError 를 전달
+func (tx *Transaction) Commit(c context.Context) error {
+	if tx.err != nil {
+		return tx.err
+	}
+	if len(tx.items) == 0 {
+		return fmt.Errorf("invalid transaction, no items")
+	}
+	if len(tx.items) > max_count_transaction_item {
+		log.Error().Interface("request_items_count", len(tx.items)).Msg(common.ErrorRequestParameterExceed.Error())
+		return common.ErrorRequestParameterExceed
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: tx.items}
+	if tx.token != "" {
+		input.ClientRequestToken = aws.String(tx.token)
+	}
+
+	client := tx.client
+	if client == nil {
+		client = getDefaultClient()
+	}
+
+	response, err := client.TransactWriteItems(c, input)
+	if err != nil {
+		return fmt.Errorf("transaction write items failed, err : %w", transactionError(err))
+	}
+
+	log.Debug().Interface("rseponse", response).Msg("transaction commit success")
+
+	return nil
+}
+
+// putItem 는 marshaling 된 item 을 넣는 작업을 추가, version 을 사용하면 version 조건도 같이 걸어 줌
+func (tx *Transaction) putItem(t TableBasics, av map[string]types.AttributeValue, conds []expression.ConditionBuilder) *Transaction {
+	cond := mergeConditions(conds)
+	if t.versionAttribute != "" {
+		versionCond, err := t.applyVersion(av)
+		if err != nil {
+			tx.setErr(err)
+			return tx
+		}
+		cond = andCondition(cond, versionCond)
+	}
+
+	put := &types.Put{TableName: aws.String(t.tableName), Item: av}
+	if cond != nil {
+		expr, err := expression.NewBuilder().WithCondition(*cond).Build()
+		if err != nil {
+			tx.setErr(fmt.Errorf("condition expression build failed, %w", err))
+			return tx
+		}
+		put.ConditionExpression = expr.Condition()
+		put.ExpressionAttributeNames = expr.Names()
+		put.ExpressionAttributeValues = expr.Values()
+	}
+
+	tx.use(t)
+	tx.items = append(tx.items, types.TransactWriteItem{Put: put})
+
+	return tx
+}
+
+// use 는 처음 추가된 테이블의 client 를 트랜잭션 요청에 사용하도록 지정, 이후 테이블의 client 가 다르면 오류
+func (tx *Transaction) use(t TableBasics) {
+	if !tx.used {
+		tx.client, tx.used = t.client, true
+		return
+	}
+	if t.client != tx.client {
+		tx.setErr(fmt.Errorf("invalid transaction, table %s uses different client", t.tableName))
+	}
+}
+
+// setErr 는 처음 난 오류만 가지고 있음
+func (tx *Transaction) setErr(err error) {
+	if tx.err == nil {
+		tx.err = err
+	}
+}
+
+// keyAttributes 는 pk, sk 로 key attribute 를 만들어 줌, sk 가 없으면 # 으로 지정
+func keyAttributes(pk, sk string) map[string]types.AttributeValue {
+	if sk == "" {
+		sk = "#"
+	}
+
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: pk},
+		"sk": &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+// mergeConditions 는 여러 조건을 and 로 묶어 줌, 조건이 없으면 nil
+func mergeConditions(conds []expression.ConditionBuilder) *expression.ConditionBuilder {
+	var cond *expression.ConditionBuilder
+	for _, c := range conds {
+		cond = andCondition(cond, c)
+	}
+	return cond
+}
+
+// transactionError 는 TransactionCanceledException 의 취소 사유를 item 별 오류로 풀어 줌
+// 취소 사유가 없는 다른 오류는 그대로 전달
+func transactionError(err error) error {
+	var canceledEx *types.TransactionCanceledException
+	if !errors.As(err, &canceledEx) {
+		return err
+	}
+
+	txErr := &TransactionError{Err: err}
+	for i, reason := range canceledEx.CancellationReasons {
+		code := aws.ToString(reason.Code)
+		if code == "" || code == cancellation_reason_none {
+			continue
+		}
+
+		itemErr := errors.New(code)
+		if code == cancellation_reason_condition_failure {
+			itemErr = common.ErrorConditionCheckFailed
+		}
+
+		txErr.Reasons = append(txErr.Reasons, TransactionReason{
+			Index:   i,
+			Code:    code,
+			Message: aws.ToString(reason.Message),
+			Err:     itemErr,
+		})
+	}
+
+	return txErr
+}
@@ -0,0 +1,74 @@
+package dynamo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_TransactionBuilder 는 Put, Update, Delete, ConditionCheck 를 섞어서 트랜잭션을 조립하는 기능 검사
+func Test_TransactionBuilder(t *testing.T) {
+	table := New(test_table_name)
+	logTable := New(test_table_name + "-log")
+
+	tx := NewTransaction().
+		PutIfNotExists(table, testItem{PK: "pk", SK: "tx#1"}).
+		Update(logTable, "pk", "counter", NewUpdate().Increment("count", 1)).
+		Delete(table, "pk", "tx#2").
+		ConditionCheck(table, "pk", "tx#3", expression.AttributeExists(expression.Name("pk"))).
+		IdempotencyToken("token")
+
+	if tx.err != nil {
+		t.Fatal(tx.err)
+	}
+	if tx.Len() != 4 || tx.items[0].Put == nil || tx.items[1].Update == nil || tx.items[2].Delete == nil || tx.items[3].ConditionCheck == nil {
+		t.Fatalf("invalid transaction items, %v", tx.items)
+	}
+	if *tx.items[1].Update.TableName != test_table_name+"-log" {
+		t.Fatalf("update must use log table, %s", *tx.items[1].Update.TableName)
+	}
+
+	// client 가 다른 테이블은 한 트랜잭션으로 보낼 수 없음
+	mixed := NewTransaction().
+		PutIfNotExists(table, testItem{PK: "pk", SK: "tx#1"}).
+		Delete(NewWithClient(&fakeClient{}, test_table_name+"-other"), "pk", "tx#2")
+	if mixed.err == nil {
+		t.Fatal("tables with different clients must be failed")
+	}
+
+	tx.IdempotencyToken(strings.Repeat("a", max_length_idempotency_token+1))
+	if tx.err == nil {
+		t.Fatal("too long token must be failed")
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_TransactionError 는 취소 사유가 item 별 오류로 풀리는지 검사
+func Test_TransactionError(t *testing.T) {
+	err := transactionError(&types.TransactionCanceledException{
+		CancellationReasons: []types.CancellationReason{
+			{Code: aws.String(cancellation_reason_none)},
+			{Code: aws.String(cancellation_reason_condition_failure), Message: aws.String("The conditional request failed")},
+		},
+	})
+
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) {
+		t.Fatalf("must be transaction error, %v", err)
+	}
+	if len(txErr.Reasons) != 1 || txErr.Reasons[0].Index != 1 {
+		t.Fatalf("invalid reasons, %v", txErr.Reasons)
+	}
+	if !errors.Is(err, common.ErrorConditionCheckFailed) {
+		t.Fatalf("condition failure must be ErrorConditionCheckFailed, %v", err)
+	}
+
+	log.Debug().Err(err).Msgf(test_success_msg_format, common.FunctionName())
+}
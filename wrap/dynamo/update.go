@@ -0,0 +1,202 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Update 는 UpdateItem 에 넘길 update expression 을 조립하기 위한 builder
+// expression.UpdateBuilder 를 바로 써도 되지만 attribute 이름만 가지고 간단하게 쓰기 위함
+// ex) dynamo.NewUpdate().Set("last_login", now).Increment("login_count", 1)
+type Update struct {
+	builder         expression.UpdateBuilder
+	condition       *expression.ConditionBuilder
+	expectedVersion *int64
+	returnValue     types.ReturnValue
+}
+
+func NewUpdate() *Update {
+	return &Update{}
+}
+
+// Set 는 attribute 값을 지정한 값으로 변경 (SET name = value)
+func (u *Update) Set(name string, value interface{}) *Update {
+	u.builder = u.builder.Set(expression.Name(name), expression.Value(value))
+	return u
+}
+
+// SetIfNotExists 는 attribute 가 없을 때만 값을 넣음 (SET name = if_not_exists(name, value))
+func (u *Update) SetIfNotExists(name string, value interface{}) *Update {
+	u.builder = u.builder.Set(expression.Name(name), expression.IfNotExists(expression.Name(name), expression.Value(value)))
+	return u
+}
+
+// Remove 는 attribute 를 삭제 (REMOVE name)
+func (u *Update) Remove(names ...string) *Update {
+	for _, name := range names {
+		u.builder = u.builder.Remove(expression.Name(name))
+	}
+	return u
+}
+
+// Add 는 숫자면 더해 주고, set 이면 원소를 추가 (ADD name value)
+// attribute 가 없으면 0 이나 빈 set 에서 시작
+// []string, []int64 처럼 slice 로 넘기면 list 가 아니라 set 으로 바꿔서 넣음
+func (u *Update) Add(name string, value interface{}) *Update {
+	u.builder = u.builder.Add(expression.Name(name), expression.Value(toSet(value)))
+	return u
+}
+
+// Delete 는 set 에서 원소를 제거 (DELETE name value)
+// Add 와 동일하게 slice 로 넘기면 set 으로 바꿔서 넣음
+func (u *Update) Delete(name string, value interface{}) *Update {
+	u.builder = u.builder.Delete(expression.Name(name), expression.Value(toSet(value)))
+	return u
+}
+
+// Increment 는 숫자 attribute 를 delta 만큼 원자적으로 증가, 감소는 음수를 넘기면 됨
+// read-modify-write 없이 카운터를 올리기 위함
+func (u *Update) Increment(name string, delta int64) *Update {
+	return u.Add(name, delta)
+}
+
+// AppendList 는 list attribute 뒤에 values 를 붙임, attribute 가 없으면 빈 list 에서 시작
+// values 는 slice 형태로 넘겨야 함
+func (u *Update) AppendList(name string, values interface{}) *Update {
+	u.builder = u.builder.Set(
+		expression.Name(name),
+		expression.ListAppend(
+			expression.IfNotExists(expression.Name(name), expression.Value([]interface{}{})),
+			expression.Value(values),
+		),
+	)
+	return u
+}
+
+// Condition 는 조건을 만족할 때만 update 되도록 함, 여러번 호출하면 and 로 묶임
+func (u *Update) Condition(cond expression.ConditionBuilder) *Update {
+	u.condition = andCondition(u.condition, cond)
+	return u
+}
+
+// ExpectVersion 는 WithVersion 을 사용하는 테이블에서 저장된 version 이 version 과 같을 때만 update 되도록 함
+// WithVersion 을 사용하는 테이블에서는 필수
+func (u *Update) ExpectVersion(version int64) *Update {
+	u.expectedVersion = aws.Int64(version)
+	return u
+}
+
+// Return 는 update 후에 돌려 받을 값을 지정
+// types.ReturnValueAllOld, types.ReturnValueAllNew, types.ReturnValueUpdatedOld, types.ReturnValueUpdatedNew
+func (u *Update) Return(rv types.ReturnValue) *Update {
+	u.returnValue = rv
+	return u
+}
+
+// UpdateItem 는 pk, sk 의 item 에서 지정한 attribute 만 update
+// item 전체를 다시 쓰지 않아도 되기 때문에 일부 값만 바꿀 때는 PutItem 대신 사용
+// out 이 nil 이 아니면 Return 으로 지정한 값을 바인딩 해주고, 지정을 안했으면 update 후의 값(ALL_NEW)을 바인딩
+// MustFindOne 과 동일하게 sk 가 없으면 # 으로 지정
+// item 이 없으면 새로 만들어 지기 때문에, 막으려면 Condition 으로 attribute_exists 를 걸어야 함
+// 조건이 실패하면 common.ErrorConditionCheckFailed 를 전달
+func (t TableBasics) UpdateItem(c context.Context, pk, sk string, u *Update, out interface{}) error {
+	if u == nil {
+		return fmt.Errorf("invalid update, update is nil")
+	}
+	expr, err := t.buildUpdate(u)
+	if err != nil {
+		return err
+	}
+
+	returnValue := u.returnValue
+	if returnValue == "" && out != nil {
+		returnValue = types.ReturnValueAllNew
+	}
+
+	r, err := t.api().UpdateItem(c, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(t.tableName),
+		Key:                       keyAttributes(pk, sk),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ReturnValues:              returnValue,
+	})
+	if err != nil {
+		return fmt.Errorf("update item failed, %w", conditionError(err))
+	}
+
+	if out != nil {
+		err = attributevalue.UnmarshalMap(r.Attributes, out)
+		if err != nil {
+			return fmt.Errorf("couldn't unmarshal update response, err : %w", err)
+		}
+	}
+
+	log.Debug().Interface("pk", pk).Interface("sk", sk).Interface("attributes", r.Attributes).Msg("update item success")
+
+	return nil
+}
+
+// buildUpdate 는 Update 에 모아 놓은 내용으로 expression 을 만들어 줌
+// version 을 사용하면 ExpectVersion 으로 지정한 version 조건을 걸고 version 을 1 올려 줌
+// version 확인 없이 올리면 동시에 쓰는 곳끼리 서로 덮어 써서 optimistic locking 이 의미가 없어지기 때문에 ExpectVersion 이 없으면 오류
+func (t TableBasics) buildUpdate(u *Update) (expression.Expression, error) {
+	update := u.builder
+	cond := u.condition
+	if t.versionAttribute != "" {
+		if u.expectedVersion == nil {
+			return expression.Expression{}, fmt.Errorf("invalid update, table %s uses version, ExpectVersion is required", t.tableName)
+		}
+		versionCond, err := t.VersionCondition(*u.expectedVersion)
+		if err != nil {
+			return expression.Expression{}, err
+		}
+		update = update.Add(expression.Name(t.versionAttribute), expression.Value(1))
+		cond = andCondition(cond, versionCond)
+	}
+
+	builder := expression.NewBuilder().WithUpdate(update)
+	if cond != nil {
+		builder = builder.WithCondition(*cond)
+	}
+	expr, err := builder.Build()
+	if err != nil {
+		return expression.Expression{}, fmt.Errorf("update expression build failed, %w", err)
+	}
+
+	return expr, nil
+}
+
+// toSet 는 ADD, DELETE 에 넘길 slice 값을 set 타입으로 바꿔 줌
+// 그냥 marshaling 하면 list(L) 로 만들어져서 dynamo 에서 오류가 나기 때문
+func toSet(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []string:
+		return &types.AttributeValueMemberSS{Value: v}
+	case [][]byte:
+		return &types.AttributeValueMemberBS{Value: v}
+	case []int:
+		ns := make([]string, 0, len(v))
+		for _, n := range v {
+			ns = append(ns, strconv.Itoa(n))
+		}
+		return &types.AttributeValueMemberNS{Value: ns}
+	case []int64:
+		ns := make([]string, 0, len(v))
+		for _, n := range v {
+			ns = append(ns, strconv.FormatInt(n, 10))
+		}
+		return &types.AttributeValueMemberNS{Value: ns}
+	default:
+		return value
+	}
+}
@@ -0,0 +1,64 @@
+package dynamo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_UpdateBuilder 는 update builder 로 SET, REMOVE, ADD, DELETE 가 모두 들어간 expression 이 만들어 지는지 검사
+func Test_UpdateBuilder(t *testing.T) {
+	u := NewUpdate().
+		Set("val", "val").
+		SetIfNotExists("created", 1).
+		AppendList("history", []string{"login"}).
+		Increment("count", 1).
+		Delete("tags", []string{"old"}).
+		Remove("temp")
+
+	expr, err := expression.NewBuilder().WithUpdate(u.builder).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := *expr.Update()
+	for _, keyword := range []string{"SET", "REMOVE", "ADD", "DELETE", "list_append", "if_not_exists"} {
+		if !strings.Contains(update, keyword) {
+			t.Fatalf("update expression must contain %s, %s", keyword, update)
+		}
+	}
+
+	if _, ok := expr.Values()[":1"].(*types.AttributeValueMemberSS); !ok {
+		t.Fatalf("delete value must be string set, %T", expr.Values()[":1"])
+	}
+
+	log.Debug().Interface("update", update).Interface("values", expr.Values()).Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_UpdateVersion 는 version 을 사용하는 테이블에서 ExpectVersion 없이 update 하면 오류가 나고, 있으면 version 조건이 걸리는지 검사
+func Test_UpdateVersion(t *testing.T) {
+	table := New(test_table_name).WithVersion("version")
+
+	if _, err := table.buildUpdate(NewUpdate().Set("val", "val")); err == nil {
+		t.Fatal("versioned update without expected version must be failed")
+	}
+
+	expr, err := table.buildUpdate(NewUpdate().Set("val", "val").ExpectVersion(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.Condition() == nil || !strings.Contains(*expr.Update(), "ADD") {
+		t.Fatalf("versioned update must check and increase version, %v, %v", expr.Condition(), expr.Update())
+	}
+
+	// version 을 사용하지 않는 테이블은 그대로
+	if _, err := New(test_table_name).buildUpdate(NewUpdate().Set("val", "val")); err != nil {
+		t.Fatal(err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
@@ -0,0 +1,213 @@
+package envelope
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// ENCODING_* 는 payload 의 content encoding, 압축한 payload 는 base64 문자열로 들어감
+	ENCODING_NONE = ""
+	ENCODING_GZIP = "gzip"
+	ENCODING_ZSTD = "zstd"
+
+	// lambda 에서 x-ray trace id 가 들어 있는 환경 변수
+	env_trace_id = "_X_AMZN_TRACE_ID"
+)
+
+// Envelope 는 queue, topic 으로 보내는 메시지의 공통 포맷
+// 받는 쪽에서 type, version 을 보고 처리 방법을 고르고, content encoding 을 보고 payload 를 풀 수 있게 하기 위함
+//
+//	{"type":"account_noti","version":1,"produced_at":1712650000000,"trace_id":"...","content_encoding":"gzip","payload":"H4sI..."}
+type Envelope struct {
+	Type            string          `json:"type"`
+	Version         int             `json:"version"`
+	ProducedAt      int64           `json:"produced_at"` // unix milli
+	TraceId         string          `json:"trace_id,omitempty"`
+	ContentEncoding string          `json:"content_encoding,omitempty"`
+	Payload         json.RawMessage `json:"payload"`
+}
+
+// Encoder 는 payload 를 Envelope 로 감싸 줌, 기본은 압축 없음
+type Encoder struct {
+	encoding  string
+	threshold int
+}
+
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Compress 는 json payload 가 threshold 바이트 이상이면 encoding 으로 압축
+// 압축하면 base64 로 바뀌어서 30% 정도 커지니 작은 메시지는 압축하지 않는 게 나음
+func (e *Encoder) Compress(encoding string, threshold int) *Encoder {
+	e.encoding = encoding
+	e.threshold = threshold
+	return e
+}
+
+// Wrap 는 payload 를 json 으로 바꿔서 Envelope 로 감싸 줌, trace id 는 context 나 lambda 환경 변수에서 가져옴
+// 만든 Envelope 는 그대로 Queue.Send, json.Marshal 에 넘기면 됨
+func (e *Encoder) Wrap(c context.Context, msgType string, version int, payload interface{}) (Envelope, error) {
+	if msgType == "" {
+		return Envelope{}, fmt.Errorf("invalid envelope, type is empty")
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("envelope payload json marshaling failed, %w", err)
+	}
+
+	env := Envelope{
+		Type:       msgType,
+		Version:    version,
+		ProducedAt: time.Now().UnixMilli(),
+		TraceId:    TraceId(c),
+		Payload:    data,
+	}
+
+	if e.encoding != ENCODING_NONE && len(data) >= e.threshold {
+		compressed, err := compress(e.encoding, data)
+		if err != nil {
+			return Envelope{}, err
+		}
+		encoded, err := json.Marshal(base64.StdEncoding.EncodeToString(compressed))
+		if err != nil {
+			return Envelope{}, fmt.Errorf("envelope payload json marshaling failed, %w", err)
+		}
+		env.ContentEncoding = e.encoding
+		env.Payload = encoded
+	}
+
+	return env, nil
+}
+
+// Decode 는 메시지 body 를 Envelope 로 바꿔 줌, type 이나 payload 가 없으면 envelope 이 아닌 걸로 보고 오류
+func Decode(body []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return Envelope{}, fmt.Errorf("envelope json unmarshaling failed, %w", err)
+	}
+	if env.Type == "" || len(env.Payload) == 0 {
+		return Envelope{}, fmt.Errorf("invalid envelope, type or payload is empty")
+	}
+
+	return env, nil
+}
+
+// Data 는 content encoding 에 맞게 풀어 놓은 json payload 를 전달
+func (env Envelope) Data() ([]byte, error) {
+	if env.ContentEncoding == ENCODING_NONE {
+		return env.Payload, nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(env.Payload, &encoded); err != nil {
+		return nil, fmt.Errorf("envelope payload is not encoded string, %w", err)
+	}
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("envelope payload base64 decoding failed, %w", err)
+	}
+
+	return decompress(env.ContentEncoding, compressed)
+}
+
+// Bind 는 payload 를 풀어서 v 에 바인딩
+func (env Envelope) Bind(v interface{}) error {
+	data, err := env.Data()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("envelope payload json unmarshaling failed, type : %s, version : %d, %w", env.Type, env.Version, err)
+	}
+
+	return nil
+}
+
+func compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch encoding {
+	case ENCODING_GZIP:
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("gzip compress failed, %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("gzip compress failed, %w", err)
+		}
+	case ENCODING_ZSTD:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, fmt.Errorf("zstd compress failed, %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("zstd compress failed, %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("zstd compress failed, %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding, %s", encoding)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompress(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case ENCODING_GZIP:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gzip decompress failed, %w", err)
+		}
+		defer r.Close()
+
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip decompress failed, %w", err)
+		}
+		return out, nil
+	case ENCODING_ZSTD:
+		r, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("zstd decompress failed, %w", err)
+		}
+		defer r.Close()
+
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("zstd decompress failed, %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding, %s", encoding)
+	}
+}
+
+type traceIdKey struct{}
+
+// WithTraceId 는 Wrap 할 때 사용할 trace id 를 context 에 넣어 줌
+func WithTraceId(c context.Context, traceId string) context.Context {
+	return context.WithValue(c, traceIdKey{}, traceId)
+}
+
+// TraceId 는 context 에 넣은 trace id 를 전달, 없으면 lambda 의 x-ray trace id
+func TraceId(c context.Context) string {
+	if id, ok := c.Value(traceIdKey{}).(string); ok && id != "" {
+		return id
+	}
+
+	return os.Getenv(env_trace_id)
+}
@@ -0,0 +1,108 @@
+package envelope
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	test_success_msg_format = "[%s] success"
+)
+
+type testPayload struct {
+	UserId string `json:"user_id"`
+	Val    string `json:"val"`
+}
+
+// Test_Wrap 는 payload 를 감싸고 압축한 뒤 다시 풀어서 같은 값이 나오는지 검사
+func Test_Wrap(t *testing.T) {
+	c := WithTraceId(context.TODO(), "trace-1")
+	payload := testPayload{UserId: "user-1", Val: strings.Repeat("a", 4096)}
+
+	for _, encoding := range []string{ENCODING_NONE, ENCODING_GZIP, ENCODING_ZSTD} {
+		env, err := NewEncoder().Compress(encoding, 1024).Wrap(c, "test", 2, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoding != ENCODING_NONE && len(body) > 1024 {
+			t.Fatalf("payload must be compressed, encoding : %s, size : %d", encoding, len(body))
+		}
+
+		decoded, err := Decode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out testPayload
+		if err := decoded.Bind(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out != payload || decoded.Type != "test" || decoded.Version != 2 || decoded.TraceId != "trace-1" || decoded.ContentEncoding != encoding {
+			t.Fatalf("decoded envelope mismatch, encoding : %s, %+v", encoding, decoded)
+		}
+	}
+
+	// threshold 보다 작으면 압축하지 않음
+	env, err := NewEncoder().Compress(ENCODING_GZIP, 1024).Wrap(c, "test", 1, testPayload{UserId: "user-1"})
+	if err != nil || env.ContentEncoding != ENCODING_NONE {
+		t.Fatalf("small payload must not be compressed, %+v, %v", env, err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_Router 는 type, version 에 맞는 handler 로 보내고, envelope 가 아닌 메시지는 raw handler 로 보내는지 검사
+func Test_Router(t *testing.T) {
+	c := context.TODO()
+	var routed []string
+	router := NewRouter().
+		Handle("test", 1, func(c context.Context, env Envelope) error {
+			routed = append(routed, "v1")
+			return nil
+		}).
+		Handle("test", 2, func(c context.Context, env Envelope) error {
+			routed = append(routed, "v2")
+			return nil
+		}).
+		Raw(func(c context.Context, body []byte) error {
+			routed = append(routed, "raw")
+			return nil
+		})
+
+	for _, version := range []int{1, 2, 3} {
+		env, err := NewEncoder().Wrap(c, "test", version, testPayload{UserId: "user-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal(env)
+
+		err = router.Route(c, body)
+		if version == 3 {
+			if !errors.Is(err, common.ErrorUnsupportedMessage) {
+				t.Fatalf("unknown version must be unsupported, %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := router.Route(c, []byte(`{"user_id":"user-1"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(routed, ",") != "v1,v2,raw" {
+		t.Fatalf("routed handlers mismatch, %v", routed)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
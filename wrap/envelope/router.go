@@ -0,0 +1,66 @@
+package envelope
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// HandlerFunc 는 type, version 에 맞는 Envelope 를 처리하는 함수
+type HandlerFunc func(c context.Context, env Envelope) error
+
+type route struct {
+	msgType string
+	version int
+}
+
+// Router 는 메시지 body 를 Envelope 로 풀어서 type, version 에 맞는 handler 로 보내 줌
+//
+//	router := envelope.NewRouter().
+//		Handle("account_noti", 1, handleV1).
+//		Handle("account_noti", 2, handleV2).
+//		Raw(handleLegacy)
+//	err := router.Route(c, []byte(record.Body))
+type Router struct {
+	routes map[route]HandlerFunc
+	raw    func(c context.Context, body []byte) error
+}
+
+func NewRouter() *Router {
+	return &Router{routes: make(map[route]HandlerFunc)}
+}
+
+// Handle 는 type, version 의 메시지를 처리할 handler 를 등록
+func (r *Router) Handle(msgType string, version int, fn HandlerFunc) *Router {
+	r.routes[route{msgType: msgType, version: version}] = fn
+	return r
+}
+
+// Raw 는 envelope 가 아닌 메시지를 처리할 handler 를 등록, envelope 를 쓰기 전에 보낸 메시지를 처리하기 위함
+func (r *Router) Raw(fn func(c context.Context, body []byte) error) *Router {
+	r.raw = fn
+	return r
+}
+
+// Route 는 body 를 풀어서 맞는 handler 를 호출
+// 등록 안된 type, version 이면 common.ErrorUnsupportedMessage 를 전달
+func (r *Router) Route(c context.Context, body []byte) error {
+	env, err := Decode(body)
+	if err != nil {
+		if r.raw != nil {
+			return r.raw(c, body)
+		}
+		return err
+	}
+
+	fn, ok := r.routes[route{msgType: env.Type, version: env.Version}]
+	if !ok {
+		return fmt.Errorf("route message failed, type : %s, version : %d, %w", env.Type, env.Version, common.ErrorUnsupportedMessage)
+	}
+
+	log.Debug().Interface("type", env.Type).Interface("version", env.Version).Interface("trace_id", env.TraceId).Msg("route message")
+
+	return fn(c, env)
+}
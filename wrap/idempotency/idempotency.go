@@ -0,0 +1,296 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/config"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// 처리가 끝난 key 를 기억 하는 시간, sqs 메시지 보관 기간(최대 14일) 보다 길게
+	default_record_ttl = 15 * 24 * time.Hour
+
+	// 처리 중인 key 를 잡고 있는 시간, 처리하다 죽으면 이 시간이 지난 뒤에 다른 곳에서 다시 잡을 수 있음
+	// sqs 기본 visibility timeout(30초) 안에 풀려야 다시 들어온 메시지가 잡을 수 있어서 그 보다 짧게
+	default_lock_timeout = 20 * time.Second
+
+	// 처리가 끝난 결과를 프로세스 안에 기억 하는 시간과 최대 수
+	// 같은 메시지는 보통 visibility timeout 이 지나고 바로 다시 들어오기 때문에 길게 잡을 필요는 없음
+	default_cache_ttl  = 5 * time.Minute
+	default_cache_size = 1000
+
+	prefix_idempotency_pk = "idempotency#"
+
+	status_in_progress = "in_progress"
+	status_completed   = "completed"
+)
+
+// record 는 테이블에 저장 되는 처리 기록
+// expire 는 테이블의 ttl attribute 로 지정해서 지나면 dynamodb 가 알아서 지움
+type record struct {
+	Id         string `dynamodbav:"id"`
+	Status     string `dynamodbav:"status"`
+	Result     []byte `dynamodbav:"result,omitempty"`
+	LockExpire int64  `dynamodbav:"lock_expire"`
+	Expire     int64  `dynamodbav:"expire"`
+}
+
+func (r record) Key() (string, string) {
+	return prefix_idempotency_pk + r.Id, ""
+}
+
+// Store 는 같은 메시지를 두번 처리하지 않도록 처리한 key 를 dynamodb 에 기록
+// 처리 전에 Claim 으로 key 를 잡고, 끝나면 Complete 로 결과를 남기고, 실패하면 Release 로 풀어 줌
+// 처리가 끝난 결과는 프로세스 안에도 잠깐 cache 해서, 같은 프로세스로 다시 들어온 key 는 dynamodb 를 조회 하지 않음
+// 보통은 직접 쓰지 않고 Do 나 Wrap 을 사용
+//
+//	store := idempotency.NewDefault()
+//	handler := idempotency.Wrap(store, func(r events.SQSMessage) string { return r.MessageId }, handle)
+type Store struct {
+	repo        dynamo.Repository[record]
+	ttl         time.Duration
+	lockTimeout time.Duration
+	cacheTTL    time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// cacheEntry 는 프로세스 안에 기억 해 둔 처리 결과
+type cacheEntry struct {
+	result []byte
+	expire time.Time
+}
+
+// New 는 table 에 기록하는 Store 를 생성, table 은 pk, sk 로 된 테이블이고 expire 를 ttl attribute 로 지정 해야 함
+func New(table dynamo.TableBasics) *Store {
+	return &Store{
+		repo:        dynamo.NewRepository[record](table),
+		ttl:         default_record_ttl,
+		lockTimeout: default_lock_timeout,
+		cacheTTL:    default_cache_ttl,
+		now:         time.Now,
+		cache:       make(map[string]cacheEntry),
+	}
+}
+
+// NewDefault 는 config.TABLE_IDEMPOTENCY 테이블을 사용하는 Store 를 생성
+func NewDefault() *Store {
+	return New(dynamo.New(config.TABLE_IDEMPOTENCY))
+}
+
+// TTL 는 처리가 끝난 key 를 기억 하는 시간
+func (s *Store) TTL(d time.Duration) *Store {
+	s.ttl = d
+	return s
+}
+
+// LockTimeout 는 처리 중인 key 를 잡고 있는 시간, handler 가 걸리는 시간 보다 길어야 함
+// 다시 들어오는 간격(lambda timeout, queue 의 visibility timeout) 보다 길면 재시도가 계속 ErrorAlreadyInProgress 로 실패 하기 때문에
+// lambda 에서는 function timeout 에 맞춰 주는 것이 좋음
+func (s *Store) LockTimeout(d time.Duration) *Store {
+	s.lockTimeout = d
+	return s
+}
+
+// CacheTTL 는 처리가 끝난 결과를 프로세스 안에 기억 하는 시간, 0 이면 cache 하지 않고 매번 dynamodb 를 조회
+// TTL 보다 길게 주어도 TTL 까지만 기억
+func (s *Store) CacheTTL(d time.Duration) *Store {
+	s.cacheTTL = d
+	return s
+}
+
+// Claim 는 key 를 처리 하겠다고 잡음
+// 처음 잡은 경우에는 done 이 false, 이미 처리가 끝난 key 면 done 이 true 이고 저장해 둔 결과를 전달
+// 다른 곳에서 처리 중이면 common.ErrorAlreadyInProgress 를 전달
+func (s *Store) Claim(c context.Context, key string) ([]byte, bool, error) {
+	if key == "" {
+		return nil, false, fmt.Errorf("invalid idempotency key, key is empty")
+	}
+	if result, ok := s.cached(key); ok {
+		log.Debug().Interface("key", key).Msg("idempotency already completed, cached")
+		return result, true, nil
+	}
+
+	now := s.now()
+	item := record{
+		Id:         key,
+		Status:     status_in_progress,
+		LockExpire: now.Add(s.lockTimeout).Unix(),
+		Expire:     now.Add(s.ttl).Unix(),
+	}
+
+	// 없거나, ttl 이 지났는데 아직 안 지워졌거나, 처리 중이던 곳이 lock 시간 안에 못 끝낸 경우에만 잡을 수 있음
+	cond := expression.AttributeNotExists(expression.Name("pk")).
+		Or(expression.Name("expire").LessThan(expression.Value(now.Unix()))).
+		Or(expression.Name("status").Equal(expression.Value(status_in_progress)).
+			And(expression.Name("lock_expire").LessThan(expression.Value(now.Unix()))))
+
+	_, err := s.repo.PutWithCondition(c, item, cond)
+	if err == nil {
+		log.Debug().Interface("key", key).Msg("idempotency claim success")
+		return nil, false, nil
+	}
+	if !errors.Is(err, common.ErrorConditionCheckFailed) {
+		return nil, false, fmt.Errorf("idempotency claim failed, key : %s, %w", key, err)
+	}
+
+	pk, sk := item.Key()
+	exists, err := s.repo.Get(c, pk, sk)
+	if err != nil {
+		return nil, false, fmt.Errorf("idempotency get record failed, key : %s, %w", key, err)
+	}
+	if exists.Status != status_completed {
+		return nil, false, fmt.Errorf("idempotency claim failed, key : %s, %w", key, common.ErrorAlreadyInProgress)
+	}
+
+	log.Debug().Interface("key", key).Msg("idempotency already completed")
+	s.remember(key, exists.Result)
+
+	return exists.Result, true, nil
+}
+
+// Complete 는 처리가 끝난 key 에 결과를 기록해서 다음에 Claim 하면 기록한 결과를 돌려 주게 함
+func (s *Store) Complete(c context.Context, key string, result []byte) error {
+	now := s.now()
+	_, err := s.repo.Put(c, record{
+		Id:     key,
+		Status: status_completed,
+		Result: result,
+		Expire: now.Add(s.ttl).Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("idempotency complete failed, key : %s, %w", key, err)
+	}
+	s.remember(key, result)
+
+	return nil
+}
+
+// Release 는 처리에 실패한 key 를 지워서 재시도 할 때 다시 잡을 수 있게 함
+func (s *Store) Release(c context.Context, key string) error {
+	s.forget(key)
+	err := s.repo.Delete(c, record{Id: key})
+	if err != nil {
+		return fmt.Errorf("idempotency release failed, key : %s, %w", key, err)
+	}
+
+	return nil
+}
+
+// cached 는 프로세스 안에 기억 해 둔 결과를 전달
+func (s *Store) cached(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !s.now().Before(entry.expire) {
+		delete(s.cache, key)
+		return nil, false
+	}
+
+	return entry.result, true
+}
+
+// remember 는 처리가 끝난 결과를 기억, 가득 차면 만료된 것부터 지우고 그래도 가득 차면 아무거나 하나 지움
+func (s *Store) remember(key string, result []byte) {
+	ttl := min(s.cacheTTL, s.ttl)
+	if ttl <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if _, ok := s.cache[key]; !ok && len(s.cache) >= default_cache_size {
+		for k, entry := range s.cache {
+			if !now.Before(entry.expire) {
+				delete(s.cache, k)
+			}
+		}
+		for k := range s.cache {
+			if len(s.cache) < default_cache_size {
+				break
+			}
+			delete(s.cache, k)
+		}
+	}
+	s.cache[key] = cacheEntry{result: result, expire: now.Add(ttl)}
+}
+
+// forget 는 기억 해 둔 결과를 지움
+func (s *Store) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.cache, key)
+}
+
+// Do 는 key 로 한번만 fn 을 실행하고 결과를 json 으로 저장, 이미 처리한 key 면 fn 을 실행하지 않고 저장한 결과를 전달
+// fn 이 실패하면 key 를 풀어서 다음에 다시 실행 되게 함
+func Do[T any](c context.Context, s *Store, key string, fn func(c context.Context) (T, error)) (T, error) {
+	var result T
+
+	stored, done, err := s.Claim(c, key)
+	if err != nil {
+		return result, err
+	}
+	if done {
+		if len(stored) > 0 {
+			if err := json.Unmarshal(stored, &result); err != nil {
+				return result, fmt.Errorf("idempotency result unmarshal failed, key : %s, %w", key, err)
+			}
+		}
+		return result, nil
+	}
+
+	result, err = fn(c)
+	if err != nil {
+		if releaseErr := s.Release(context.WithoutCancel(c), key); releaseErr != nil {
+			log.Error().Err(releaseErr).Interface("key", key).Msg("idempotency release failed")
+		}
+		return result, err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return result, fmt.Errorf("idempotency result marshal failed, key : %s, %w", key, err)
+	}
+	// 처리는 이미 끝났기 때문에 기록이 실패해도 결과는 전달, lock 시간이 지나면 다시 처리 될 수는 있음
+	if err := s.Complete(context.WithoutCancel(c), key, data); err != nil {
+		log.Error().Err(err).Interface("key", key).Msg("idempotency complete failed")
+	}
+
+	return result, nil
+}
+
+// Wrap 는 결과가 없는 handler 를 감싸서 같은 key 의 event 는 한번만 처리 되게 함
+// key 는 sqs 면 MessageId, dynamodb stream 이면 EventID 처럼 재전송 되어도 바뀌지 않는 값을 사용
+// key 가 비어 있으면 기록 없이 그냥 처리
+func Wrap[E any](s *Store, key func(event E) string, handler func(c context.Context, event E) error) func(c context.Context, event E) error {
+	return func(c context.Context, event E) error {
+		k := key(event)
+		if k == "" {
+			return handler(c, event)
+		}
+
+		_, err := Do(c, s, k, func(c context.Context) (struct{}, error) {
+			return struct{}{}, handler(c, event)
+		})
+		return err
+	}
+}
@@ -0,0 +1,145 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo"
+	"github.com/dalpengida/portfolio-go-aws/wrap/dynamo/dynamotest"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	test_table_name         = "portfolio-idempotency-test"
+	test_success_msg_format = "[%s] success"
+)
+
+func newTestStore() *Store {
+	_, store := newTestStoreWithFake()
+	return store
+}
+
+func newTestStoreWithFake() (*dynamotest.Fake, *Store) {
+	fake := dynamotest.New().AddTable(test_table_name)
+	return fake, New(dynamo.NewWithClient(fake, test_table_name))
+}
+
+// Test_Do 는 같은 key 는 한번만 실행하고 저장한 결과를 돌려 주고, 실패하면 다시 실행 되는지 검사
+func Test_Do(t *testing.T) {
+	c := context.TODO()
+	store := newTestStore()
+
+	calls := 0
+	fn := func(c context.Context) (int, error) {
+		calls++
+		return calls * 10, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		result, err := Do(c, store, "message-1", fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != 10 {
+			t.Fatalf("cached result must be returned, result : %d", result)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("fn must be called once, calls : %d", calls)
+	}
+
+	// 실패하면 key 를 풀어 줘서 다음에 다시 실행
+	_, err := Do(c, store, "message-2", func(c context.Context) (int, error) {
+		return 0, fmt.Errorf("handle failed")
+	})
+	if err == nil {
+		t.Fatal("fn error must be returned")
+	}
+	result, err := Do(c, store, "message-2", fn)
+	if err != nil || result != 20 {
+		t.Fatalf("released key must be claimed again, result : %d, %v", result, err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_Claim 는 처리 중인 key 는 잡을 수 없고, lock 시간이 지나면 다시 잡을 수 있는지 검사
+func Test_Claim(t *testing.T) {
+	c := context.TODO()
+	now := time.Now()
+	store := newTestStore().LockTimeout(time.Minute)
+	store.now = func() time.Time { return now }
+
+	_, done, err := store.Claim(c, "message-1")
+	if err != nil || done {
+		t.Fatalf("first claim must success, %v", err)
+	}
+	_, _, err = store.Claim(c, "message-1")
+	if !errors.Is(err, common.ErrorAlreadyInProgress) {
+		t.Fatalf("claimed key must be in progress, %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	_, done, err = store.Claim(c, "message-1")
+	if err != nil || done {
+		t.Fatalf("expired lock must be claimed again, %v", err)
+	}
+
+	// handler 감싸기
+	calls := 0
+	handler := Wrap(store, func(event string) string { return event }, func(c context.Context, event string) error {
+		calls++
+		return nil
+	})
+	for i := 0; i < 2; i++ {
+		if err := handler(c, "message-2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("duplicated event must be skipped, calls : %d", calls)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_Cache 는 처리가 끝난 key 는 dynamodb 를 조회 하지 않고 기억 해 둔 결과를 돌려 주고, 만료 되면 다시 조회 하는지 검사
+func Test_Cache(t *testing.T) {
+	c := context.TODO()
+	now := time.Now()
+	fake, store := newTestStoreWithFake()
+	store.CacheTTL(time.Minute)
+	store.now = func() time.Time { return now }
+
+	fn := func(c context.Context) (string, error) {
+		return "result", nil
+	}
+	if _, err := Do(c, store, "message-1", fn); err != nil {
+		t.Fatal(err)
+	}
+
+	puts, gets := fake.Calls(dynamotest.OP_PUT_ITEM), fake.Calls(dynamotest.OP_GET_ITEM)
+	result, err := Do(c, store, "message-1", fn)
+	if err != nil || result != "result" {
+		t.Fatalf("cached result must be returned, %s, %v", result, err)
+	}
+	if fake.Calls(dynamotest.OP_PUT_ITEM) != puts || fake.Calls(dynamotest.OP_GET_ITEM) != gets {
+		t.Fatal("cached key must not call dynamodb")
+	}
+
+	// 만료 되면 dynamodb 에 남아 있는 기록으로 확인
+	now = now.Add(2 * time.Minute)
+	result, err = Do(c, store, "message-1", fn)
+	if err != nil || result != "result" {
+		t.Fatalf("stored result must be returned, %s, %v", result, err)
+	}
+	if fake.Calls(dynamotest.OP_GET_ITEM) == gets {
+		t.Fatal("expired cache must look up dynamodb")
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
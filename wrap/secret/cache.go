@@ -0,0 +1,165 @@
+package secret
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// 값을 다시 가져 오기 전까지 기억 하는 시간
+	default_cache_ttl = 5 * time.Minute
+
+	// 만료 되기 이 시간 전부터는 기억 해 둔 값을 주면서 뒤에서 미리 다시 가져 옴
+	default_refresh_before = time.Minute
+
+	// 뒤에서 다시 가져 올 때는 요청한 context 가 끝나도 계속 하도록 따로 시간을 줌
+	background_refresh_timeout = 10 * time.Second
+)
+
+// cacheKey 는 secret id 와 version stage 로 값을 구분
+type cacheKey struct {
+	secretId string
+	stage    string
+}
+
+type cacheEntry struct {
+	secret Secret
+	expire time.Time
+}
+
+// call 은 같은 key 를 동시에 가져 올 때 한번만 가져 오고 결과를 나눠 갖기 위함
+type call struct {
+	done   chan struct{}
+	secret Secret
+	err    error
+}
+
+// Cache 는 secretsmanager 에서 가져 온 값을 ttl 동안 기억
+// 같은 값을 동시에 가져 오면 한번만 요청하고, 만료 되기 전에 뒤에서 미리 다시 가져 와서 hot path 에서는 기다리지 않음
+// 뒤에서 가져 오다 실패하면 만료 될 때까지 기억 해 둔 값을 계속 씀
+type Cache struct {
+	ttl           time.Duration
+	refreshBefore time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+	calls   map[cacheKey]*call
+}
+
+// NewCache 는 값을 5분 동안 기억하고, 만료 1분 전부터 미리 다시 가져 오는 Cache 를 생성
+func NewCache() *Cache {
+	return &Cache{
+		ttl:           default_cache_ttl,
+		refreshBefore: default_refresh_before,
+		now:           time.Now,
+		entries:       make(map[cacheKey]cacheEntry),
+		calls:         make(map[cacheKey]*call),
+	}
+}
+
+// TTL 는 값을 기억 하는 시간
+func (cache *Cache) TTL(d time.Duration) *Cache {
+	cache.ttl = d
+	return cache
+}
+
+// RefreshBefore 는 만료 되기 얼마 전부터 미리 다시 가져 올지, 0 이면 미리 가져 오지 않음
+func (cache *Cache) RefreshBefore(d time.Duration) *Cache {
+	cache.refreshBefore = d
+	return cache
+}
+
+// Invalidate 는 secret id 의 모든 stage 값을 잊음, rotation 을 한 직후 처럼 바로 새 값을 받아야 할 때 사용
+func (cache *Cache) Invalidate(secretId string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for key := range cache.entries {
+		if key.secretId == secretId {
+			delete(cache.entries, key)
+		}
+	}
+}
+
+// Clear 는 기억 해 둔 값을 모두 잊음
+func (cache *Cache) Clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries = make(map[cacheKey]cacheEntry)
+}
+
+// get 은 기억 해 둔 값이 있으면 전달하고, 없거나 만료 되었으면 fetch 로 가져 옴
+// 만료가 가까우면 기억 해 둔 값을 주고 뒤에서 fetch 를 한번 돌림
+func (cache *Cache) get(c context.Context, key cacheKey, fetch func(c context.Context) (Secret, error)) (Secret, error) {
+	now := cache.now()
+
+	cache.mu.Lock()
+	entry, ok := cache.entries[key]
+	if ok && now.Before(entry.expire) {
+		if cache.refreshBefore > 0 && !now.Before(entry.expire.Add(-cache.refreshBefore)) {
+			if _, running := cache.calls[key]; !running {
+				cl := cache.start(key)
+				go cache.refresh(context.WithoutCancel(c), key, cl, fetch)
+			}
+		}
+		cache.mu.Unlock()
+		return entry.secret, nil
+	}
+
+	cl, running := cache.calls[key]
+	if !running {
+		cl = cache.start(key)
+		cache.mu.Unlock()
+		cache.do(c, key, cl, fetch)
+	} else {
+		cache.mu.Unlock()
+	}
+
+	select {
+	case <-cl.done:
+		return cl.secret, cl.err
+	case <-c.Done():
+		return Secret{}, c.Err()
+	}
+}
+
+// start 는 key 를 가져 오는 중이라고 표시, mu 를 잡은 상태에서 호출
+func (cache *Cache) start(key cacheKey) *call {
+	cl := &call{done: make(chan struct{})}
+	cache.calls[key] = cl
+	return cl
+}
+
+// do 는 fetch 를 한번 실행하고 성공하면 기억, 기다리던 곳들에게 결과를 알려 줌
+func (cache *Cache) do(c context.Context, key cacheKey, cl *call, fetch func(c context.Context) (Secret, error)) {
+	defer close(cl.done)
+
+	cl.secret, cl.err = fetch(c)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.calls, key)
+	if cl.err == nil {
+		cache.entries[key] = cacheEntry{secret: cl.secret, expire: cache.now().Add(cache.ttl)}
+	}
+}
+
+// refresh 는 뒤에서 미리 다시 가져 옴, 실패해도 만료 될 때까지는 기억 해 둔 값을 씀
+func (cache *Cache) refresh(c context.Context, key cacheKey, cl *call, fetch func(c context.Context) (Secret, error)) {
+	c, cancel := context.WithTimeout(c, background_refresh_timeout)
+	defer cancel()
+
+	cache.do(c, key, cl, fetch)
+	if cl.err != nil {
+		log.Error().Err(cl.err).Interface("secret_id", key.secretId).Interface("stage", key.stage).Msg("secret background refresh failed")
+		return
+	}
+
+	log.Debug().Interface("secret", cl.secret).Msg("secret background refresh success")
+}
@@ -0,0 +1,306 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/config"
+	"github.com/joho/godotenv"
+)
+
+const (
+	// 환경 변수에서 찾을 때 secret id 앞에 붙이는 값, ex) portfolio/db-password -> SECRET_PORTFOLIO_DB_PASSWORD
+	default_env_prefix = "SECRET_"
+
+	// local 에서 읽을 secret 파일, SECRET_FILE 환경 변수로 바꿀 수 있음
+	default_secret_file = ".secret.json"
+	env_secret_file     = "SECRET_FILE"
+)
+
+// Provider 는 secret id 와 stage 로 값을 찾아 줌
+// 없으면 common.ErrorNotFountItem 을 감싸서 전달해야 Chain 에서 다음 provider 로 넘어감
+// Manager(secretsmanager), EnvProvider, FileProvider, MemoryProvider 가 구현
+type Provider interface {
+	Get(c context.Context, secretId, stage string) (Secret, error)
+}
+
+var (
+	defaultProvider   Provider
+	defaultProviderMu sync.Mutex
+)
+
+// SetDefaultProvider 는 패키지 함수들이 사용할 provider 를 변경, 테스트에서 MemoryProvider 를 넣을 때 사용
+func SetDefaultProvider(p Provider) {
+	defaultProviderMu.Lock()
+	defer defaultProviderMu.Unlock()
+
+	defaultProvider = p
+}
+
+// getDefaultProvider 는 default provider 를 전달, 처음 호출 될 때 config.STAGE 를 보고 생성
+func getDefaultProvider() Provider {
+	defaultProviderMu.Lock()
+	defer defaultProviderMu.Unlock()
+
+	if defaultProvider == nil {
+		defaultProvider = ProviderForStage(config.Config(config.STAGE))
+	}
+
+	return defaultProvider
+}
+
+// ProviderForStage 는 stage 에 맞는 provider 를 전달
+//   - local 이면 aws 없이 환경 변수, secret 파일 순서로 찾음
+//   - lambda 가 아닌 곳에서 dev 같은 stage 로 띄우거나 stage 가 없으면 환경 변수, secret 파일에 없을 때 secretsmanager 에서 찾음
+//   - lambda 에서는 secretsmanager 만 사용, 값은 cache 에 기억 해 둠
+func ProviderForStage(stage string) Provider {
+	sm := New().WithCache(defaultCache)
+
+	if stage == config.STAGE_LOCAL {
+		return NewChain(NewEnvProvider(default_env_prefix), NewFileProvider(secretFile()))
+	}
+	if !common.IsAWSLambda() {
+		return NewChain(NewEnvProvider(default_env_prefix), NewFileProvider(secretFile()), sm)
+	}
+
+	return sm
+}
+
+// secretFile 는 local 에서 읽을 secret 파일 경로를 전달
+func secretFile() string {
+	if path := config.Config(env_secret_file); path != "" {
+		return path
+	}
+	return default_secret_file
+}
+
+// notFound 는 provider 에 값이 없을 때의 오류
+func notFound(secretId, stage string) error {
+	return fmt.Errorf("secret not found, secret id : %s, stage : %s, %w", secretId, stage, common.ErrorNotFountItem)
+}
+
+// getString 는 provider 에서 현재 값을 문자열로 가져옴
+func getString(c context.Context, p Provider, secretId string) (string, error) {
+	secret, err := p.Get(c, secretId, VERSION_STAGE_CURRENT)
+	if err != nil {
+		return "", err
+	}
+
+	return secret.Value(), nil
+}
+
+// getWithJsonUnmarshal 는 provider 에서 현재 값을 가져와서 obj 로 unmarshaling
+func getWithJsonUnmarshal(c context.Context, p Provider, secretId string, obj interface{}) error {
+	r, err := getString(c, p, secretId)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal([]byte(r), obj)
+	if err != nil {
+		return fmt.Errorf("data unmarshaling failed, %w", err)
+	}
+
+	return nil
+}
+
+// Chain 은 provider 들을 순서대로 찾아 보고 처음 찾은 값을 전달
+// 없다는 오류가 아니면 다음 provider 로 넘어가지 않고 그 오류를 전달
+//
+//	p := secret.NewChain(secret.NewEnvProvider("SECRET_"), secret.NewFileProvider(".secret.json"), secret.New())
+type Chain struct {
+	providers []Provider
+}
+
+// NewChain 는 providers 순서대로 찾는 Chain 을 생성
+func NewChain(providers ...Provider) Chain {
+	return Chain{providers: providers}
+}
+
+func (ch Chain) Get(c context.Context, secretId, stage string) (Secret, error) {
+	for _, p := range ch.providers {
+		secret, err := p.Get(c, secretId, stage)
+		if err == nil {
+			return secret, nil
+		}
+		if !errors.Is(err, common.ErrorNotFountItem) {
+			return Secret{}, err
+		}
+	}
+
+	return Secret{}, notFound(secretId, stage)
+}
+
+// EnvProvider 는 환경 변수에서 값을 찾음, .env 파일은 config 패키지가 읽어서 환경 변수로 넣어 줌
+// 환경 변수에는 stage 가 없어서 VERSION_STAGE_CURRENT 만 찾음
+type EnvProvider struct {
+	prefix string
+}
+
+// NewEnvProvider 는 prefix 를 붙인 환경 변수에서 찾는 EnvProvider 를 생성
+func NewEnvProvider(prefix string) EnvProvider {
+	return EnvProvider{prefix: prefix}
+}
+
+func (p EnvProvider) Get(c context.Context, secretId, stage string) (Secret, error) {
+	if stage != "" && stage != VERSION_STAGE_CURRENT {
+		return Secret{}, notFound(secretId, stage)
+	}
+
+	v, ok := os.LookupEnv(envName(p.prefix, secretId))
+	if !ok {
+		return Secret{}, notFound(secretId, stage)
+	}
+
+	return localSecret(secretId, v), nil
+}
+
+// envName 는 secret id 를 환경 변수 이름으로 바꿈, 영문 대문자와 숫자가 아닌 문자는 _ 로 바꿈
+func envName(prefix, secretId string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		default:
+			return '_'
+		}
+	}, secretId)
+
+	return prefix + name
+}
+
+// FileProvider 는 local 파일에서 값을 찾음, 파일을 고치면 바로 반영 되도록 찾을 때마다 읽음
+// .json 파일이면 secret id 를 key 로 하는 object 이고, 값이 object 면 json 그대로 전달해서 GetWithJsonUnmarshal 로 받을 수 있음
+// 그 외에는 dotenv 파일로 읽고, secret id 그대로 또는 환경 변수 이름으로 바꾼 key 로 찾음
+// 파일이 없으면 모든 값이 없는 걸로 봄, VERSION_STAGE_CURRENT 만 찾음
+//
+//	{"portfolio_id": {"key": "value"}, "token": "abc"}
+type FileProvider struct {
+	path string
+}
+
+// NewFileProvider 는 path 의 파일에서 찾는 FileProvider 를 생성
+func NewFileProvider(path string) FileProvider {
+	return FileProvider{path: path}
+}
+
+func (p FileProvider) Get(c context.Context, secretId, stage string) (Secret, error) {
+	if stage != "" && stage != VERSION_STAGE_CURRENT {
+		return Secret{}, notFound(secretId, stage)
+	}
+
+	values, err := p.read()
+	if err != nil {
+		return Secret{}, err
+	}
+
+	v, ok := values[secretId]
+	if !ok {
+		v, ok = values[envName("", secretId)]
+	}
+	if !ok {
+		return Secret{}, notFound(secretId, stage)
+	}
+
+	return localSecret(secretId, v), nil
+}
+
+// read 는 파일을 읽어서 key 별 값을 전달
+func (p FileProvider) read() (map[string]string, error) {
+	if strings.EqualFold(filepath.Ext(p.path), ".json") {
+		data, err := os.ReadFile(p.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read secret file failed, path : %s, %w", p.path, err)
+		}
+
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("secret file unmarshaling failed, path : %s, %w", p.path, err)
+		}
+
+		values := make(map[string]string, len(raw))
+		for k, v := range raw {
+			var s string
+			if err := json.Unmarshal(v, &s); err == nil {
+				values[k] = s
+				continue
+			}
+			values[k] = string(v)
+		}
+		return values, nil
+	}
+
+	values, err := godotenv.Read(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secret file failed, path : %s, %w", p.path, err)
+	}
+
+	return values, nil
+}
+
+// MemoryProvider 는 메모리에 넣어 둔 값을 찾음, 테스트에서 SetDefaultProvider 와 같이 사용
+type MemoryProvider struct {
+	mu     sync.RWMutex
+	values map[cacheKey]string
+}
+
+// NewMemoryProvider 는 빈 MemoryProvider 를 생성
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{values: make(map[cacheKey]string)}
+}
+
+// Set 는 secret id 의 현재 값을 넣음
+func (p *MemoryProvider) Set(secretId, value string) *MemoryProvider {
+	return p.SetStage(secretId, VERSION_STAGE_CURRENT, value)
+}
+
+// SetStage 는 secret id 의 stage 값을 넣음, rotation 중인 상황을 만들 때 사용
+func (p *MemoryProvider) SetStage(secretId, stage, value string) *MemoryProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.values[cacheKey{secretId: secretId, stage: stage}] = value
+	return p
+}
+
+func (p *MemoryProvider) Get(c context.Context, secretId, stage string) (Secret, error) {
+	if stage == "" {
+		stage = VERSION_STAGE_CURRENT
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	v, ok := p.values[cacheKey{secretId: secretId, stage: stage}]
+	if !ok {
+		return Secret{}, notFound(secretId, stage)
+	}
+
+	secret := localSecret(secretId, v)
+	secret.Stages = []string{stage}
+	return secret, nil
+}
+
+// localSecret 는 aws 밖에서 찾은 값을 Secret 으로 만듦
+func localSecret(secretId, value string) Secret {
+	return Secret{
+		Id:     secretId,
+		Stages: []string{VERSION_STAGE_CURRENT},
+		value:  value,
+	}
+}
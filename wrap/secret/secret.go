@@ -2,54 +2,201 @@ package secret
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/dalpengida/portfolio-go-aws/config"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	latest_version_for_aws_secretsmanager = "AWSCURRENT"
+	// VERSION_STAGE_* 는 secretsmanager 가 rotation 할 때 붙이는 staging label
+	// CURRENT 는 지금 쓰는 값, PENDING 은 rotation 중에 새로 만든 값, PREVIOUS 는 바로 전 값
+	VERSION_STAGE_CURRENT  = "AWSCURRENT"
+	VERSION_STAGE_PENDING  = "AWSPENDING"
+	VERSION_STAGE_PREVIOUS = "AWSPREVIOUS"
+
+	redacted = "[REDACTED]"
 )
 
+// Secret 은 secretsmanager 에서 가져 온 값
+// 값은 Value, Binary 로만 꺼낼 수 있고, 로그나 fmt 로 찍으면 값 대신 [REDACTED] 가 나옴
+type Secret struct {
+	Id          string    `json:"id"`
+	VersionId   string    `json:"version_id"`
+	Stages      []string  `json:"stages"`
+	CreatedDate time.Time `json:"created_date"`
+
+	value  string
+	binary []byte
+}
+
+// Value 는 문자열 값을 전달
+func (s Secret) Value() string {
+	return s.value
+}
+
+// Binary 는 바이너리로 저장 된 값을 전달, 문자열로 저장 되었으면 nil
+func (s Secret) Binary() []byte {
+	return s.binary
+}
+
+// String 는 값이 로그에 남지 않도록 가려서 전달
+func (s Secret) String() string {
+	return fmt.Sprintf("secret{id: %s, version: %s, stages: %v, value: %s}", s.Id, s.VersionId, s.Stages, redacted)
+}
+
+// Client 는 Manager 에서 사용하는 secretsmanager 기능
+// *secretsmanager.Client 가 그대로 구현하고 있고, 테스트에서는 fake 를 넣어서 사용할 수 있음
+type Client interface {
+	GetSecretValue(c context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// Manager 는 secretmanager 에서 값을 가져오는 기능들, Provider 를 구현
+// 패키지 함수 GetString, GetWithJsonUnmarshal 은 config.STAGE 에 맞는 Provider 로 동작
+type Manager struct {
+	// client 는 nil 이면 default client 를 사용
+	client Client
+
+	// cache 는 nil 이면 매번 secretsmanager 에서 가져 옴
+	cache *Cache
+}
+
 var (
-	client *secretsmanager.Client
+	defaultClient   Client
+	defaultClientMu sync.Mutex
+
+	// defaultCache 는 패키지 함수들이 같이 쓰는 cache, lambda 가 재사용 되는 동안 유지 됨
+	defaultCache = NewCache()
 )
 
-func init() {
-	client = secretsmanager.NewFromConfig(config.GetAws())
+func New() Manager {
+	return Manager{}
 }
 
-// GetString secretmanager 에서 값을 가져옴
-func GetString(c context.Context, secretId string) (string, error) {
-	r, err := client.GetSecretValue(c, &secretsmanager.GetSecretValueInput{
-		SecretId:     aws.String(secretId),
-		VersionStage: aws.String(latest_version_for_aws_secretsmanager),
-	})
-	if err != nil {
-		return "", fmt.Errorf("get secretmanager value failed, secret id : %s, %w", secretId, err)
+// NewWithClient 는 외부에서 만든 client 를 사용하는 Manager 를 생성
+func NewWithClient(client Client) Manager {
+	return Manager{client: client}
+}
+
+// NewFromConfig 는 aws config 로 client 를 만들어서 Manager 를 생성
+func NewFromConfig(cfg aws.Config) Manager {
+	return NewWithClient(secretsmanager.NewFromConfig(cfg))
+}
+
+// WithCache 는 값을 cache 에 기억 해 두고 쓰는 Manager 를 전달, 여러 Manager 가 같은 cache 를 써도 됨
+//
+//	manager := secret.New().WithCache(secret.NewCache().TTL(10 * time.Minute))
+func (m Manager) WithCache(cache *Cache) Manager {
+	m.cache = cache
+	return m
+}
+
+// SetDefaultClient 는 패키지 함수와 client 를 지정하지 않은 Manager 가 사용할 client 를 변경
+// 다른 곳의 값이 섞이지 않도록 패키지 함수들의 cache 도 비움
+func SetDefaultClient(client Client) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	defaultClient = client
+	defaultCache.Clear()
+}
+
+// getDefaultClient 는 default client 를 전달, 처음 호출 될 때 config.GetAws() 로 생성
+// import 할 때 init 에서 만들지 않기 위함
+func getDefaultClient() Client {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	if defaultClient == nil {
+		defaultClient = secretsmanager.NewFromConfig(config.GetAws())
+	}
+
+	return defaultClient
+}
+
+// api 는 Manager 가 사용할 client 를 전달, 지정한 client 가 없으면 default client
+func (m Manager) api() Client {
+	if m.client != nil {
+		return m.client
 	}
 
-	log.Debug().Interface("response", r).Msgf("secret manager get value success, secret id : %s", secretId)
+	return getDefaultClient()
+}
 
-	return *r.SecretString, nil
+// GetString 는 config.STAGE 에 맞는 provider 에서 값을 가져옴
+// lambda 에서는 secretmanager 에서 가져 와서 5분 동안 기억 해 두고, local 에서는 환경 변수나 secret 파일에서 가져옴
+func GetString(c context.Context, secretId string) (string, error) {
+	return getString(c, getDefaultProvider(), secretId)
 }
 
-// GetWithJsonUnmarshal secretmanger에서 값을 가져옴
+// GetWithJsonUnmarshal 는 config.STAGE 에 맞는 provider 에서 값을 가져옴
 // 들어가 있는 값이 json marshaling 이 되었을 경우 여기서 unmarshaling 해서 던져 줌
 func GetWithJsonUnmarshal(c context.Context, secretId string, obj interface{}) error {
-	r, err := GetString(c, secretId)
-	if err != nil {
-		return err
+	return getWithJsonUnmarshal(c, getDefaultProvider(), secretId, obj)
+}
+
+// Invalidate 는 패키지 함수들이 기억 해 둔 secret id 의 값을 잊음
+func Invalidate(secretId string) {
+	defaultCache.Invalidate(secretId)
+}
+
+// GetString secretmanager 에서 값을 가져옴
+func (m Manager) GetString(c context.Context, secretId string) (string, error) {
+	return getString(c, m, secretId)
+}
+
+// Get 는 secret id 의 stage 에 해당하는 값을 가져옴, cache 가 있으면 기억 해 둔 값을 씀
+// rotation 중에 새 값을 확인 하려면 VERSION_STAGE_PENDING, 바로 전 값은 VERSION_STAGE_PREVIOUS
+func (m Manager) Get(c context.Context, secretId, stage string) (Secret, error) {
+	if stage == "" {
+		stage = VERSION_STAGE_CURRENT
+	}
+	if m.cache == nil {
+		return m.fetch(c, secretId, stage)
 	}
 
-	err = json.Unmarshal([]byte(r), obj)
+	return m.cache.get(c, cacheKey{secretId: secretId, stage: stage}, func(c context.Context) (Secret, error) {
+		return m.fetch(c, secretId, stage)
+	})
+}
+
+// fetch 는 secretsmanager 에서 값을 가져옴, 응답에 값이 들어 있어서 로그에는 값을 뺀 정보만 남김
+func (m Manager) fetch(c context.Context, secretId, stage string) (Secret, error) {
+	r, err := m.api().GetSecretValue(c, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(secretId),
+		VersionStage: aws.String(stage),
+	})
+	var notFoundErr *types.ResourceNotFoundException
+	if errors.As(err, &notFoundErr) {
+		// Chain 에서 다음 provider 로 넘어 갈 수 있도록 없는 값은 common.ErrorNotFountItem 으로 전달
+		return Secret{}, fmt.Errorf("%w, %w", notFound(secretId, stage), err)
+	}
 	if err != nil {
-		return fmt.Errorf("data unmarshaling failed, %w", err)
+		return Secret{}, fmt.Errorf("get secretmanager value failed, secret id : %s, stage : %s, %w", secretId, stage, err)
 	}
 
-	return nil
+	secret := Secret{
+		Id:          secretId,
+		VersionId:   aws.ToString(r.VersionId),
+		Stages:      r.VersionStages,
+		CreatedDate: aws.ToTime(r.CreatedDate),
+		value:       aws.ToString(r.SecretString),
+		binary:      r.SecretBinary,
+	}
+
+	log.Debug().Interface("secret", secret).Msg("secret manager get value success")
+
+	return secret, nil
+}
+
+// GetWithJsonUnmarshal secretmanger에서 값을 가져옴
+// 들어가 있는 값이 json marshaling 이 되었을 경우 여기서 unmarshaling 해서 던져 줌
+func (m Manager) GetWithJsonUnmarshal(c context.Context, secretId string, obj interface{}) error {
+	return getWithJsonUnmarshal(c, m, secretId, obj)
 }
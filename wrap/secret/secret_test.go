@@ -2,9 +2,22 @@ package secret
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 
 	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/config"
 	"github.com/rs/zerolog/log"
 )
 
@@ -22,3 +35,248 @@ func Test_GetString(t *testing.T) {
 
 	log.Debug().Interface("value", v).Msgf(test_success_msg_format, common.FunctionName())
 }
+
+type fakeClient struct {
+	values map[string]string
+}
+
+func (f fakeClient) GetSecretValue(c context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	v, ok := f.values[*params.SecretId]
+	if !ok {
+		return nil, fmt.Errorf("secret not found, %s", *params.SecretId)
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(v)}, nil
+}
+
+// Test_GetWithClient 는 외부에서 넣어 준 client 로 값을 가져오는 기능 검사
+func Test_GetWithClient(t *testing.T) {
+	manager := NewWithClient(fakeClient{values: map[string]string{test_secret_id: `{"key":"value"}`}})
+
+	var v map[string]string
+	err := manager.GetWithJsonUnmarshal(context.TODO(), test_secret_id, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v["key"] != "value" {
+		t.Fatalf("secret value mismatch, %v", v)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// stagedClient 는 stage 별 값을 주고 몇번 불렸는지 세는 fake, gate 가 있으면 닫힐 때까지 기다림
+type stagedClient struct {
+	mu     sync.Mutex
+	values map[string]string
+	calls  int
+	gate   chan struct{}
+}
+
+func (f *stagedClient) GetSecretValue(c context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	if f.gate != nil {
+		<-f.gate
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	v, ok := f.values[*params.VersionStage]
+	if !ok {
+		return nil, fmt.Errorf("secret stage not found, %s", *params.VersionStage)
+	}
+	return &secretsmanager.GetSecretValueOutput{
+		SecretString:  aws.String(v),
+		VersionId:     aws.String(fmt.Sprintf("version-%d", f.calls)),
+		VersionStages: []string{*params.VersionStage},
+	}, nil
+}
+
+func (f *stagedClient) set(stage, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[stage] = value
+}
+
+func (f *stagedClient) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// Test_Cache 는 동시에 가져 와도 한번만 요청하고, 만료 전에는 뒤에서 미리 다시 가져 오고, stage 별로 따로 기억 하는지 검사
+func Test_Cache(t *testing.T) {
+	c := context.TODO()
+	client := &stagedClient{
+		values: map[string]string{VERSION_STAGE_CURRENT: "current", VERSION_STAGE_PENDING: "pending"},
+		gate:   make(chan struct{}),
+	}
+
+	var mu sync.Mutex
+	now := time.Now()
+	cache := NewCache().TTL(time.Minute).RefreshBefore(10 * time.Second)
+	cache.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	manager := NewWithClient(client).WithCache(cache)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := manager.GetString(c, test_secret_id)
+			if err == nil && v != "current" {
+				err = fmt.Errorf("secret value mismatch, %s", v)
+			}
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(client.gate)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.count() != 1 {
+		t.Fatalf("concurrent gets must be fetched once, calls : %d", client.count())
+	}
+
+	// stage 가 다르면 따로 가져 옴
+	pending, err := manager.Get(c, test_secret_id, VERSION_STAGE_PENDING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Value() != "pending" || client.count() != 2 {
+		t.Fatalf("pending stage must be fetched separately, %s, calls : %d", pending.Value(), client.count())
+	}
+
+	// 만료가 가까우면 기억 해 둔 값을 주고 뒤에서 새 값을 가져 옴
+	client.set(VERSION_STAGE_CURRENT, "rotated")
+	mu.Lock()
+	now = now.Add(55 * time.Second)
+	mu.Unlock()
+	if v, _ := manager.GetString(c, test_secret_id); v != "current" {
+		t.Fatalf("cached value must be returned while refreshing, %s", v)
+	}
+	for i := 0; i < 100; i++ {
+		if v, _ := manager.GetString(c, test_secret_id); v == "rotated" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v, _ := manager.GetString(c, test_secret_id); v != "rotated" {
+		t.Fatalf("value must be refreshed in background, %s", v)
+	}
+
+	// 로그에 값이 남지 않음
+	if s := fmt.Sprint(pending); strings.Contains(s, pending.Value()) || !strings.Contains(s, redacted) {
+		t.Fatalf("secret value must be redacted, %s", s)
+	}
+	data, err := json.Marshal(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"pending"`) {
+		t.Fatalf("secret value must not be marshaled, %s", data)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// notFoundClient 는 secretsmanager 에 secret 이 없을 때 처럼 동작하는 fake
+type notFoundClient struct{}
+
+func (notFoundClient) GetSecretValue(c context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	return nil, &types.ResourceNotFoundException{Message: aws.String("secret not found")}
+}
+
+// Test_Provider 는 환경 변수, 파일, 메모리에서 찾고, chain 이 없을 때만 다음 provider 로 넘어가는지 검사
+func Test_Provider(t *testing.T) {
+	c := context.TODO()
+	dir := t.TempDir()
+
+	jsonFile := filepath.Join(dir, "secret.json")
+	if err := os.WriteFile(jsonFile, []byte(`{"portfolio_id": {"key": "file"}, "token": "json-token"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	envFile := filepath.Join(dir, "secret.env")
+	if err := os.WriteFile(envFile, []byte("DB_PASSWORD=dotenv-password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_PORTFOLIO_API_KEY", "env-api-key")
+
+	memory := NewMemoryProvider().Set("memory", "memory-value").SetStage("memory", VERSION_STAGE_PENDING, "memory-pending")
+	chain := NewChain(
+		NewWithClient(notFoundClient{}),
+		NewEnvProvider(default_env_prefix),
+		NewFileProvider(jsonFile),
+		NewFileProvider(envFile),
+		NewFileProvider(filepath.Join(dir, "not-exists.json")),
+		memory,
+	)
+
+	cases := map[string]string{
+		"portfolio/api-key": "env-api-key",
+		"token":             "json-token",
+		"db-password":       "dotenv-password",
+		"memory":            "memory-value",
+	}
+	for id, want := range cases {
+		v, err := getString(c, chain, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != want {
+			t.Fatalf("secret value mismatch, id : %s, %s", id, v)
+		}
+	}
+
+	// object 값은 json 그대로 전달 되어서 unmarshaling 할 수 있음
+	SetDefaultProvider(chain)
+	defer SetDefaultProvider(nil)
+
+	var v map[string]string
+	if err := GetWithJsonUnmarshal(c, test_secret_id, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["key"] != "file" {
+		t.Fatalf("secret value mismatch, %v", v)
+	}
+
+	// 환경 변수, 파일에는 stage 가 없어서 메모리까지 가서 찾음
+	pending, err := chain.Get(c, "memory", VERSION_STAGE_PENDING)
+	if err != nil || pending.Value() != "memory-pending" {
+		t.Fatalf("pending stage must be found in memory, %v, %v", pending.Value(), err)
+	}
+
+	if _, err := chain.Get(c, "unknown", VERSION_STAGE_CURRENT); !errors.Is(err, common.ErrorNotFountItem) {
+		t.Fatalf("unknown secret must be not found, %v", err)
+	}
+
+	// stage 가 없으면 local 이 아니라서 secretsmanager 까지 찾아야 함
+	for stage, want := range map[string]bool{"": true, "dev": true, config.STAGE_LOCAL: false} {
+		p, ok := ProviderForStage(stage).(Chain)
+		if !ok {
+			t.Fatalf("provider must be chain outside lambda, stage : %s", stage)
+		}
+		_, manager := p.providers[len(p.providers)-1].(Manager)
+		if manager != want {
+			t.Fatalf("secretsmanager usage mismatch, stage : %s, %v", stage, manager)
+		}
+	}
+
+	// 없다는 오류가 아니면 다음 provider 로 넘어가지 않음
+	broken := NewChain(NewWithClient(&stagedClient{values: map[string]string{}}), memory)
+	if _, err := broken.Get(c, "memory", VERSION_STAGE_CURRENT); err == nil || errors.Is(err, common.ErrorNotFountItem) {
+		t.Fatalf("provider error must stop chain, %v", err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
@@ -0,0 +1,261 @@
+package sns
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// PublishBatch 는 entry 10개 이하, 전체 payload 256KB 이하 까지만 가능
+	max_count_batch_entry = 10
+
+	batch_failure_code_invalid = "InvalidEntry"
+	batch_failure_code_group   = "PreviousEntryFailed"
+)
+
+var (
+	// 다시 보내면 성공할 수도 있는 sns 오류 code
+	retryableErrorCodes = []string{"Throttled", "ThrottlingException", "InternalError", "KMSThrottling"}
+)
+
+// Entry 는 PublishBatch 로 보낼 메시지 하나
+type Entry struct {
+	// Id 는 batch 안에서 entry 를 구분하는 값, 비어 있으면 entries 의 index 를 사용
+	Id      string
+	Message string
+	Options []PublishOption
+}
+
+// BatchFailure 는 batch 요청에서 실패한 entry 하나
+type BatchFailure = common.BatchFailure
+
+// BatchError 는 batch 요청에서 실패한 entry 들을 모아서 전달하기 위한 오류
+type BatchError = common.BatchError
+
+// PublishBatch 는 여러 메시지를 10개, 256KB 제한에 맞게 나눠서 publish
+// 실패한 entry 만 backoff 를 주면서 재시도 하고, 끝내 실패한 entry 는 Id 와 사유를 *BatchError 로 전달
+// fifo topic 은 group 순서가 바뀌지 않도록 요청 한번에 group 마다 하나씩만 보내고,
+// entry 가 실패하면 같은 group 의 뒤 entry 는 보내지 않고 같이 실패로 전달 하기 때문에 실패한 entry 부터 순서대로 다시 보내면 됨
+func (n Notification) PublishBatch(c context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	arn, err := n.Arn(c)
+	if err != nil {
+		return err
+	}
+
+	batchErr := &BatchError{Op: "publish batch"}
+	requests := make([]types.PublishBatchRequestEntry, 0, len(entries))
+	ids := make(map[string]struct{}, len(entries))
+	// 앞의 entry 가 잘못되어서 더 보내면 안 되는 fifo group
+	blocked := make(map[string]struct{})
+	for i, entry := range entries {
+		id := entry.Id
+		if id == "" {
+			id = strconv.Itoa(i)
+		}
+		if _, ok := ids[id]; ok {
+			return fmt.Errorf("invalid entries, duplicated id %s", id)
+		}
+		ids[id] = struct{}{}
+
+		group := entryGroup(entry)
+		if _, ok := blocked[group]; ok && n.isFifo() {
+			batchErr.Failed = append(batchErr.Failed, groupFailure(id, group))
+			continue
+		}
+
+		request, err := n.batchRequest(c, id, entry)
+		if err != nil {
+			batchErr.Failed = append(batchErr.Failed, BatchFailure{
+				Id:          id,
+				Code:        batch_failure_code_invalid,
+				Message:     err.Error(),
+				SenderFault: true,
+				Err:         err,
+			})
+			if group != "" {
+				blocked[group] = struct{}{}
+			}
+			continue
+		}
+		requests = append(requests, request)
+	}
+
+	if n.isFifo() {
+		batchErr.Failed = append(batchErr.Failed, n.publishGroups(c, arn, requests)...)
+	} else {
+		for _, chunk := range chunkEntries(requests) {
+			batchErr.Failed = append(batchErr.Failed, n.publishChunk(c, arn, chunk)...)
+		}
+	}
+
+	if len(batchErr.Failed) > 0 {
+		log.Error().Interface("failed", batchErr.Failed).Interface("count", len(entries)).Msg("publish batch failed")
+		return batchErr
+	}
+
+	log.Debug().Interface("count", len(entries)).Msg("publish batch success")
+
+	return nil
+}
+
+// batchRequest 는 entry 의 옵션을 적용해서 sdk 의 entry 로 바꿔 줌
+func (n Notification) batchRequest(c context.Context, id string, entry Entry) (types.PublishBatchRequestEntry, error) {
+	var o publishOptions
+	for _, opt := range entry.Options {
+		opt(&o)
+	}
+
+	message, err := n.prepare(c, entry.Message, &o)
+	if err != nil {
+		return types.PublishBatchRequestEntry{}, err
+	}
+
+	request := types.PublishBatchRequestEntry{
+		Id:                aws.String(id),
+		Message:           aws.String(message),
+		MessageAttributes: o.attributes,
+	}
+	if o.groupId != "" {
+		request.MessageGroupId = aws.String(o.groupId)
+	}
+	if o.dedupId != "" {
+		request.MessageDeduplicationId = aws.String(o.dedupId)
+	}
+
+	return request, nil
+}
+
+// publishGroups 는 fifo topic 에서 group 마다 앞의 entry 가 끝난 뒤에 다음 entry 를 보냄
+// 한번에 group 별 맨 앞의 entry 들만 모아서 보내고, 실패한 entry 가 있으면 그 group 의 남은 entry 는 보내지 않고 실패로 돌려 줌
+func (n Notification) publishGroups(c context.Context, arn string, requests []types.PublishBatchRequestEntry) []BatchFailure {
+	var failed []BatchFailure
+
+	var order []string
+	queues := make(map[string][]types.PublishBatchRequestEntry)
+	for _, request := range requests {
+		group := aws.ToString(request.MessageGroupId)
+		if _, ok := queues[group]; !ok {
+			order = append(order, group)
+		}
+		queues[group] = append(queues[group], request)
+	}
+
+	for len(order) > 0 {
+		heads := make([]types.PublishBatchRequestEntry, 0, len(order))
+		for _, group := range order {
+			heads = append(heads, queues[group][0])
+		}
+
+		failedIds := make(map[string]struct{})
+		for _, chunk := range chunkEntries(heads) {
+			for _, f := range n.publishChunk(c, arn, chunk) {
+				failedIds[f.Id] = struct{}{}
+				failed = append(failed, f)
+			}
+		}
+
+		next := make([]string, 0, len(order))
+		for _, group := range order {
+			head, rest := queues[group][0], queues[group][1:]
+			if _, ok := failedIds[aws.ToString(head.Id)]; ok {
+				for _, request := range rest {
+					failed = append(failed, groupFailure(aws.ToString(request.Id), group))
+				}
+				continue
+			}
+			if len(rest) > 0 {
+				queues[group] = rest
+				next = append(next, group)
+			}
+		}
+		order = next
+	}
+
+	return failed
+}
+
+// entryGroup 는 entry 옵션에 지정한 group id, 지정하지 않았으면 비어 있음
+func entryGroup(entry Entry) string {
+	var o publishOptions
+	for _, opt := range entry.Options {
+		opt(&o)
+	}
+	return o.groupId
+}
+
+// groupFailure 는 같은 group 의 앞 entry 가 실패해서 보내지 않은 entry
+func groupFailure(id, group string) BatchFailure {
+	return BatchFailure{
+		Id:      id,
+		Code:    batch_failure_code_group,
+		Message: fmt.Sprintf("previous entry of group %s failed", group),
+	}
+}
+
+// publishChunk 는 제한 안에 들어오는 entry 들을 보내고, 실패한 entry 만 다시 보냄
+func (n Notification) publishChunk(c context.Context, arn string, entries []types.PublishBatchRequestEntry) []BatchFailure {
+	return common.SendBatch(c, entries, entryId, func(c context.Context, entries []types.PublishBatchRequestEntry) ([]BatchFailure, error) {
+		r, err := n.api().PublishBatch(c, &sns.PublishBatchInput{
+			TopicArn:                   aws.String(arn),
+			PublishBatchRequestEntries: entries,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return batchFailures(r.Failed), nil
+	}, isRetryable)
+}
+
+// batchFailures 는 sdk 의 실패 entry 를 BatchFailure 로 바꿈
+func batchFailures(results []types.BatchResultErrorEntry) []BatchFailure {
+	failed := make([]BatchFailure, 0, len(results))
+	for _, f := range results {
+		failed = append(failed, BatchFailure{
+			Id:          aws.ToString(f.Id),
+			Code:        aws.ToString(f.Code),
+			Message:     aws.ToString(f.Message),
+			SenderFault: f.SenderFault,
+		})
+	}
+	return failed
+}
+
+// isRetryable 는 다시 보내면 성공할 수도 있는 오류인지 확인
+func isRetryable(err error) bool {
+	return common.IsRetryable(err, retryableErrorCodes...)
+}
+
+// chunkEntries 는 10개, 256KB 제한에 맞게 entry 들을 순서대로 나눔
+func chunkEntries(entries []types.PublishBatchRequestEntry) [][]types.PublishBatchRequestEntry {
+	return common.ChunkBatch(entries, max_count_batch_entry, max_message_size, entrySize)
+}
+
+// entryId 는 batch 안에서 entry 를 구분하는 Id
+func entryId(entry types.PublishBatchRequestEntry) string {
+	return aws.ToString(entry.Id)
+}
+
+// entrySize 는 sns 가 계산하는 메시지 크기
+func entrySize(entry types.PublishBatchRequestEntry) int {
+	return messageSize(aws.ToString(entry.Message), entry.MessageAttributes)
+}
+
+// messageSize 는 message 와 message attribute 의 이름, 타입, 값을 더한 값
+func messageSize(message string, attributes map[string]types.MessageAttributeValue) int {
+	size := len(message)
+	for name, attr := range attributes {
+		size += len(name) + len(aws.ToString(attr.DataType)) + len(aws.ToString(attr.StringValue)) + len(attr.BinaryValue)
+	}
+	return size
+}
@@ -0,0 +1,64 @@
+package sns
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/dalpengida/portfolio-go-aws/config"
+)
+
+// Client 는 Notification 에서 사용하는 sns 기능들
+// *sns.Client 가 그대로 구현하고 있고, 테스트에서는 fake 를 넣어서 사용할 수 있음
+type Client interface {
+	CreateTopic(c context.Context, params *sns.CreateTopicInput, optFns ...func(*sns.Options)) (*sns.CreateTopicOutput, error)
+	DeleteTopic(c context.Context, params *sns.DeleteTopicInput, optFns ...func(*sns.Options)) (*sns.DeleteTopicOutput, error)
+	GetTopicAttributes(c context.Context, params *sns.GetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error)
+	SetTopicAttributes(c context.Context, params *sns.SetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.SetTopicAttributesOutput, error)
+	TagResource(c context.Context, params *sns.TagResourceInput, optFns ...func(*sns.Options)) (*sns.TagResourceOutput, error)
+	UntagResource(c context.Context, params *sns.UntagResourceInput, optFns ...func(*sns.Options)) (*sns.UntagResourceOutput, error)
+	ListTopics(c context.Context, params *sns.ListTopicsInput, optFns ...func(*sns.Options)) (*sns.ListTopicsOutput, error)
+	Publish(c context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+	PublishBatch(c context.Context, params *sns.PublishBatchInput, optFns ...func(*sns.Options)) (*sns.PublishBatchOutput, error)
+	ListSubscriptionsByTopic(c context.Context, params *sns.ListSubscriptionsByTopicInput, optFns ...func(*sns.Options)) (*sns.ListSubscriptionsByTopicOutput, error)
+	ConfirmSubscription(c context.Context, params *sns.ConfirmSubscriptionInput, optFns ...func(*sns.Options)) (*sns.ConfirmSubscriptionOutput, error)
+	Subscribe(c context.Context, params *sns.SubscribeInput, optFns ...func(*sns.Options)) (*sns.SubscribeOutput, error)
+	SetSubscriptionAttributes(c context.Context, params *sns.SetSubscriptionAttributesInput, optFns ...func(*sns.Options)) (*sns.SetSubscriptionAttributesOutput, error)
+	GetSubscriptionAttributes(c context.Context, params *sns.GetSubscriptionAttributesInput, optFns ...func(*sns.Options)) (*sns.GetSubscriptionAttributesOutput, error)
+	Unsubscribe(c context.Context, params *sns.UnsubscribeInput, optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error)
+}
+
+var (
+	defaultClient   Client
+	defaultClientMu sync.Mutex
+)
+
+// SetDefaultClient 는 client 를 따로 지정하지 않은 Notification 과 패키지 함수들이 사용할 client 를 변경
+func SetDefaultClient(client Client) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	defaultClient = client
+}
+
+// getDefaultClient 는 default client 를 전달, 처음 호출 될 때 config.GetAws() 로 생성
+// import 할 때 init 에서 만들지 않기 위함
+func getDefaultClient() Client {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	if defaultClient == nil {
+		defaultClient = sns.NewFromConfig(config.GetAws())
+	}
+
+	return defaultClient
+}
+
+// api 는 Notification 이 사용할 client 를 전달, 지정한 client 가 없으면 default client
+func (n Notification) api() Client {
+	if n.client != nil {
+		return n.client
+	}
+
+	return getDefaultClient()
+}
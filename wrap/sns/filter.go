@@ -0,0 +1,183 @@
+package sns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// FILTER_SCOPE_* 는 filter policy 를 message attribute 에 적용할지 body(json) 에 적용할지
+	FILTER_SCOPE_ATTRIBUTES = "MessageAttributes"
+	FILTER_SCOPE_BODY       = "MessageBody"
+
+	attribute_filter_policy       = "FilterPolicy"
+	attribute_filter_policy_scope = "FilterPolicyScope"
+
+	// body scope 에서 중첩된 key 를 나눌 때 사용
+	filter_path_separator = "."
+)
+
+// FilterPolicy 는 구독에 거는 filter policy, 조건에 맞는 메시지만 구독자에게 전달 됨
+// 같은 key 의 값들은 or, 서로 다른 key 는 and 로 묶임
+//
+//	policy := sns.NewFilterPolicy().Equals("event_type", "MODIFY").Equals("stage", "dev")
+//	policy := sns.NewBodyFilterPolicy().Prefix("user.user_id", "test-")
+type FilterPolicy struct {
+	scope string
+	rules map[string]interface{}
+}
+
+// NewFilterPolicy 는 message attribute 에 거는 filter policy 를 생성
+func NewFilterPolicy() *FilterPolicy {
+	return &FilterPolicy{scope: FILTER_SCOPE_ATTRIBUTES, rules: make(map[string]interface{})}
+}
+
+// NewBodyFilterPolicy 는 json body 에 거는 filter policy 를 생성, key 는 . 으로 중첩된 field 를 지정
+func NewBodyFilterPolicy() *FilterPolicy {
+	return &FilterPolicy{scope: FILTER_SCOPE_BODY, rules: make(map[string]interface{})}
+}
+
+// Scope 는 filter policy 가 적용 되는 곳
+func (p *FilterPolicy) Scope() string {
+	return p.scope
+}
+
+// Equals 는 값이 values 중에 하나면 통과
+func (p *FilterPolicy) Equals(key string, values ...string) *FilterPolicy {
+	for _, v := range values {
+		p.add(key, v)
+	}
+	return p
+}
+
+// Prefix 는 값이 prefix 로 시작하면 통과
+func (p *FilterPolicy) Prefix(key, prefix string) *FilterPolicy {
+	return p.add(key, map[string]interface{}{"prefix": prefix})
+}
+
+// AnythingBut 는 값이 values 가 아니면 통과
+func (p *FilterPolicy) AnythingBut(key string, values ...string) *FilterPolicy {
+	return p.add(key, map[string]interface{}{"anything-but": values})
+}
+
+// Numeric 는 숫자 값을 op(=, <, <=, >, >=) 로 비교해서 통과
+func (p *FilterPolicy) Numeric(key, op string, value float64) *FilterPolicy {
+	return p.add(key, map[string]interface{}{"numeric": []interface{}{op, value}})
+}
+
+// Exists 는 key 가 있는지(없는지) 로 통과
+func (p *FilterPolicy) Exists(key string, exists bool) *FilterPolicy {
+	return p.add(key, map[string]interface{}{"exists": exists})
+}
+
+// add 는 key 의 조건 목록에 조건을 추가, body scope 면 key 를 . 으로 나눠서 중첩 시킴
+func (p *FilterPolicy) add(key string, cond interface{}) *FilterPolicy {
+	rules := p.rules
+	if p.scope == FILTER_SCOPE_BODY {
+		path := strings.Split(key, filter_path_separator)
+		for _, k := range path[:len(path)-1] {
+			child, ok := rules[k].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				rules[k] = child
+			}
+			rules = child
+		}
+		key = path[len(path)-1]
+	}
+
+	conds, _ := rules[key].([]interface{})
+	rules[key] = append(conds, cond)
+
+	return p
+}
+
+// JSON 는 구독 attribute 로 넣을 filter policy json
+// 숫자 비교의 < > 가 \u003c 처럼 바뀌지 않도록 html escape 는 끔
+func (p *FilterPolicy) JSON() (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(p.rules); err != nil {
+		return "", fmt.Errorf("filter policy json marshaling failed, %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// Rules 는 filter policy 의 조건들을 map 으로 전달, GetFilterPolicy 로 읽어 온 값을 확인 할 때 사용
+func (p *FilterPolicy) Rules() map[string]interface{} {
+	return p.rules
+}
+
+// subscriptionAttributes 는 Subscribe 할 때 넣을 filter policy attribute
+func (p *FilterPolicy) subscriptionAttributes() (map[string]string, error) {
+	policy, err := p.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		attribute_filter_policy:       policy,
+		attribute_filter_policy_scope: p.scope,
+	}, nil
+}
+
+// SetFilterPolicy 는 구독의 filter policy 를 바꿈, 반영 되는 데 최대 15분 정도 걸릴 수 있음
+// scope 를 먼저 바꾸고 policy 를 바꾸기 때문에 scope 가 바뀌는 경우 잠깐 맞지 않는 상태가 될 수 있음
+func (n Notification) SetFilterPolicy(c context.Context, subscriptionArn string, policy *FilterPolicy) error {
+	if policy == nil {
+		return fmt.Errorf("invalid filter policy, policy is nil")
+	}
+
+	attributes, err := policy.subscriptionAttributes()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{attribute_filter_policy_scope, attribute_filter_policy} {
+		_, err := n.api().SetSubscriptionAttributes(c, &sns.SetSubscriptionAttributesInput{
+			SubscriptionArn: aws.String(subscriptionArn),
+			AttributeName:   aws.String(name),
+			AttributeValue:  aws.String(attributes[name]),
+		})
+		if err != nil {
+			return fmt.Errorf("set subscription attribute failed, arn : %s, attribute : %s, %w", subscriptionArn, name, err)
+		}
+	}
+
+	log.Debug().Interface("arn", subscriptionArn).Interface("policy", attributes).Msg("set filter policy success")
+
+	return nil
+}
+
+// GetFilterPolicy 는 구독에 걸린 filter policy 를 읽어 옴, 걸려 있지 않으면 nil
+func (n Notification) GetFilterPolicy(c context.Context, subscriptionArn string) (*FilterPolicy, error) {
+	r, err := n.api().GetSubscriptionAttributes(c, &sns.GetSubscriptionAttributesInput{
+		SubscriptionArn: aws.String(subscriptionArn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get subscription attributes failed, arn : %s, %w", subscriptionArn, err)
+	}
+
+	raw, ok := r.Attributes[attribute_filter_policy]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	policy := &FilterPolicy{scope: r.Attributes[attribute_filter_policy_scope]}
+	if policy.scope == "" {
+		policy.scope = FILTER_SCOPE_ATTRIBUTES
+	}
+	if err := json.Unmarshal([]byte(raw), &policy.rules); err != nil {
+		return nil, fmt.Errorf("filter policy json unmarshaling failed, %w", err)
+	}
+
+	return policy, nil
+}
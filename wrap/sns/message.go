@@ -0,0 +1,258 @@
+package sns
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/wrap/blob"
+)
+
+const (
+	message_type_notification = "Notification"
+)
+
+// Message 는 sns 가 sqs 로 보낸 메시지, raw message delivery 가 꺼져 있으면 sqs body 가 이 json 임
+// raw message delivery 가 켜져 있으면 body 가 publish 한 메시지 그대로라서 Message, MessageAttributes 만 채워지고 Raw 가 true
+type Message struct {
+	Type              string                      `json:"Type"`
+	MessageId         string                      `json:"MessageId"`
+	TopicArn          string                      `json:"TopicArn"`
+	Subject           string                      `json:"Subject,omitempty"`
+	Message           string                      `json:"Message"`
+	Timestamp         string                      `json:"Timestamp"`
+	SignatureVersion  string                      `json:"SignatureVersion,omitempty"`
+	Signature         string                      `json:"Signature,omitempty"`
+	SigningCertURL    string                      `json:"SigningCertURL,omitempty"`
+	UnsubscribeURL    string                      `json:"UnsubscribeURL,omitempty"`
+	Token             string                      `json:"Token,omitempty"` // SubscriptionConfirmation 메시지에만 있음, ConfirmSubscription 에 사용
+	MessageAttributes map[string]MessageAttribute `json:"MessageAttributes,omitempty"`
+
+	Raw bool `json:"-"`
+}
+
+// MessageAttribute 는 sns 메시지 json 에 들어 있는 message attribute, Binary 는 Value 가 base64
+type MessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// Attribute 는 이름에 해당하는 message attribute 값을 전달
+func (m Message) Attribute(name string) (string, bool) {
+	attr, ok := m.MessageAttributes[name]
+	return attr.Value, ok
+}
+
+// Decoder 는 sqs body 에서 sns 메시지를 꺼내 줌
+// Topic 을 지정하면 그 topic 에서 온 메시지만 받고, AllowRaw 를 하면 sns 메시지가 아닌 body 도 그대로 받음
+//
+//	decoder := sns.NewDecoder().Topic(config.AccountTopicName()).AllowRaw()
+//	lambda.Start(sqs.BatchHandler(decoder.RecordHandler(handler)))
+type Decoder struct {
+	topics map[string]struct{}
+	raw    bool
+	blobs  blob.Store
+}
+
+// NewDecoder 는 모든 topic 의 sns 메시지만 받는 Decoder 를 생성
+func NewDecoder() *Decoder {
+	return &Decoder{topics: make(map[string]struct{})}
+}
+
+// Topic 는 받을 topic 을 지정, topic arn 이나 이름 둘 다 가능
+func (d *Decoder) Topic(topics ...string) *Decoder {
+	for _, topic := range topics {
+		d.topics[topic] = struct{}{}
+	}
+	return d
+}
+
+// AllowRaw 는 raw message delivery 로 받거나 queue 로 바로 보낸 메시지도 받음
+// raw 메시지는 어느 topic 에서 왔는지 알 수 없어서 Topic 검사는 하지 않으니, queue policy 로 보낼 수 있는 곳을 막아야 함
+func (d *Decoder) AllowRaw() *Decoder {
+	d.raw = true
+	return d
+}
+
+// Blobs 는 sns 메시지를 풀고 나서 안의 메시지가 blob pointer 면 store 에서 원래 메시지로 바꿔 줌
+// sns 가 publish 할 때 offload 한 pointer 는 sns 메시지 json 안에 들어 있어서, sqs 에서 body 를 resolve 하는 것으로는 풀리지 않음
+// payload 는 지우지 않음, topic 의 구독자들이 같은 payload 를 읽기 때문에 누가 마지막인지 알 수 없어서 bucket 의 lifecycle 로 만료 시킴
+func (d *Decoder) Blobs(store blob.Store) *Decoder {
+	d.blobs = store
+	return d
+}
+
+// Decode 는 body 가 sns 메시지면 풀어서 전달, 아니면 raw 로 보고 attributes 를 붙여서 전달
+// 지정하지 않은 topic 에서 온 메시지면 common.ErrorUnexpectedTopic, raw 를 허용하지 않았는데 raw 면 common.ErrorUnsupportedMessage
+func (d *Decoder) Decode(body string, attributes map[string]MessageAttribute) (Message, error) {
+	m, ok := parseMessage(body)
+	if !ok {
+		if !d.raw {
+			return Message{}, fmt.Errorf("not sns notification, %w", common.ErrorUnsupportedMessage)
+		}
+		return Message{Message: body, MessageAttributes: attributes, Raw: true}, nil
+	}
+
+	if len(d.topics) > 0 && !d.expected(m.TopicArn) {
+		return Message{}, fmt.Errorf("invalid topic, [%s], %w", m.TopicArn, common.ErrorUnexpectedTopic)
+	}
+
+	return m, nil
+}
+
+// expected 는 지정한 topic 에서 온 메시지인지 확인
+func (d *Decoder) expected(arn string) bool {
+	if _, ok := d.topics[arn]; ok {
+		return true
+	}
+	_, ok := d.topics[topicName(arn)]
+	return ok
+}
+
+// parseMessage 는 body 가 sns notification json 인지 확인하고 풀어 줌
+func parseMessage(body string) (Message, bool) {
+	var m Message
+	if err := json.Unmarshal([]byte(body), &m); err != nil {
+		return Message{}, false
+	}
+	if m.Type != message_type_notification || m.TopicArn == "" || m.MessageId == "" {
+		return Message{}, false
+	}
+
+	return m, true
+}
+
+// RecordHandler 는 lambda 로 들어온 sqs record 의 body 를 sns 메시지 안의 메시지로, message attribute 를 sns 의 것으로 바꿔서 handler 에 넘김
+// 풀어 놓은 Message 는 FromContext 로 꺼낼 수 있고, Blobs 를 지정했으면 offload 된 메시지도 풀어서 넘김
+//
+//	lambda.Start(sqs.BatchHandler(decoder.Blobs(store).RecordHandler(handler)))
+func (d *Decoder) RecordHandler(handler func(c context.Context, record events.SQSMessage) error) func(c context.Context, record events.SQSMessage) error {
+	return func(c context.Context, record events.SQSMessage) error {
+		m, err := d.decode(c, record.Body, recordAttributes(record.MessageAttributes))
+		if err != nil {
+			return err
+		}
+
+		record.Body = m.Message
+		if !m.Raw {
+			record.MessageAttributes = toRecordAttributes(m.MessageAttributes)
+		}
+
+		return handler(NewContext(c, m), record)
+	}
+}
+
+// MessageHandler 는 RecordHandler 와 같은 일을 sqs.Consumer 에서 받은 메시지에 해 줌
+// consumer 는 sns 메시지를 풀기 전에 body 를 resolve 해서 sns 메시지 안의 pointer 는 Blobs 로 지정한 store 에서 가져옴
+func (d *Decoder) MessageHandler(handler func(c context.Context, m sqstypes.Message) error) func(c context.Context, m sqstypes.Message) error {
+	return func(c context.Context, msg sqstypes.Message) error {
+		m, err := d.decode(c, aws.ToString(msg.Body), messageAttributes(msg.MessageAttributes))
+		if err != nil {
+			return err
+		}
+
+		msg.Body = aws.String(m.Message)
+		if !m.Raw {
+			msg.MessageAttributes = toMessageAttributes(m.MessageAttributes)
+		}
+
+		return handler(NewContext(c, m), msg)
+	}
+}
+
+// decode 는 Decode 를 하고, Blobs 를 지정했으면 풀어 낸 메시지의 blob pointer 를 resolve
+func (d *Decoder) decode(c context.Context, body string, attributes map[string]MessageAttribute) (Message, error) {
+	m, err := d.Decode(body, attributes)
+	if err != nil || d.blobs == nil {
+		return m, err
+	}
+
+	message, _, err := blob.Resolve(c, d.blobs, m.Message)
+	if err != nil {
+		return Message{}, err
+	}
+	m.Message = message
+
+	return m, nil
+}
+
+type messageKey struct{}
+
+// NewContext 는 풀어 놓은 sns 메시지를 context 에 넣어 줌
+func NewContext(c context.Context, m Message) context.Context {
+	return context.WithValue(c, messageKey{}, m)
+}
+
+// FromContext 는 RecordHandler, MessageHandler 가 넣어 둔 sns 메시지를 전달
+func FromContext(c context.Context) (Message, bool) {
+	m, ok := c.Value(messageKey{}).(Message)
+	return m, ok
+}
+
+// recordAttributes 는 lambda sqs record 의 message attribute 를 sns 메시지 json 과 같은 모양으로 바꿈
+func recordAttributes(attributes map[string]events.SQSMessageAttribute) map[string]MessageAttribute {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	r := make(map[string]MessageAttribute, len(attributes))
+	for name, attr := range attributes {
+		r[name] = toAttribute(attr.DataType, attr.StringValue, attr.BinaryValue)
+	}
+	return r
+}
+
+// messageAttributes 는 sqs sdk 의 message attribute 를 sns 메시지 json 과 같은 모양으로 바꿈
+func messageAttributes(attributes map[string]sqstypes.MessageAttributeValue) map[string]MessageAttribute {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	r := make(map[string]MessageAttribute, len(attributes))
+	for name, attr := range attributes {
+		r[name] = toAttribute(aws.ToString(attr.DataType), attr.StringValue, attr.BinaryValue)
+	}
+	return r
+}
+
+func toAttribute(dataType string, value *string, binary []byte) MessageAttribute {
+	if dataType == attribute_type_binary {
+		return MessageAttribute{Type: dataType, Value: base64.StdEncoding.EncodeToString(binary)}
+	}
+	return MessageAttribute{Type: dataType, Value: aws.ToString(value)}
+}
+
+// toRecordAttributes 는 sns 메시지의 message attribute 를 lambda sqs record 의 것으로 바꿈
+func toRecordAttributes(attributes map[string]MessageAttribute) map[string]events.SQSMessageAttribute {
+	r := make(map[string]events.SQSMessageAttribute, len(attributes))
+	for name, attr := range attributes {
+		value, binary := fromAttribute(attr)
+		r[name] = events.SQSMessageAttribute{DataType: attr.Type, StringValue: value, BinaryValue: binary}
+	}
+	return r
+}
+
+// toMessageAttributes 는 sns 메시지의 message attribute 를 sqs sdk 의 것으로 바꿈
+func toMessageAttributes(attributes map[string]MessageAttribute) map[string]sqstypes.MessageAttributeValue {
+	r := make(map[string]sqstypes.MessageAttributeValue, len(attributes))
+	for name, attr := range attributes {
+		value, binary := fromAttribute(attr)
+		r[name] = sqstypes.MessageAttributeValue{DataType: aws.String(attr.Type), StringValue: value, BinaryValue: binary}
+	}
+	return r
+}
+
+// fromAttribute 는 Binary 면 base64 를 풀고, 아니면 문자열 그대로 전달
+func fromAttribute(attr MessageAttribute) (*string, []byte) {
+	if attr.Type == attribute_type_binary {
+		// sns 가 만든 값이라 base64 가 깨져 있을 일은 없음, 깨져 있으면 비워서 넘김
+		binary, _ := base64.StdEncoding.DecodeString(attr.Value)
+		return nil, binary
+	}
+	return aws.String(attr.Value), nil
+}
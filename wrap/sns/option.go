@@ -0,0 +1,150 @@
+package sns
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// message attribute 는 메시지 하나에 최대 10개
+	max_count_message_attribute = 10
+
+	attribute_type_string       = "String"
+	attribute_type_string_array = "String.Array"
+	attribute_type_number       = "Number"
+	attribute_type_binary       = "Binary"
+)
+
+// PublishOption 는 Publish 할 때 메시지에 붙일 값들을 지정
+// message attribute 는 구독의 filter policy 로 거를 때 사용
+//
+//	err := topic.Publish(c, message, sns.WithStringAttribute("event_type", "MODIFY"))
+type PublishOption func(*publishOptions)
+
+type publishOptions struct {
+	attributes   map[string]types.MessageAttributeValue
+	groupId      string
+	dedupId      string
+	contentDedup bool
+
+	// 옵션을 만들다 난 오류는 모아 뒀다가 Publish 할 때 전달
+	err error
+}
+
+// WithAttribute 는 message attribute 를 하나 추가, 같은 이름이면 덮어 씀
+func WithAttribute(name string, value types.MessageAttributeValue) PublishOption {
+	return func(o *publishOptions) {
+		if o.attributes == nil {
+			o.attributes = make(map[string]types.MessageAttributeValue)
+		}
+		o.attributes[name] = value
+	}
+}
+
+// WithStringAttribute 는 String 타입의 message attribute 를 추가
+func WithStringAttribute(name, value string) PublishOption {
+	return WithAttribute(name, types.MessageAttributeValue{
+		DataType:    aws.String(attribute_type_string),
+		StringValue: aws.String(value),
+	})
+}
+
+// WithStringArrayAttribute 는 String.Array 타입의 message attribute 를 추가, filter policy 에서 하나라도 맞으면 통과
+func WithStringArrayAttribute(name string, values ...string) PublishOption {
+	return func(o *publishOptions) {
+		data, err := json.Marshal(values)
+		if err != nil {
+			o.setErr(fmt.Errorf("string array attribute json marshaling failed, name : %s, %w", name, err))
+			return
+		}
+		WithAttribute(name, types.MessageAttributeValue{
+			DataType:    aws.String(attribute_type_string_array),
+			StringValue: aws.String(string(data)),
+		})(o)
+	}
+}
+
+// WithNumberAttribute 는 Number 타입의 message attribute 를 추가
+func WithNumberAttribute(name string, value int64) PublishOption {
+	return WithAttribute(name, types.MessageAttributeValue{
+		DataType:    aws.String(attribute_type_number),
+		StringValue: aws.String(strconv.FormatInt(value, 10)),
+	})
+}
+
+// WithBinaryAttribute 는 Binary 타입의 message attribute 를 추가, filter policy 에서는 무시 됨
+func WithBinaryAttribute(name string, value []byte) PublishOption {
+	return WithAttribute(name, types.MessageAttributeValue{
+		DataType:    aws.String(attribute_type_binary),
+		BinaryValue: value,
+	})
+}
+
+// WithGroupId 는 fifo topic 의 MessageGroupId 를 지정, 같은 group 안에서만 순서가 지켜짐
+func WithGroupId(id string) PublishOption {
+	return func(o *publishOptions) {
+		o.groupId = id
+	}
+}
+
+// WithDeduplicationId 는 fifo topic 의 MessageDeduplicationId 를 지정, 5분 안에 같은 값으로 들어온 메시지는 버려짐
+func WithDeduplicationId(id string) PublishOption {
+	return func(o *publishOptions) {
+		o.dedupId = id
+	}
+}
+
+// WithContentDeduplication 는 MessageDeduplicationId 를 안 보내고 message 의 sha-256 으로 중복을 거르게 함
+// topic 에 ContentBasedDeduplication 이 켜져 있어야 함
+func WithContentDeduplication() PublishOption {
+	return func(o *publishOptions) {
+		o.contentDedup = true
+	}
+}
+
+// setErr 는 처음 난 오류만 가지고 있음
+func (o *publishOptions) setErr(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// validate 는 옵션을 만들다 난 오류가 있는지와 attribute 수를 검사
+func (o *publishOptions) validate() error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(o.attributes) > max_count_message_attribute {
+		return fmt.Errorf("invalid message attributes, too many attributes %d", len(o.attributes))
+	}
+	return nil
+}
+
+// apply 는 옵션을 검사하고 fifo 여부에 맞게 group id, deduplication id 를 정해 줌
+// fifo topic 에서 group 을 지정하지 않으면 메시지 마다 새 group 을 사용해서 순서가 지켜지지 않음
+func (o *publishOptions) apply(fifo bool) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	if !fifo {
+		if o.groupId != "" || o.dedupId != "" || o.contentDedup {
+			return fmt.Errorf("invalid publish option, group and deduplication are only for fifo topic")
+		}
+		return nil
+	}
+
+	if o.groupId == "" {
+		o.groupId = uuid.NewString()
+	}
+	if o.dedupId == "" && !o.contentDedup {
+		o.dedupId = uuid.NewString()
+	}
+
+	return nil
+}
@@ -2,101 +2,219 @@ package sns
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
-	"github.com/dalpengida/portfolio-go-aws/config"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/wrap/blob"
 	"github.com/rs/zerolog/log"
 )
 
 const (
 	seperator = ":"
+
+	// sns 메시지 최대 크기, 넘으면 blob store 에 저장하고 pointer 를 보냄
+	max_message_size = 256 * 1024
+
+	// sns 의 경우 fifo 기능을 쓰기 위해서는 필수로 이름 끝에 fifo 가 붙어야 함
+	fifo_topic_suffix = ".fifo"
 )
 
 var (
-	client *sns.Client
-	topics map[string]string
+	// topicCaches 는 client 별 topic 이름과 arn 의 cache
+	// client 마다 다른 계정이나 region 을 보고 있을 수 있어서 같은 이름이라도 arn 을 따로 기억
+	topicCaches   = make(map[Client]*topicCache)
+	topicCachesMu sync.Mutex
 )
 
-type Notification struct {
-	topic     string
-	targetArn string
+// topicCache 는 client 하나로 찾은 topic 이름과 arn
+type topicCache struct {
+	mu   sync.RWMutex
+	arns map[string]string
+
+	// refreshMu 는 cache 에 없는 topic 을 여러 곳에서 동시에 찾을 때 ListTopics 를 한번씩만 돌게 하기 위함
+	refreshMu sync.Mutex
 }
 
-func init() {
-	topics = make(map[string]string, 0)
+// cacheFor 는 client 의 topic cache 를 전달, 없으면 만듦
+func cacheFor(client Client) *topicCache {
+	topicCachesMu.Lock()
+	defer topicCachesMu.Unlock()
 
-	client = sns.NewFromConfig(config.GetAws())
-	err := topicsToMap()
-	if err != nil {
-		panic(err)
+	cache, ok := topicCaches[client]
+	if !ok {
+		cache = &topicCache{arns: make(map[string]string)}
+		topicCaches[client] = cache
 	}
+	return cache
+}
+
+type Notification struct {
+	topic string
+
+	// client 는 nil 이면 default client 를 사용
+	client Client
+
+	// blobs 는 256KB 가 넘는 메시지를 대신 저장할 곳, nil 이면 큰 메시지는 보낼 수 없음
+	blobs blob.Store
 }
 
 func New(topic string) Notification {
-	arn, ok := topics[topic]
-	if !ok {
-		// aws topic 리스트에 없는 애를 호출을 하면 잘 못 들고 온거라고 판단을 함
-		// 잘 못된 topic 을 가져온 거라 panic
-		panic(fmt.Errorf("invlid topic, [%s] is not found topic list", topic))
-	}
+	return newNotification(nil, topic)
+}
+
+// NewWithClient 는 외부에서 만든 client 를 사용하는 Notification 을 생성
+// local 에 띄운 sns 호환 서버나 테스트용 fake 를 넣을 때 사용
+func NewWithClient(client Client, topic string) Notification {
+	return newNotification(client, topic)
+}
+
+// NewFromConfig 는 aws config 로 client 를 만들어서 Notification 을 생성
+func NewFromConfig(cfg aws.Config, topic string) Notification {
+	return newNotification(sns.NewFromConfig(cfg), topic)
+}
 
+// newNotification 는 Notification 을 생성, topic arn 은 처음 사용할 때 찾음
+// 예전에는 만들 때 topic 리스트를 받아 오고 없으면 panic 이 났는데, 이제는 Publish 같은 호출에서 오류로 전달
+func newNotification(client Client, topic string) Notification {
 	return Notification{
-		topic:     topic,
-		targetArn: arn,
+		topic:  topic,
+		client: client,
 	}
 }
 
-// Create topic 생성 함수
-// https://docs.aws.amazon.com/sns/latest/dg/sns-create-topic.html
-func Create(c context.Context) error {
-	r, err := client.CreateTopic(c, &sns.CreateTopicInput{
-		Name: aws.String("portfolio_test"),
-	})
-	if err != nil {
-		return fmt.Errorf("create sns topic failed, %w", err)
+// WithBlobStore 는 256KB 가 넘는 메시지를 store 에 저장하고 pointer 만 publish 하는 Notification 을 전달
+// 구독하는 sqs 쪽에서 같은 store 를 지정하면 원래 메시지를 가져옴
+func (n Notification) WithBlobStore(store blob.Store) Notification {
+	n.blobs = store
+	return n
+}
+
+// Arn 는 topic 의 arn 을 전달, cache 에 없으면 topic 리스트를 다시 받아서 찾음
+// 그래도 없으면 common.ErrorNotFoundTopic 을 전달
+func (n Notification) Arn(c context.Context) (string, error) {
+	client := n.api()
+	return cacheFor(client).resolve(c, client, n.topic)
+}
+
+// resolve 는 topic 이름으로 arn 을 찾음, cache 에 없으면 나중에 생성된 topic 일 수 있어서 한번 다시 받아 옴
+func (cache *topicCache) resolve(c context.Context, client Client, topic string) (string, error) {
+	if arn, ok := cache.get(topic); ok {
+		return arn, nil
 	}
 
-	log.Debug().Interface("response", r).Msg("sns topice create success")
+	cache.refreshMu.Lock()
+	defer cache.refreshMu.Unlock()
 
-	return nil
+	// 기다리는 동안 다른 곳에서 받아 왔을 수 있음
+	if arn, ok := cache.get(topic); ok {
+		return arn, nil
+	}
+	if err := cache.refresh(c, client); err != nil {
+		return "", err
+	}
+	if arn, ok := cache.get(topic); ok {
+		return arn, nil
+	}
+
+	return "", fmt.Errorf("invalid topic, [%s], %w", topic, common.ErrorNotFoundTopic)
 }
 
-// topicsToMap targetArn 을 가져오기 위하여 aws sns topic 정보들을 모두 가져와서 map으로 가지고 있음
-func topicsToMap() error {
-	r, err := client.ListTopics(context.TODO(), &sns.ListTopicsInput{})
-	if err != nil {
-		return fmt.Errorf("get list topics failed, %w", err)
-	}
+// refresh targetArn 을 가져오기 위하여 aws sns topic 정보들을 모두 가져와서 map으로 가지고 있음
+// 한번에 100개씩만 오기 때문에 NextToken 을 따라서 끝까지 받고, 지워진 topic 이 남지 않도록 map 을 통째로 바꿈
+func (cache *topicCache) refresh(c context.Context, client Client) error {
+	fresh := make(map[string]string)
 
-	log.Debug().Interface("response", r).Msg("get list topics success")
+	paginator := sns.NewListTopicsPaginator(client, &sns.ListTopicsInput{})
+	for paginator.HasMorePages() {
+		r, err := paginator.NextPage(c)
+		if err != nil {
+			return fmt.Errorf("get list topics failed, %w", err)
+		}
 
-	for _, v := range r.Topics {
-		sp := strings.Split(*v.TopicArn, seperator)
-		topics[sp[len(sp)-1]] = *v.TopicArn
+		for _, v := range r.Topics {
+			arn := aws.ToString(v.TopicArn)
+			fresh[topicName(arn)] = arn
+		}
 	}
 
-	log.Debug().Interface("topics", topics).Msg("")
+	cache.mu.Lock()
+	cache.arns = fresh
+	cache.mu.Unlock()
+
+	log.Debug().Interface("topics", fresh).Msg("get list topics success")
 
 	return nil
 }
 
-// getTargetArn 는 미리 만들어 놓은 topic map 에서 topic arn 을 찾아서 넘겨 줌
-func getTargetArn(topic string) (v string, ok bool) {
-	v, ok = topics[topic], false
-	return
+// topicName 는 topic arn 에서 이름만 잘라 줌
+func topicName(arn string) string {
+	sp := strings.Split(arn, seperator)
+	return sp[len(sp)-1]
+}
+
+// register 는 topic arn 을 바로 cache 에 넣음, 만든 topic 을 ListTopics 없이 바로 쓰기 위함
+func (cache *topicCache) register(arn string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.arns[topicName(arn)] = arn
+}
+
+// unregister 는 지운 topic 을 cache 에서 뺌
+func (cache *topicCache) unregister(topic string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.arns, topic)
+}
+
+// get 는 미리 만들어 놓은 topic map 에서 topic arn 을 찾아서 넘겨 줌
+func (cache *topicCache) get(topic string) (string, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	arn, ok := cache.arns[topic]
+	return arn, ok
 }
 
 // Publish 는 sns 로 메시지 전달 , 발행, 전송
 // Subject: , // 구독자가 email 로 구독을 했을 경우, 제목
 // PhoneNumber: , // 구독자가 sms 로 구독을 했을 경우, 수신자에 해당 하는 듯
-func (n Notification) Publish(c context.Context, message string) error {
-	r, err := client.Publish(c, &sns.PublishInput{
-		Message:   aws.String(message),
-		TargetArn: aws.String(n.targetArn),
-	})
+// message attribute, fifo 의 group id, deduplication id 는 PublishOption 으로 지정
+func (n Notification) Publish(c context.Context, message string, opts ...PublishOption) error {
+	var o publishOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	arn, err := n.Arn(c)
+	if err != nil {
+		return err
+	}
+	message, err = n.prepare(c, message, &o)
+	if err != nil {
+		return err
+	}
+
+	input := &sns.PublishInput{
+		Message:           aws.String(message),
+		TargetArn:         aws.String(arn),
+		MessageAttributes: o.attributes,
+	}
+	if o.groupId != "" {
+		input.MessageGroupId = aws.String(o.groupId) // FIFO 타입에서는 필수
+	}
+	if o.dedupId != "" {
+		input.MessageDeduplicationId = aws.String(o.dedupId) // FIFO 타입에서 content based deduplication 을 안 쓰면 필수
+	}
+
+	r, err := n.api().Publish(c, input)
 	if err != nil {
 		return fmt.Errorf("sns publish failed, %w", err)
 	}
@@ -106,31 +224,91 @@ func (n Notification) Publish(c context.Context, message string) error {
 	return nil
 }
 
+// isFifo 는 이름을 보고 fifo topic 인지 확인
+func (n Notification) isFifo() bool {
+	return strings.HasSuffix(n.topic, fifo_topic_suffix)
+}
+
+// prepare 는 옵션을 검사하고, 메시지가 256KB 를 넘으면 blob store 에 저장하고 pointer 를 메시지로 전달
+// pointer 는 blob key 가 매번 달라서 content based deduplication 이 안 되기 때문에, fifo topic 이면 원래 메시지의 sha-256 을 deduplication id 로 지정
+func (n Notification) prepare(c context.Context, message string, o *publishOptions) (string, error) {
+	if err := o.apply(n.isFifo()); err != nil {
+		return "", err
+	}
+	size := messageSize(message, o.attributes)
+	if size <= max_message_size {
+		return message, nil
+	}
+	if n.blobs == nil {
+		return "", fmt.Errorf("sns message too large, size : %d, %w", size, common.ErrorRequestParameterExceed)
+	}
+
+	pointer, err := blob.Offload(c, n.blobs, []byte(message))
+	if err != nil {
+		return "", err
+	}
+	WithNumberAttribute(blob.POINTER_ATTRIBUTE, int64(len(message)))(o)
+	if err := o.validate(); err != nil {
+		return "", err
+	}
+	if n.isFifo() && o.dedupId == "" {
+		sum := sha256.Sum256([]byte(message))
+		o.dedupId = hex.EncodeToString(sum[:])
+	}
+
+	return pointer, nil
+}
+
 // SubscribeTopic 지정 topic 에 구독을 신청을 함
 func (n Notification) SubscribeTopic(c context.Context, protocol, endpoint string) error {
+	_, err := n.Subscribe(c, protocol, endpoint, nil)
+	return err
+}
+
+// Subscribe 는 topic 에 구독을 신청하고 구독 arn 을 전달
+// policy 가 nil 이 아니면 filter policy 를 같이 걸어서 조건에 맞는 메시지만 받음
+func (n Notification) Subscribe(c context.Context, protocol, endpoint string, policy *FilterPolicy) (string, error) {
 	if !isValidSubscribeProtocol(protocol) {
-		return fmt.Errorf("invalid protocol, %s", protocol)
+		return "", fmt.Errorf("invalid protocol, %s", protocol)
+	}
+	arn, err := n.Arn(c)
+	if err != nil {
+		return "", err
 	}
 
-	r, err := client.Subscribe(c, &sns.SubscribeInput{
+	input := &sns.SubscribeInput{
 		// http, https, email, email-json, sms, sqs, application, lambda, firehouse
-		TopicArn:              aws.String(n.targetArn),
+		TopicArn:              aws.String(arn),
 		Protocol:              aws.String(protocol),
 		Endpoint:              aws.String(endpoint), // protocol 에 따라서 구독을 받을 대상 정보
 		ReturnSubscriptionArn: true,
-	})
+	}
+	if policy != nil {
+		input.Attributes, err = policy.subscriptionAttributes()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	r, err := n.api().Subscribe(c, input)
 	if err != nil {
-		return fmt.Errorf("topic subscribe failed, topic : %s , %w", n.topic, err)
+		return "", fmt.Errorf("topic subscribe failed, topic : %s , %w", n.topic, err)
 	}
 
 	log.Debug().Interface("response", r).Msg("subscribe success")
 
-	return nil
+	return aws.ToString(r.SubscriptionArn), nil
 }
 
-// UnsubscribeTopic 는 구독한 arn 를 가지고 구독 해제를 함
+// UnsubscribeTopic 는 구독한 arn 를 가지고 구독 해제를 함, default client 를 사용
+// client 를 지정한 Notification 이 있으면 Notification.Unsubscribe 를 사용
 func UnsubscribeTopic(c context.Context, subscribeArn string) error {
-	r, err := client.Unsubscribe(c, &sns.UnsubscribeInput{
+	return Notification{}.Unsubscribe(c, subscribeArn)
+}
+
+// Unsubscribe 는 구독한 arn 를 가지고 Notification 의 client 로 구독 해제를 함
+func (n Notification) Unsubscribe(c context.Context, subscribeArn string) error {
+	r, err := n.api().Unsubscribe(c, &sns.UnsubscribeInput{
 		SubscriptionArn: aws.String(subscribeArn),
 	})
 	if err != nil {
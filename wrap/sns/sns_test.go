@@ -1,13 +1,25 @@
 package sns
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/dalpengida/portfolio-go-aws/common"
 	"github.com/dalpengida/portfolio-go-aws/config"
+	"github.com/dalpengida/portfolio-go-aws/wrap/blob"
 	"github.com/dalpengida/portfolio-go-aws/wrap/sqs"
 	"github.com/rs/zerolog/log"
 )
@@ -17,7 +29,7 @@ const (
 )
 
 func Test_CreateTopic(t *testing.T) {
-	err := Create(context.TODO())
+	_, err := New("portfolio_test").Create(context.TODO())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -29,7 +41,13 @@ func Test_CreateTopic(t *testing.T) {
 func Test_Publish(t *testing.T) {
 	c := sns.NewFromConfig(config.GetAws())
 
-	arn, _ := getTargetArn("portfolio")
+	cache := cacheFor(c)
+	err := cache.refresh(context.TODO(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arn, _ := cache.get("portfolio")
 	r, err := c.Publish(context.TODO(), &sns.PublishInput{
 		Message: aws.String("test"),
 		// Subject: , // 구독자가 email 로 구독을 했을 경우, 제목
@@ -61,3 +79,521 @@ func Test_SubScribe(t *testing.T) {
 	log.Debug().Msgf("[%s] success", common.FunctionName())
 
 }
+
+type fakeClient struct {
+	Client
+	topicArns []string
+	published []string
+
+	mu        sync.Mutex
+	listCalls int
+
+	// 마지막으로 publish 한 message attribute
+	attributes map[string]types.MessageAttributeValue
+	// 구독 arn 별 attribute
+	subscriptions map[string]map[string]string
+
+	// PublishBatch 로 받은 요청들, entry Id 별로 일시적으로 실패 시킬 횟수, sender fault 로 거절할 entry Id
+	batches      []*sns.PublishBatchInput
+	batchFaults  map[string]int
+	batchRejects map[string]bool
+
+	// CreateTopic 으로 받은 요청들
+	created []*sns.CreateTopicInput
+
+	// Unsubscribe 호출 수
+	unsubscribed int
+
+	// Publish 로 받은 deduplication id 들
+	dedupIds []string
+}
+
+func (f *fakeClient) Unsubscribe(c context.Context, params *sns.UnsubscribeInput, optFns ...func(*sns.Options)) (*sns.UnsubscribeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.unsubscribed++
+	return &sns.UnsubscribeOutput{}, nil
+}
+
+func (f *fakeClient) PublishBatch(c context.Context, params *sns.PublishBatchInput, optFns ...func(*sns.Options)) (*sns.PublishBatchOutput, error) {
+	if len(params.PublishBatchRequestEntries) > max_count_batch_entry {
+		return nil, fmt.Errorf("too many entries in batch request, %d", len(params.PublishBatchRequestEntries))
+	}
+	f.batches = append(f.batches, params)
+
+	r := &sns.PublishBatchOutput{}
+	for _, e := range params.PublishBatchRequestEntries {
+		id := aws.ToString(e.Id)
+		if f.batchRejects[id] {
+			r.Failed = append(r.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InvalidParameter"), SenderFault: true})
+			continue
+		}
+		if n := f.batchFaults[id]; n > 0 {
+			f.batchFaults[id] = n - 1
+			r.Failed = append(r.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InternalError")})
+			continue
+		}
+		f.published = append(f.published, id)
+		r.Successful = append(r.Successful, types.PublishBatchResultEntry{Id: e.Id, MessageId: aws.String("id-" + id)})
+	}
+	return r, nil
+}
+
+func (f *fakeClient) CreateTopic(c context.Context, params *sns.CreateTopicInput, optFns ...func(*sns.Options)) (*sns.CreateTopicOutput, error) {
+	f.created = append(f.created, params)
+	arn := "arn:aws:sns:ap-northeast-2:000000000000:" + aws.ToString(params.Name)
+	f.topicArns = append(f.topicArns, arn)
+	return &sns.CreateTopicOutput{TopicArn: aws.String(arn)}, nil
+}
+
+func (f *fakeClient) DeleteTopic(c context.Context, params *sns.DeleteTopicInput, optFns ...func(*sns.Options)) (*sns.DeleteTopicOutput, error) {
+	for i, arn := range f.topicArns {
+		if arn == aws.ToString(params.TopicArn) {
+			f.topicArns = append(f.topicArns[:i], f.topicArns[i+1:]...)
+			return &sns.DeleteTopicOutput{}, nil
+		}
+	}
+	return nil, fmt.Errorf("not found topic")
+}
+
+// ListSubscriptionsByTopic 는 구독을 하나씩 나눠서 NextToken 과 같이 전달
+func (f *fakeClient) ListSubscriptionsByTopic(c context.Context, params *sns.ListSubscriptionsByTopicInput, optFns ...func(*sns.Options)) (*sns.ListSubscriptionsByTopicOutput, error) {
+	var arns []string
+	for arn := range f.subscriptions {
+		if strings.HasPrefix(arn, aws.ToString(params.TopicArn)+":") {
+			arns = append(arns, arn)
+		}
+	}
+	sort.Strings(arns)
+
+	start, _ := strconv.Atoi(aws.ToString(params.NextToken))
+	r := &sns.ListSubscriptionsByTopicOutput{}
+	if start < len(arns) {
+		r.Subscriptions = []types.Subscription{{SubscriptionArn: aws.String(arns[start]), TopicArn: params.TopicArn}}
+	}
+	if start+1 < len(arns) {
+		r.NextToken = aws.String(strconv.Itoa(start + 1))
+	}
+	return r, nil
+}
+
+func (f *fakeClient) Subscribe(c context.Context, params *sns.SubscribeInput, optFns ...func(*sns.Options)) (*sns.SubscribeOutput, error) {
+	if f.subscriptions == nil {
+		f.subscriptions = make(map[string]map[string]string)
+	}
+	arn := fmt.Sprintf("%s:subscription-%d", *params.TopicArn, len(f.subscriptions))
+	f.subscriptions[arn] = map[string]string{}
+	for k, v := range params.Attributes {
+		f.subscriptions[arn][k] = v
+	}
+	return &sns.SubscribeOutput{SubscriptionArn: aws.String(arn)}, nil
+}
+
+func (f *fakeClient) SetSubscriptionAttributes(c context.Context, params *sns.SetSubscriptionAttributesInput, optFns ...func(*sns.Options)) (*sns.SetSubscriptionAttributesOutput, error) {
+	attributes, ok := f.subscriptions[*params.SubscriptionArn]
+	if !ok {
+		return nil, fmt.Errorf("not found subscription")
+	}
+	attributes[*params.AttributeName] = *params.AttributeValue
+	return &sns.SetSubscriptionAttributesOutput{}, nil
+}
+
+func (f *fakeClient) GetSubscriptionAttributes(c context.Context, params *sns.GetSubscriptionAttributesInput, optFns ...func(*sns.Options)) (*sns.GetSubscriptionAttributesOutput, error) {
+	attributes, ok := f.subscriptions[*params.SubscriptionArn]
+	if !ok {
+		return nil, fmt.Errorf("not found subscription")
+	}
+	return &sns.GetSubscriptionAttributesOutput{Attributes: attributes}, nil
+}
+
+// ListTopics 는 sns 처럼 100개씩 나눠서 NextToken 과 같이 전달
+func (f *fakeClient) ListTopics(c context.Context, params *sns.ListTopicsInput, optFns ...func(*sns.Options)) (*sns.ListTopicsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.listCalls++
+	start, _ := strconv.Atoi(aws.ToString(params.NextToken))
+	end := min(start+100, len(f.topicArns))
+
+	r := &sns.ListTopicsOutput{}
+	for _, arn := range f.topicArns[start:end] {
+		r.Topics = append(r.Topics, types.Topic{TopicArn: aws.String(arn)})
+	}
+	if end < len(f.topicArns) {
+		r.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return r, nil
+}
+
+func (f *fakeClient) Publish(c context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.published = append(f.published, *params.TargetArn)
+	f.attributes = params.MessageAttributes
+	f.dedupIds = append(f.dedupIds, aws.ToString(params.MessageDeduplicationId))
+	return &sns.PublishOutput{MessageId: aws.String("id")}, nil
+}
+
+// Test_PublishWithClient 는 외부에서 넣어 준 client 로 topic arn 을 찾아서 publish 하는 기능 검사
+func Test_PublishWithClient(t *testing.T) {
+	arn := "arn:aws:sns:ap-northeast-2:000000000000:portfolio-fake"
+	client := &fakeClient{topicArns: []string{arn}}
+
+	topic := NewWithClient(client, "portfolio-fake")
+	err := topic.Publish(context.TODO(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.published) != 1 || client.published[0] != arn {
+		t.Fatalf("publish target mismatch, %v", client.published)
+	}
+
+	log.Debug().Msgf("[%s] success", common.FunctionName())
+}
+
+// Test_ResolveTopic 는 topic 이 100개가 넘어도 찾고, 동시에 찾아도 한번만 조회하고, 없는 topic 은 panic 대신 오류를 주는지 검사
+func Test_ResolveTopic(t *testing.T) {
+	client := &fakeClient{}
+	for i := 0; i < 250; i++ {
+		client.topicArns = append(client.topicArns, fmt.Sprintf("arn:aws:sns:ap-northeast-2:000000000000:portfolio-%d", i))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			arn, err := NewWithClient(client, "portfolio-249").Arn(context.TODO())
+			if err == nil && arn != client.topicArns[249] {
+				err = fmt.Errorf("arn mismatch, %s", arn)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.listCalls != 3 {
+		t.Fatalf("topics must be listed once with 3 pages, calls : %d", client.listCalls)
+	}
+
+	err := NewWithClient(client, "unknown").Publish(context.TODO(), "test")
+	if !errors.Is(err, common.ErrorNotFoundTopic) {
+		t.Fatalf("unknown topic must be not found, %v", err)
+	}
+
+	// 같은 이름이라도 client 가 다르면 그 client 에서 찾은 arn 을 사용
+	other := &fakeClient{topicArns: []string{"arn:aws:sns:us-east-1:111111111111:portfolio-249"}}
+	arn, err := NewWithClient(other, "portfolio-249").Arn(context.TODO())
+	if err != nil || arn != other.topicArns[0] {
+		t.Fatalf("topic arn must be cached per client, %s, %v", arn, err)
+	}
+
+	// 구독 해제도 지정한 client 로 보냄
+	if err := NewWithClient(other, "portfolio-249").Unsubscribe(context.TODO(), "arn:aws:sns:us-east-1:111111111111:portfolio-249:sub"); err != nil {
+		t.Fatal(err)
+	}
+	if other.unsubscribed != 1 {
+		t.Fatalf("unsubscribe must use injected client, %d", other.unsubscribed)
+	}
+
+	log.Debug().Msgf("[%s] success", common.FunctionName())
+}
+
+// Test_FilterPolicy 는 message attribute 를 붙여서 publish 하고, 구독에 filter policy 를 걸고 바꾸고 읽는 기능 검사
+func Test_FilterPolicy(t *testing.T) {
+	c := context.TODO()
+	arn := "arn:aws:sns:ap-northeast-2:000000000000:portfolio-filter"
+	client := &fakeClient{topicArns: []string{arn}}
+	topic := NewWithClient(client, "portfolio-filter")
+
+	err := topic.Publish(c, "test", WithStringAttribute("event_type", "MODIFY"), WithStringArrayAttribute("tags", "a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(client.attributes["event_type"].StringValue) != "MODIFY" || aws.ToString(client.attributes["tags"].StringValue) != `["a","b"]` {
+		t.Fatalf("message attributes must be published, %v", client.attributes)
+	}
+
+	// 옵션을 만들다 난 오류는 panic 하지 않고 publish 에서 전달
+	failed := errors.New("option failed")
+	err = topic.Publish(c, "test", func(o *publishOptions) { o.setErr(failed) })
+	if !errors.Is(err, failed) {
+		t.Fatalf("option error must be returned, %v", err)
+	}
+
+	subscriptionArn, err := topic.Subscribe(c, "sqs", "arn:aws:sqs:ap-northeast-2:000000000000:portfolio", NewFilterPolicy().Equals("event_type", "MODIFY", "INSERT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy := client.subscriptions[subscriptionArn][attribute_filter_policy]; policy != `{"event_type":["MODIFY","INSERT"]}` {
+		t.Fatalf("filter policy must be set on subscribe, %s", policy)
+	}
+
+	err = topic.SetFilterPolicy(c, subscriptionArn, NewBodyFilterPolicy().Prefix("user.user_id", "test-").Numeric("last_login", ">", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := topic.GetFilterPolicy(c, subscriptionArn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := policy.JSON()
+	if policy.Scope() != FILTER_SCOPE_BODY || data != `{"last_login":[{"numeric":[">",0]}],"user":{"user_id":[{"prefix":"test-"}]}}` {
+		t.Fatalf("body filter policy mismatch, scope : %s, policy : %s", policy.Scope(), data)
+	}
+
+	log.Debug().Msgf("[%s] success", common.FunctionName())
+}
+
+// Test_PublishBatch 는 10개씩 나눠서 보내고, fifo topic 이면 group id 를 붙이고, 실패한 entry 만 재시도 하는지 검사
+// fifo topic 에서는 entry 가 실패하면 같은 group 의 뒤 entry 는 보내지 않아야 함
+func Test_PublishBatch(t *testing.T) {
+	c := context.TODO()
+	arn := "arn:aws:sns:ap-northeast-2:000000000000:portfolio-batch.fifo"
+	client := &fakeClient{
+		topicArns:    []string{arn, "arn:aws:sns:ap-northeast-2:000000000000:portfolio-batch"},
+		batchFaults:  map[string]int{"5": 2},
+		batchRejects: map[string]bool{"7": true},
+	}
+	topic := NewWithClient(client, "portfolio-batch.fifo")
+
+	var entries []Entry
+	for i := 0; i < 23; i++ {
+		entries = append(entries, Entry{
+			Message: fmt.Sprintf("message-%d", i),
+			Options: []PublishOption{WithGroupId(fmt.Sprintf("user-%d", i%3))},
+		})
+	}
+
+	err := topic.PublishBatch(c, entries)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failed) != 6 {
+		t.Fatalf("rejected entry and later entries of its group must fail, %v", err)
+	}
+	for _, f := range batchErr.Failed {
+		if f.Id == "7" && !f.SenderFault {
+			t.Fatalf("rejected entry must be sender fault, %v", f)
+		}
+		if id, _ := strconv.Atoi(f.Id); id%3 != 1 || id < 7 {
+			t.Fatalf("only user-1 entries from rejected entry must fail, %v", f)
+		}
+	}
+	if len(client.published) != 17 {
+		t.Fatalf("17 entries must be published, published : %d", len(client.published))
+	}
+
+	// 같은 group 은 보낸 순서가 entry 순서와 같아야 하고, 요청 하나에 group 마다 하나씩만 들어가야 함
+	last := map[int]int{}
+	for _, id := range client.published {
+		i, _ := strconv.Atoi(id)
+		if prev, ok := last[i%3]; ok && prev > i {
+			t.Fatalf("group order must be kept, published : %v", client.published)
+		}
+		last[i%3] = i
+	}
+	for _, batch := range client.batches {
+		groups := map[string]struct{}{}
+		for _, e := range batch.PublishBatchRequestEntries {
+			if e.MessageGroupId == nil || e.MessageDeduplicationId == nil {
+				t.Fatalf("fifo entry must have group and deduplication id, %v", e)
+			}
+			if _, ok := groups[*e.MessageGroupId]; ok {
+				t.Fatalf("one request must have one entry per group, %v", batch.PublishBatchRequestEntries)
+			}
+			groups[*e.MessageGroupId] = struct{}{}
+		}
+	}
+
+	// fifo 가 아닌 topic 에는 group id 를 줄 수 없음
+	err = NewWithClient(client, "portfolio-batch").PublishBatch(c, entries[:1])
+	if !errors.As(err, &batchErr) || batchErr.Failed[0].Code != batch_failure_code_invalid {
+		t.Fatalf("group id on standard topic must be invalid, %v", err)
+	}
+
+	log.Debug().Msgf("[%s] success", common.FunctionName())
+}
+
+// Test_Decoder 는 sqs body 의 sns 메시지를 풀고, topic 을 검사하고, raw 메시지를 구분 하는지 검사
+func Test_Decoder(t *testing.T) {
+	c := context.TODO()
+	arn := "arn:aws:sns:ap-northeast-2:000000000000:topic-test-account.fifo"
+	body, err := json.Marshal(Message{
+		Type:      "Notification",
+		MessageId: "sns-message-id",
+		TopicArn:  arn,
+		Message:   `{"user_id":"test"}`,
+		MessageAttributes: map[string]MessageAttribute{
+			"event_type": {Type: "String", Value: "MODIFY"},
+			"raw":        {Type: "Binary", Value: "AQI="},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got events.SQSMessage
+	var gotMessage Message
+	handler := NewDecoder().Topic("topic-test-account.fifo").RecordHandler(func(c context.Context, record events.SQSMessage) error {
+		got = record
+		gotMessage, _ = FromContext(c)
+		return nil
+	})
+	if err := handler(c, events.SQSMessage{MessageId: "sqs-message-id", Body: string(body)}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Body != `{"user_id":"test"}` || gotMessage.TopicArn != arn || gotMessage.Raw {
+		t.Fatalf("sns message must be unwrapped, body : %s, message : %v", got.Body, gotMessage)
+	}
+	if aws.ToString(got.MessageAttributes["event_type"].StringValue) != "MODIFY" || !bytes.Equal(got.MessageAttributes["raw"].BinaryValue, []byte{1, 2}) {
+		t.Fatalf("sns message attributes must be exposed, %v", got.MessageAttributes)
+	}
+
+	// 지정하지 않은 topic 에서 온 메시지는 거름
+	_, err = NewDecoder().Topic("topic-test-other").Decode(string(body), nil)
+	if !errors.Is(err, common.ErrorUnexpectedTopic) {
+		t.Fatalf("message from other topic must be rejected, %v", err)
+	}
+
+	// raw 메시지는 AllowRaw 를 해야 받고, sqs 의 message attribute 를 그대로 사용
+	attributes := map[string]MessageAttribute{"event_type": {Type: "String", Value: "MODIFY"}}
+	if _, err := NewDecoder().Decode(`{"user_id":"test"}`, attributes); !errors.Is(err, common.ErrorUnsupportedMessage) {
+		t.Fatalf("raw message must be rejected, %v", err)
+	}
+	m, err := NewDecoder().Topic(arn).AllowRaw().Decode(`{"user_id":"test"}`, attributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.Attribute("event_type"); !m.Raw || m.Message != `{"user_id":"test"}` || v != "MODIFY" {
+		t.Fatalf("raw message must be passed as it is, %v", m)
+	}
+
+	log.Debug().Msgf("[%s] success", common.FunctionName())
+}
+
+// Test_DecoderBlobs 는 sns 메시지 안에 들어 있는 blob pointer 를 sns 메시지를 풀고 나서 resolve 하는지 검사
+func Test_DecoderBlobs(t *testing.T) {
+	c := context.TODO()
+	store, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pointer, err := blob.Offload(c, store, []byte(`{"user_id":"test"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(Message{
+		Type:      "Notification",
+		MessageId: "sns-message-id",
+		TopicArn:  "arn:aws:sns:ap-northeast-2:000000000000:topic-test-account.fifo",
+		Message:   pointer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// consumer 는 sns 메시지를 풀기 전에 resolve 해서 pointer 가 그대로 남아 있다가 decoder 에서 풀림
+	var got string
+	handler := NewDecoder().Blobs(store).MessageHandler(func(c context.Context, m sqstypes.Message) error {
+		got = aws.ToString(m.Body)
+		return nil
+	})
+	if err := handler(c, sqstypes.Message{MessageId: aws.String("sqs-message-id"), Body: aws.String(string(body))}); err != nil {
+		t.Fatal(err)
+	}
+	if got != `{"user_id":"test"}` {
+		t.Fatalf("blob pointer in sns message must be resolved, %s", got)
+	}
+
+	// store 를 지정하지 않으면 pointer 를 그대로 넘김
+	err = NewDecoder().RecordHandler(func(c context.Context, record events.SQSMessage) error {
+		got = record.Body
+		return nil
+	})(c, events.SQSMessage{Body: string(body)})
+	if err != nil || got != pointer {
+		t.Fatalf("blob pointer must be passed without store, %s, %v", got, err)
+	}
+
+	log.Debug().Msgf("[%s] success", common.FunctionName())
+}
+
+// Test_PublishLargeMessage 는 256KB 가 넘는 메시지를 blob store 에 저장하고, fifo topic 이면 원래 메시지로 중복을 거르게 하는지 검사
+func Test_PublishLargeMessage(t *testing.T) {
+	c := context.TODO()
+	store, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	arn := "arn:aws:sns:ap-northeast-2:000000000000:portfolio-large.fifo"
+	client := &fakeClient{topicArns: []string{arn}}
+	topic := NewWithClient(client, "portfolio-large.fifo").WithBlobStore(store)
+
+	large := strings.Repeat("a", 300*1024)
+	for i := 0; i < 2; i++ {
+		if err := topic.Publish(c, large, WithGroupId("pk"), WithContentDeduplication()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := client.attributes[blob.POINTER_ATTRIBUTE]; !ok {
+		t.Fatalf("offloaded message must have pointer attribute, %v", client.attributes)
+	}
+	if len(client.dedupIds) != 2 || client.dedupIds[0] == "" || client.dedupIds[0] != client.dedupIds[1] {
+		t.Fatalf("same message must have same deduplication id, %v", client.dedupIds)
+	}
+
+	log.Debug().Msgf("[%s] success", common.FunctionName())
+}
+
+// Test_TopicLifecycle 는 만든 topic 을 ListTopics 없이 바로 쓰고, 구독을 끝까지 조회하고, 지우면 cache 에서도 빠지는지 검사
+func Test_TopicLifecycle(t *testing.T) {
+	c := context.TODO()
+	client := &fakeClient{}
+	topic := NewWithClient(client, "portfolio-lifecycle.fifo")
+
+	arn, err := topic.Create(c, WithDisplayName("lifecycle"), WithKmsKey("alias/aws/sns"), WithContentBasedDeduplication(), WithTag("stage", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := client.created[0]
+	if input.Attributes[TOPIC_ATTRIBUTE_FIFO_TOPIC] != "true" || input.Attributes[TOPIC_ATTRIBUTE_KMS_MASTER_KEY_ID] != "alias/aws/sns" || len(input.Tags) != 1 {
+		t.Fatalf("fifo topic must be created with attributes, %v", input)
+	}
+
+	if err := topic.Publish(c, "test", WithGroupId("user")); err != nil {
+		t.Fatal(err)
+	}
+	if client.listCalls != 0 {
+		t.Fatalf("created topic must be cached, list calls : %d", client.listCalls)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := topic.Subscribe(c, "sqs", fmt.Sprintf("arn:aws:sqs:ap-northeast-2:000000000000:queue-%d", i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subscriptions, err := topic.Subscriptions(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subscriptions) != 3 {
+		t.Fatalf("all pages of subscriptions must be listed, %d", len(subscriptions))
+	}
+
+	// content based deduplication 은 fifo topic 에만 줄 수 있음
+	if _, err := NewWithClient(client, "portfolio-lifecycle").Create(c, WithContentBasedDeduplication()); err == nil {
+		t.Fatal("content based deduplication on standard topic must be rejected")
+	}
+
+	if err := topic.Delete(c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.Arn(c); !errors.Is(err, common.ErrorNotFoundTopic) || arn == "" {
+		t.Fatalf("deleted topic must be not found, %v", err)
+	}
+
+	log.Debug().Msgf("[%s] success", common.FunctionName())
+}
@@ -0,0 +1,256 @@
+package sns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// topic attribute 이름
+	// https://docs.aws.amazon.com/sns/latest/api/API_SetTopicAttributes.html
+	TOPIC_ATTRIBUTE_DISPLAY_NAME                = "DisplayName"
+	TOPIC_ATTRIBUTE_DELIVERY_POLICY             = "DeliveryPolicy"
+	TOPIC_ATTRIBUTE_KMS_MASTER_KEY_ID           = "KmsMasterKeyId"
+	TOPIC_ATTRIBUTE_POLICY                      = "Policy"
+	TOPIC_ATTRIBUTE_FIFO_TOPIC                  = "FifoTopic"
+	TOPIC_ATTRIBUTE_CONTENT_BASED_DEDUPLICATION = "ContentBasedDeduplication"
+
+	// 구독 확인을 기다리는 중인 구독은 arn 대신 이 값이 옴
+	pending_subscription_arn = "PendingConfirmation"
+)
+
+// TopicOption 는 topic 을 만들 때 attribute 와 tag 를 지정
+//
+//	arn, err := sns.New("topic-dev-account.fifo").Create(c, sns.WithDisplayName("account"), sns.WithContentBasedDeduplication())
+type TopicOption func(*sns.CreateTopicInput)
+
+// WithTopicAttribute 는 topic attribute 를 하나 지정
+func WithTopicAttribute(name, value string) TopicOption {
+	return func(input *sns.CreateTopicInput) {
+		if input.Attributes == nil {
+			input.Attributes = make(map[string]string)
+		}
+		input.Attributes[name] = value
+	}
+}
+
+// WithDisplayName 는 sms, email 구독자에게 보일 이름을 지정
+func WithDisplayName(name string) TopicOption {
+	return WithTopicAttribute(TOPIC_ATTRIBUTE_DISPLAY_NAME, name)
+}
+
+// WithDeliveryPolicy 는 http/s 구독에 재시도 정책을 지정, json 문자열
+func WithDeliveryPolicy(policy string) TopicOption {
+	return WithTopicAttribute(TOPIC_ATTRIBUTE_DELIVERY_POLICY, policy)
+}
+
+// WithKmsKey 는 메시지를 암호화 할 kms key 를 지정, alias/aws/sns 처럼 alias 도 가능
+// sqs 가 구독하면 queue 쪽에서 key 를 쓸 수 있도록 key policy 를 열어 줘야 함
+func WithKmsKey(keyId string) TopicOption {
+	return WithTopicAttribute(TOPIC_ATTRIBUTE_KMS_MASTER_KEY_ID, keyId)
+}
+
+// WithContentBasedDeduplication 는 fifo topic 에서 deduplication id 가 없으면 메시지의 sha-256 으로 중복을 거르게 함
+func WithContentBasedDeduplication() TopicOption {
+	return WithTopicAttribute(TOPIC_ATTRIBUTE_CONTENT_BASED_DEDUPLICATION, "true")
+}
+
+// WithTag 는 topic 에 tag 를 하나 붙임
+func WithTag(key, value string) TopicOption {
+	return func(input *sns.CreateTopicInput) {
+		input.Tags = append(input.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+}
+
+// Create 는 topic 을 만들고 arn 을 전달, 이름이 .fifo 로 끝나면 fifo topic 으로 만듦
+// 만든 topic 은 바로 cache 에 넣어서 ListTopics 없이 Publish 할 수 있음
+// 같은 이름, 같은 attribute 로 이미 있으면 있는 topic 의 arn 을 전달
+// https://docs.aws.amazon.com/sns/latest/dg/sns-create-topic.html
+func (n Notification) Create(c context.Context, opts ...TopicOption) (string, error) {
+	input := &sns.CreateTopicInput{
+		Name: aws.String(n.topic),
+	}
+	for _, opt := range opts {
+		opt(input)
+	}
+	if n.isFifo() {
+		WithTopicAttribute(TOPIC_ATTRIBUTE_FIFO_TOPIC, "true")(input)
+	} else if _, ok := input.Attributes[TOPIC_ATTRIBUTE_CONTENT_BASED_DEDUPLICATION]; ok {
+		return "", fmt.Errorf("invalid topic option, content based deduplication is only for fifo topic, topic : %s", n.topic)
+	}
+
+	r, err := n.api().CreateTopic(c, input)
+	if err != nil {
+		return "", fmt.Errorf("create sns topic failed, topic : %s, %w", n.topic, err)
+	}
+
+	arn := aws.ToString(r.TopicArn)
+	cacheFor(n.api()).register(arn)
+
+	log.Debug().Interface("response", r).Msg("sns topic create success")
+
+	return arn, nil
+}
+
+// Delete 는 topic 을 지우고 cache 에서도 뺌, 구독도 같이 지워짐
+func (n Notification) Delete(c context.Context) error {
+	arn, err := n.Arn(c)
+	if err != nil {
+		return err
+	}
+
+	r, err := n.api().DeleteTopic(c, &sns.DeleteTopicInput{
+		TopicArn: aws.String(arn),
+	})
+	if err != nil {
+		return fmt.Errorf("delete sns topic failed, topic : %s, %w", n.topic, err)
+	}
+	cacheFor(n.api()).unregister(n.topic)
+
+	log.Debug().Interface("response", r).Msg("sns topic delete success")
+
+	return nil
+}
+
+// Attributes 는 topic 의 attribute 들을 전달
+func (n Notification) Attributes(c context.Context) (map[string]string, error) {
+	arn, err := n.Arn(c)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := n.api().GetTopicAttributes(c, &sns.GetTopicAttributesInput{
+		TopicArn: aws.String(arn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get topic attributes failed, topic : %s, %w", n.topic, err)
+	}
+
+	return r.Attributes, nil
+}
+
+// SetAttribute 는 topic attribute 를 하나 변경, FifoTopic 은 만든 뒤에 바꿀 수 없음
+func (n Notification) SetAttribute(c context.Context, name, value string) error {
+	arn, err := n.Arn(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.api().SetTopicAttributes(c, &sns.SetTopicAttributesInput{
+		TopicArn:       aws.String(arn),
+		AttributeName:  aws.String(name),
+		AttributeValue: aws.String(value),
+	})
+	if err != nil {
+		return fmt.Errorf("set topic attribute failed, topic : %s, attribute : %s, %w", n.topic, name, err)
+	}
+
+	log.Debug().Interface("topic", n.topic).Interface("attribute", name).Msg("set topic attribute success")
+
+	return nil
+}
+
+// Tag 는 topic 에 tag 를 붙임, 같은 key 면 덮어 씀
+func (n Notification) Tag(c context.Context, tags map[string]string) error {
+	arn, err := n.Arn(c)
+	if err != nil {
+		return err
+	}
+
+	input := &sns.TagResourceInput{ResourceArn: aws.String(arn)}
+	for k, v := range tags {
+		input.Tags = append(input.Tags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+
+	_, err = n.api().TagResource(c, input)
+	if err != nil {
+		return fmt.Errorf("tag topic failed, topic : %s, %w", n.topic, err)
+	}
+
+	return nil
+}
+
+// Untag 는 topic 에서 key 에 해당하는 tag 를 뗌
+func (n Notification) Untag(c context.Context, keys ...string) error {
+	arn, err := n.Arn(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = n.api().UntagResource(c, &sns.UntagResourceInput{
+		ResourceArn: aws.String(arn),
+		TagKeys:     keys,
+	})
+	if err != nil {
+		return fmt.Errorf("untag topic failed, topic : %s, %w", n.topic, err)
+	}
+
+	return nil
+}
+
+// Subscriptions 는 topic 의 구독들을 전달, 100개씩 오기 때문에 NextToken 을 따라서 끝까지 받음
+func (n Notification) Subscriptions(c context.Context) ([]types.Subscription, error) {
+	arn, err := n.Arn(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriptions []types.Subscription
+	paginator := sns.NewListSubscriptionsByTopicPaginator(n.api(), &sns.ListSubscriptionsByTopicInput{
+		TopicArn: aws.String(arn),
+	})
+	for paginator.HasMorePages() {
+		r, err := paginator.NextPage(c)
+		if err != nil {
+			return nil, fmt.Errorf("list subscriptions failed, topic : %s, %w", n.topic, err)
+		}
+		subscriptions = append(subscriptions, r.Subscriptions...)
+	}
+
+	return subscriptions, nil
+}
+
+// PendingSubscriptions 는 아직 구독 확인이 안 된 구독들을 전달
+func (n Notification) PendingSubscriptions(c context.Context) ([]types.Subscription, error) {
+	subscriptions, err := n.Subscriptions(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []types.Subscription
+	for _, s := range subscriptions {
+		if aws.ToString(s.SubscriptionArn) == pending_subscription_arn {
+			pending = append(pending, s)
+		}
+	}
+
+	return pending, nil
+}
+
+// ConfirmSubscription 는 구독 확인 메시지로 받은 token 으로 구독을 확인하고 구독 arn 을 전달
+// http/s 구독은 endpoint 로 SubscriptionConfirmation 메시지가 가고, 그 안의 Token 을 넘기면 됨
+// 확인한 구독은 topic 주인만 해제 할 수 있도록 AuthenticateOnUnsubscribe 를 켬
+func (n Notification) ConfirmSubscription(c context.Context, token string) (string, error) {
+	arn, err := n.Arn(c)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := n.api().ConfirmSubscription(c, &sns.ConfirmSubscriptionInput{
+		TopicArn:                  aws.String(arn),
+		Token:                     aws.String(token),
+		AuthenticateOnUnsubscribe: aws.String("true"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("confirm subscription failed, topic : %s, %w", n.topic, err)
+	}
+
+	log.Debug().Interface("response", r).Msg("confirm subscription success")
+
+	return aws.ToString(r.SubscriptionArn), nil
+}
@@ -0,0 +1,173 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// SendMessageBatch 는 entry 10개 이하, 전체 payload 256KB 이하 까지만 가능
+	max_batch_payload_size = 256 * 1024
+
+	batch_failure_code_too_large = "BatchEntryTooLarge"
+)
+
+var (
+	// 다시 보내면 성공할 수도 있는 sqs 오류 code
+	retryableErrorCodes = []string{"ThrottlingException", "RequestThrottled", "ServiceUnavailable", "InternalError", "KmsThrottled"}
+)
+
+// BatchFailure 는 batch 요청에서 실패한 entry 하나
+type BatchFailure = common.BatchFailure
+
+// BatchError 는 batch 요청에서 실패한 entry 들을 모아서 전달하기 위한 오류
+type BatchError = common.BatchError
+
+// BulkSend 한번에 여러 메시지를 전송을 요청을 하는 기능
+// 최대 10개, 256KB 까지만 한번에 보낼 수 있어서 두 제한에 맞게 나눠서 보냄
+// 실패한 entry 만 backoff 를 주면서 재시도 하고, 끝내 실패한 entry 는 Id 와 사유를 *BatchError 로 전달
+// Id 가 비어 있는 entry 는 entries 의 index 를 Id 로 사용, Id 는 요청 안에서 겹치면 안됨
+// fifo queue 에서 일부만 재시도 되면 같은 group 안에서 순서가 바뀔 수 있으니 순서가 중요하면 Send 를 사용
+func (q *Queue) BulkSend(c context.Context, entries []types.SendMessageBatchRequestEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := q.ensureUrl(c); err != nil {
+		return err
+	}
+
+	batchErr := &BatchError{Op: "send message batch"}
+	pending := make([]types.SendMessageBatchRequestEntry, 0, len(entries))
+	ids := make(map[string]struct{}, len(entries))
+	for i, entry := range entries {
+		if aws.ToString(entry.Id) == "" {
+			entry.Id = aws.String(strconv.Itoa(i))
+		}
+		if _, ok := ids[*entry.Id]; ok {
+			return fmt.Errorf("invalid entries, duplicated id %s", *entry.Id)
+		}
+		ids[*entry.Id] = struct{}{}
+
+		// blob store 가 있으면 큰 body 는 store 에 저장하고 pointer 를 보냄
+		if entrySize(entry) > max_batch_payload_size && q.blobs != nil {
+			offloaded, err := q.offloadEntry(c, entry)
+			if err != nil {
+				batchErr.Failed = append(batchErr.Failed, BatchFailure{Id: *entry.Id, Code: common.BATCH_FAILURE_CODE_REQUEST, Message: err.Error(), Err: err})
+				continue
+			}
+			entry = offloaded
+		}
+
+		// 하나만 보내도 제한을 넘는 건 보내 봐야 실패
+		if size := entrySize(entry); size > max_batch_payload_size {
+			batchErr.Failed = append(batchErr.Failed, BatchFailure{
+				Id:          *entry.Id,
+				Code:        batch_failure_code_too_large,
+				Message:     fmt.Sprintf("entry size %d exceeds %d bytes", size, max_batch_payload_size),
+				SenderFault: true,
+			})
+			continue
+		}
+		pending = append(pending, entry)
+	}
+
+	for _, chunk := range chunkEntries(pending) {
+		batchErr.Failed = append(batchErr.Failed, q.sendChunk(c, chunk)...)
+	}
+
+	if len(batchErr.Failed) > 0 {
+		log.Error().Interface("failed", batchErr.Failed).Interface("count", len(entries)).Msg("send message batch failed")
+		return batchErr
+	}
+
+	log.Debug().Interface("count", len(entries)).Msg("send message batch success")
+
+	return nil
+}
+
+// offloadEntry 는 entry 의 body 를 blob store 에 저장하고 pointer 로 바꾼 entry 를 전달
+func (q *Queue) offloadEntry(c context.Context, entry types.SendMessageBatchRequestEntry) (types.SendMessageBatchRequestEntry, error) {
+	o := sendOptions{
+		attributes: make(map[string]types.MessageAttributeValue, len(entry.MessageAttributes)+1),
+		dedupId:    aws.ToString(entry.MessageDeduplicationId),
+	}
+	for k, v := range entry.MessageAttributes {
+		o.attributes[k] = v
+	}
+
+	body, err := q.offload(c, aws.ToString(entry.MessageBody), &o)
+	if err != nil {
+		return entry, err
+	}
+	entry.MessageBody = aws.String(body)
+	entry.MessageAttributes = o.attributes
+	if o.dedupId != "" {
+		entry.MessageDeduplicationId = aws.String(o.dedupId)
+	}
+
+	return entry, nil
+}
+
+// sendChunk 는 제한 안에 들어오는 entry 들을 보내고, 실패한 entry 만 다시 보냄
+func (q *Queue) sendChunk(c context.Context, entries []types.SendMessageBatchRequestEntry) []BatchFailure {
+	return common.SendBatch(c, entries, entryId, func(c context.Context, entries []types.SendMessageBatchRequestEntry) ([]BatchFailure, error) {
+		r, err := q.api().SendMessageBatch(c, &sqs.SendMessageBatchInput{
+			QueueUrl: q.queueUrl,
+			Entries:  entries,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return batchFailures(r.Failed), nil
+	}, isRetryable)
+}
+
+// batchFailures 는 sdk 의 실패 entry 를 BatchFailure 로 바꿈
+func batchFailures(results []types.BatchResultErrorEntry) []BatchFailure {
+	failed := make([]BatchFailure, 0, len(results))
+	for _, f := range results {
+		failed = append(failed, BatchFailure{
+			Id:          aws.ToString(f.Id),
+			Code:        aws.ToString(f.Code),
+			Message:     aws.ToString(f.Message),
+			SenderFault: f.SenderFault,
+		})
+	}
+	return failed
+}
+
+// isRetryable 는 다시 보내면 성공할 수도 있는 오류인지 확인
+func isRetryable(err error) bool {
+	return common.IsRetryable(err, retryableErrorCodes...)
+}
+
+// chunkEntries 는 10개, 256KB 제한에 맞게 entry 들을 순서대로 나눔
+func chunkEntries(entries []types.SendMessageBatchRequestEntry) [][]types.SendMessageBatchRequestEntry {
+	return common.ChunkBatch(entries, max_count_batch_entry, max_batch_payload_size, entrySize)
+}
+
+// entryId 는 batch 안에서 entry 를 구분하는 Id
+func entryId(entry types.SendMessageBatchRequestEntry) string {
+	return aws.ToString(entry.Id)
+}
+
+// entrySize 는 sqs 가 계산하는 메시지 크기
+func entrySize(entry types.SendMessageBatchRequestEntry) int {
+	return messageSize(aws.ToString(entry.MessageBody), entry.MessageAttributes)
+}
+
+// messageSize 는 body 와 message attribute 의 이름, 타입, 값을 더한 값
+func messageSize(body string, attributes map[string]types.MessageAttributeValue) int {
+	size := len(body)
+	for name, attr := range attributes {
+		size += len(name) + len(aws.ToString(attr.DataType)) + len(aws.ToString(attr.StringValue)) + len(attr.BinaryValue)
+	}
+	return size
+}
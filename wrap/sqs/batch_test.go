@@ -0,0 +1,67 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_BulkSend 는 10개, 256KB 제한으로 나눠서 보내고, 실패한 entry 만 재시도 하고, 끝내 실패한 entry 를 알려 주는지 검사
+func Test_BulkSend(t *testing.T) {
+	client := newMemoryQueue()
+	client.sendFaults["3"] = 2  // 두번 실패 후 성공
+	client.sendFaults["5"] = -1 // 계속 실패
+	client.sendRejects["7"] = true
+
+	var entries []types.SendMessageBatchRequestEntry
+	for i := 0; i < 25; i++ {
+		entries = append(entries, types.SendMessageBatchRequestEntry{MessageBody: aws.String(fmt.Sprintf("message-%d", i))})
+	}
+	// 100KB 짜리 두개는 같은 batch 에 들어가면 안됨
+	big := strings.Repeat("a", 100*1024)
+	entries = append(entries,
+		types.SendMessageBatchRequestEntry{MessageBody: aws.String(big)},
+		types.SendMessageBatchRequestEntry{MessageBody: aws.String(big)},
+		types.SendMessageBatchRequestEntry{MessageBody: aws.String(big)},
+		types.SendMessageBatchRequestEntry{MessageBody: aws.String(strings.Repeat("a", max_batch_payload_size+1))},
+	)
+
+	queue := NewWithClient(client, test_queue_name)
+	err := queue.BulkSend(context.TODO(), entries)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("bulk send must return batch error, %v", err)
+	}
+	failed := map[string]BatchFailure{}
+	for _, f := range batchErr.Failed {
+		failed[f.Id] = f
+	}
+	if len(failed) != 3 {
+		t.Fatalf("3 entries must fail, failed : %v", batchErr.Failed)
+	}
+	if f := failed["5"]; !errors.Is(f.Err, common.ErrorRetryExhausted) {
+		t.Fatalf("entry 5 must exhaust retries, %+v", f)
+	}
+	if f := failed["7"]; !f.SenderFault {
+		t.Fatalf("entry 7 must fail by sender fault, %+v", f)
+	}
+	if f := failed["28"]; f.Code != batch_failure_code_too_large {
+		t.Fatalf("entry 28 must be too large, %+v", f)
+	}
+	if !errors.Is(err, common.ErrorRetryExhausted) {
+		t.Fatal("batch error must unwrap entry errors")
+	}
+	if client.size() != 26 {
+		t.Fatalf("26 messages must be sent, size : %d", client.size())
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
@@ -0,0 +1,128 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/wrap/blob"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_SendLargeMessage 는 256KB 가 넘는 메시지를 blob store 에 저장해서 보내고, consumer 가 원래 body 로 처리하는지 검사
+// payload 는 DeleteBlobs 를 켠 경우에만 지워야 함
+func Test_SendLargeMessage(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blob.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newMemoryQueue()
+	large := testItem{PK: "pk", Val: strings.Repeat("a", 300*1024)}
+
+	queue := NewWithClient(client, test_queue_name)
+	if err := queue.Send(context.TODO(), large); !errors.Is(err, common.ErrorRequestParameterExceed) {
+		t.Fatalf("large message without blob store must fail, %v", err)
+	}
+
+	queue = queue.WithBlobStore(store)
+	if err := queue.Send(context.TODO(), large); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("payload must be stored, files : %d", len(files))
+	}
+
+	consume := func(deleteBlobs bool) {
+		received := make(chan int, 1)
+		c, cancel := context.WithCancel(context.TODO())
+		consumer := NewConsumer(queue, func(c context.Context, m types.Message) error {
+			received <- len(aws.ToString(m.Body))
+			return nil
+		}).WaitTime(time.Second).DeleteInterval(10 * time.Millisecond).DeleteBlobs(deleteBlobs)
+
+		done := make(chan error)
+		go func() {
+			done <- consumer.Run(c)
+		}()
+
+		select {
+		case size := <-received:
+			if size < 300*1024 {
+				t.Fatalf("handler must receive original body, size : %d", size)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message must be handled")
+		}
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// sns 로 여러 곳에 갈 수 있는 payload 는 기본으로 남겨 둠
+	consume(false)
+	if files, _ := os.ReadDir(dir); len(files) != 1 || client.size() != 0 {
+		t.Fatalf("payload must remain without delete blobs, files : %d, messages : %d", len(files), client.size())
+	}
+
+	if err := queue.Send(context.TODO(), large); err != nil {
+		t.Fatal(err)
+	}
+	consume(true)
+	if files, _ := os.ReadDir(dir); len(files) != 1 || client.size() != 0 {
+		t.Fatalf("payload must be deleted with message, files : %d, messages : %d", len(files), client.size())
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_SendLargeMessageDeduplication 는 fifo queue 에서 offload 한 메시지도 원래 body 로 중복을 거를 수 있게 deduplication id 를 붙이는지 검사
+func Test_SendLargeMessageDeduplication(t *testing.T) {
+	c := context.TODO()
+	store, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newMemoryQueue()
+	queue := NewWithClient(client, test_queue_fifo_name).WithBlobStore(store)
+	large := testItem{PK: "pk", Val: strings.Repeat("a", 300*1024)}
+
+	for i := 0; i < 2; i++ {
+		if err := queue.Send(c, large, WithGroupId("pk"), WithContentDeduplication()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(client.sent) != 2 || aws.ToString(client.sent[0].MessageBody) == aws.ToString(client.sent[1].MessageBody) {
+		t.Fatalf("offloaded bodies must be different pointers, %d", len(client.sent))
+	}
+	first, second := aws.ToString(client.sent[0].MessageDeduplicationId), aws.ToString(client.sent[1].MessageDeduplicationId)
+	if first == "" || first != second {
+		t.Fatalf("same body must have same deduplication id, %s, %s", first, second)
+	}
+
+	// batch 도 같은 body 면 같은 deduplication id
+	entries := []types.SendMessageBatchRequestEntry{
+		{MessageBody: aws.String(strings.Repeat("b", 300*1024)), MessageGroupId: aws.String("pk")},
+	}
+	for i := 0; i < 2; i++ {
+		if err := queue.BulkSend(c, entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(client.sentEntries) != 2 {
+		t.Fatalf("batch entries must be sent, %d", len(client.sentEntries))
+	}
+	first, second = aws.ToString(client.sentEntries[0].MessageDeduplicationId), aws.ToString(client.sentEntries[1].MessageDeduplicationId)
+	if first == "" || first != second {
+		t.Fatalf("same batch body must have same deduplication id, %s, %s", first, second)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
@@ -0,0 +1,59 @@
+package sqs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/dalpengida/portfolio-go-aws/config"
+)
+
+// Client 는 Queue 에서 사용하는 sqs 기능들
+// *sqs.Client 가 그대로 구현하고 있고, 테스트에서는 fake 를 넣어서 사용할 수 있음
+type Client interface {
+	CreateQueue(c context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error)
+	GetQueueUrl(c context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
+	GetQueueAttributes(c context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+	SendMessage(c context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	SendMessageBatch(c context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+	ReceiveMessage(c context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(c context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	DeleteMessageBatch(c context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	ChangeMessageVisibility(c context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	ChangeMessageVisibilityBatch(c context.Context, params *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+}
+
+var (
+	defaultClient   Client
+	defaultClientMu sync.Mutex
+)
+
+// SetDefaultClient 는 client 를 따로 지정하지 않은 Queue 들이 사용할 client 를 변경
+func SetDefaultClient(client Client) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	defaultClient = client
+}
+
+// getDefaultClient 는 default client 를 전달, 처음 호출 될 때 config.GetAws() 로 생성
+// import 할 때 init 에서 만들지 않기 위함
+func getDefaultClient() Client {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+
+	if defaultClient == nil {
+		defaultClient = sqs.NewFromConfig(config.GetAws())
+	}
+
+	return defaultClient
+}
+
+// api 는 Queue 가 사용할 client 를 전달, 지정한 client 가 없으면 default client
+func (q Queue) api() Client {
+	if q.client != nil {
+		return q.client
+	}
+
+	return getDefaultClient()
+}
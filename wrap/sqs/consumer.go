@@ -0,0 +1,366 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/wrap/blob"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	default_consumer_workers      = 4
+	default_consumer_wait_time    = max_wait_time
+	default_visibility_timeout    = 30 * time.Second
+	default_delete_flush_interval = time.Second
+
+	// receive 실패 시 재시도 대기 시간
+	consumer_retry_base_delay = 100 * time.Millisecond
+	consumer_retry_max_delay  = 10 * time.Second
+)
+
+// Handler 는 consumer 가 받은 메시지를 처리하는 함수
+// nil 을 주면 메시지를 지우고, 오류를 주면 지우지 않아서 visibility timeout 이 지난 뒤에 다시 들어옴
+type Handler func(c context.Context, m types.Message) error
+
+// Consumer 는 lambda 밖에서 queue 를 long polling 으로 계속 읽으면서 handler 를 호출
+// handler 가 도는 동안은 visibility timeout 을 계속 늘려 주고, 성공한 메시지는 10개씩 모아서 지움
+// context 가 끝나면 더 이상 받지 않고, 처리 중인 메시지와 삭제 대기 중인 메시지를 마무리 한 뒤에 Run 이 끝남
+//
+//	consumer := sqs.NewConsumer(sqs.New("portfolio-dev-stats.fifo"), handler).Workers(8)
+//	err := consumer.Run(c)
+type Consumer struct {
+	queue   Queue
+	handler Handler
+
+	workers           int
+	waitTime          time.Duration
+	visibilityTimeout time.Duration
+	flushInterval     time.Duration
+	deleteBlobs       bool
+}
+
+func NewConsumer(queue Queue, handler Handler) *Consumer {
+	return &Consumer{
+		queue:             queue,
+		handler:           handler,
+		workers:           default_consumer_workers,
+		waitTime:          default_consumer_wait_time,
+		visibilityTimeout: default_visibility_timeout,
+		flushInterval:     default_delete_flush_interval,
+	}
+}
+
+// Workers 는 동시에 처리할 메시지 수
+// fifo queue 는 한번에 같은 group 의 메시지가 여러개 올 수 있어서 group 별로 묶어서 순서대로 하나씩 처리 함
+// 그래서 group 이 적으면 workers 만큼 동시에 돌지 않을 수 있음
+func (cs *Consumer) Workers(n int) *Consumer {
+	cs.workers = n
+	return cs
+}
+
+// WaitTime 는 long polling 대기 시간, 최대 20초
+func (cs *Consumer) WaitTime(d time.Duration) *Consumer {
+	cs.waitTime = d
+	return cs
+}
+
+// VisibilityTimeout 는 받은 메시지가 안 보이는 시간, handler 가 도는 동안은 절반이 지날 때마다 이 만큼 다시 늘려 줌
+func (cs *Consumer) VisibilityTimeout(d time.Duration) *Consumer {
+	cs.visibilityTimeout = d
+	return cs
+}
+
+// DeleteInterval 는 지울 메시지가 10개가 안 모였을 때 기다리는 최대 시간
+func (cs *Consumer) DeleteInterval(d time.Duration) *Consumer {
+	cs.flushInterval = d
+	return cs
+}
+
+// DeleteBlobs 는 메시지를 지울 때 blob store 에 있는 payload 도 같이 지울지, 기본은 지우지 않음
+// payload 를 이 queue 에서만 받는 경우에만 켜야 함, sns 를 구독하는 queue 는 다른 구독자도 같은 payload 를 읽기 때문에 bucket 의 lifecycle 로 만료 시킴
+func (cs *Consumer) DeleteBlobs(on bool) *Consumer {
+	cs.deleteBlobs = on
+	return cs
+}
+
+// Run 는 context 가 끝날 때까지 메시지를 받아서 처리, context 가 끝나서 멈춘 경우에는 nil 을 전달
+func (cs *Consumer) Run(c context.Context) error {
+	if cs.handler == nil {
+		return fmt.Errorf("invalid consumer, handler is nil")
+	}
+	if cs.workers < 1 {
+		return fmt.Errorf("invalid consumer workers, %d", cs.workers)
+	}
+	if cs.visibilityTimeout < time.Second || cs.visibilityTimeout > max_visibility_timeout {
+		return fmt.Errorf("invalid consumer visibility timeout, %v", cs.visibilityTimeout)
+	}
+	if cs.flushInterval <= 0 {
+		return fmt.Errorf("invalid consumer delete interval, %v", cs.flushInterval)
+	}
+
+	q := cs.queue
+	if err := q.ensureUrl(c); err != nil {
+		return err
+	}
+
+	// 처리 중인 handler 와 삭제는 종료 중에도 끝까지 해야 해서 cancel 이 전달 되지 않는 context 를 사용
+	work := context.WithoutCancel(c)
+
+	deletes := make(chan processed, max_count_batch_entry)
+	deleted := make(chan struct{})
+	go func() {
+		defer close(deleted)
+		cs.deleteLoop(work, &q, deletes)
+	}()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cs.workers)
+	for attempt := 0; ; {
+		n, ok := cs.acquire(c, sem)
+		if !ok {
+			break
+		}
+
+		messages, err := q.receive(c, n, cs.waitTime, cs.visibilityTimeout)
+		// 놀고 있는 worker 수 만큼만 받기 때문에 덜 받은 만큼 돌려 놓음
+		for i := len(messages); i < n; i++ {
+			<-sem
+		}
+		if err != nil {
+			if c.Err() != nil {
+				break
+			}
+			log.Error().Err(err).Interface("queue", q.queueName).Msg("consumer receive failed")
+			if common.Sleep(c, common.Backoff(attempt, consumer_retry_base_delay, consumer_retry_max_delay)) != nil {
+				break
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		for _, group := range groupMessages(q.isFifo(), messages) {
+			wg.Add(1)
+			go func(group []types.Message) {
+				defer wg.Done()
+				cs.processGroup(work, &q, group, sem, deletes)
+			}(group)
+		}
+	}
+
+	wg.Wait()
+	close(deletes)
+	<-deleted
+
+	log.Debug().Interface("queue", q.queueName).Msg("consumer stopped")
+
+	return nil
+}
+
+// acquire 는 worker 가 하나라도 놀 때까지 기다렸다가, 놀고 있는 worker 수(최대 10개)를 전달
+// context 가 끝나면 false
+func (cs *Consumer) acquire(c context.Context, sem chan struct{}) (int, bool) {
+	select {
+	case sem <- struct{}{}:
+	case <-c.Done():
+		return 0, false
+	}
+
+	n := 1
+	for n < max_count_batch_entry {
+		select {
+		case sem <- struct{}{}:
+			n++
+		default:
+			return n, true
+		}
+	}
+
+	return n, true
+}
+
+// groupMessages 는 fifo queue 면 받은 메시지를 MessageGroupId 별로 받은 순서대로 묶고, 일반 queue 면 메시지 하나씩 따로 묶음
+func groupMessages(fifo bool, messages []types.Message) [][]types.Message {
+	groups := make([][]types.Message, 0, len(messages))
+	if !fifo {
+		for _, m := range messages {
+			groups = append(groups, []types.Message{m})
+		}
+		return groups
+	}
+
+	index := make(map[string]int)
+	for _, m := range messages {
+		id := m.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+		i, ok := index[id]
+		if !ok {
+			i = len(groups)
+			index[id] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], m)
+	}
+
+	return groups
+}
+
+// processGroup 는 같은 group 의 메시지를 순서대로 하나씩 처리, 메시지 하나가 끝날 때마다 worker 를 하나 돌려 놓음
+// 중간에 실패하면 뒤의 메시지는 처리도 삭제도 안하고 남겨서, 실패한 메시지가 다시 들어올 때 같이 순서대로 다시 받도록 함
+func (cs *Consumer) processGroup(c context.Context, q *Queue, group []types.Message, sem chan struct{}, deletes chan<- processed) {
+	for i, m := range group {
+		p, ok := cs.process(c, q, m)
+		if ok {
+			deletes <- p
+			<-sem
+			continue
+		}
+
+		for range group[i:] {
+			<-sem
+		}
+		if skipped := len(group) - i - 1; skipped > 0 {
+			log.Debug().Interface("message_id", aws.ToString(m.MessageId)).Interface("skipped", skipped).Msg("consumer skip messages of failed group")
+		}
+		return
+	}
+}
+
+// processed 는 처리가 끝나서 지워야 할 메시지, blobKey 가 있으면 메시지를 지운 뒤에 payload 도 같이 지움
+type processed struct {
+	receiptHandle string
+	blobKey       string
+}
+
+// process 는 visibility timeout 을 늘려 주면서 handler 를 호출, 성공하면 true
+// body 가 blob pointer 면 handler 에는 store 에서 가져온 원래 body 를 넘겨 줌
+// sns 메시지 json 안에 들어 있는 pointer 는 여기서 풀 수 없어서 sns.Decoder 의 Blobs 로 resolve 해야 함
+func (cs *Consumer) process(c context.Context, q *Queue, m types.Message) (processed, bool) {
+	hc, stop := context.WithCancel(c)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		cs.heartbeat(hc, q, aws.ToString(m.ReceiptHandle))
+	}()
+
+	p := processed{receiptHandle: aws.ToString(m.ReceiptHandle)}
+	body, pointer, err := blob.Resolve(c, q.blobs, aws.ToString(m.Body))
+	if err == nil {
+		if pointer != nil {
+			m.Body = aws.String(body)
+			if cs.deleteBlobs {
+				p.blobKey = pointer.Key
+			}
+		}
+		err = cs.handle(c, m)
+	}
+	stop()
+	<-stopped
+
+	if err != nil {
+		log.Error().Err(err).Interface("message_id", aws.ToString(m.MessageId)).Msg("consumer handle message failed")
+		return p, false
+	}
+
+	return p, true
+}
+
+// handle 는 handler 에서 panic 이 나도 consumer 가 죽지 않도록 오류로 바꿔 줌
+func (cs *Consumer) handle(c context.Context, m types.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("consumer handler panic, %v", r)
+		}
+	}()
+
+	return cs.handler(c, m)
+}
+
+// heartbeat 는 handler 가 끝날 때까지 visibility timeout 의 절반마다 visibility timeout 을 다시 늘려 줌
+func (cs *Consumer) heartbeat(c context.Context, q *Queue, receiptHandle string) {
+	ticker := time.NewTicker(cs.visibilityTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+			err := q.ChangeVisibility(c, receiptHandle, cs.visibilityTimeout)
+			if err != nil && c.Err() == nil {
+				log.Error().Err(err).Msg("consumer extend visibility failed")
+			}
+		}
+	}
+}
+
+// deleteLoop 는 성공한 메시지를 10개씩 모아서 지움, 10개가 안 모여도 flushInterval 마다 지움
+// deletes 가 닫히면 남은 것 까지 지우고 끝남
+func (cs *Consumer) deleteLoop(c context.Context, q *Queue, deletes <-chan processed) {
+	ticker := time.NewTicker(cs.flushInterval)
+	defer ticker.Stop()
+
+	pending := make([]processed, 0, max_count_batch_entry)
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		cs.delete(c, q, pending)
+		pending = pending[:0]
+	}
+
+	for {
+		select {
+		case p, ok := <-deletes:
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, p)
+			if len(pending) == max_count_batch_entry {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// delete 는 메시지들을 지우고, DeleteBlobs 가 켜져 있으면 지워진 메시지의 blob payload 도 지움
+// 메시지가 안 지워졌으면 다시 들어올 때 payload 가 필요해서 남겨 둠
+func (cs *Consumer) delete(c context.Context, q *Queue, pending []processed) {
+	handles := make([]string, 0, len(pending))
+	for _, p := range pending {
+		handles = append(handles, p.receiptHandle)
+	}
+
+	failed := make(map[string]struct{})
+	err := q.DeleteBatch(c, handles)
+	if err != nil {
+		log.Error().Err(err).Interface("queue", q.queueName).Msg("consumer delete messages failed")
+
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) {
+			return
+		}
+		for _, f := range batchErr.Failed {
+			failed[f.Id] = struct{}{}
+		}
+	}
+
+	for i, p := range pending {
+		if _, ok := failed[strconv.Itoa(i)]; ok || p.blobKey == "" {
+			continue
+		}
+		if err := q.blobs.Delete(c, p.blobKey); err != nil {
+			log.Error().Err(err).Interface("key", p.blobKey).Msg("consumer delete blob failed")
+		}
+	}
+}
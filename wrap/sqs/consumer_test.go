@@ -0,0 +1,172 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_Consumer 는 consumer 가 메시지를 처리하고, 오래 걸리는 메시지는 visibility 를 늘려 주고, 성공한 것만 지우는지 검사
+func Test_Consumer(t *testing.T) {
+	client := newMemoryQueue()
+	for i := 0; i < 25; i++ {
+		client.push(fmt.Sprintf("message-%d", i), nil)
+	}
+
+	var handled, running, maxRunning atomic.Int32
+	handler := func(c context.Context, m types.Message) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRunning.Load()
+			if n <= max || maxRunning.CompareAndSwap(max, n) {
+				break
+			}
+		}
+
+		switch aws.ToString(m.Body) {
+		case "message-0":
+			// visibility timeout 보다 오래 걸려도 heartbeat 로 늘려 줘서 다른 worker 가 받지 않아야 함
+			time.Sleep(1500 * time.Millisecond)
+		case "message-1":
+			return fmt.Errorf("handle failed")
+		case "message-2":
+			panic("handler panic")
+		}
+
+		handled.Add(1)
+		return nil
+	}
+
+	c, cancel := context.WithCancel(context.TODO())
+	consumer := NewConsumer(NewWithClient(client, test_queue_name), handler).
+		Workers(3).
+		WaitTime(time.Second).
+		VisibilityTimeout(time.Second).
+		DeleteInterval(100 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		done <- consumer.Run(c)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.size() > 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer must stop after cancel")
+	}
+
+	if handled.Load() != 23 {
+		t.Fatalf("23 messages must be handled once, handled : %d", handled.Load())
+	}
+	if client.size() != 2 {
+		t.Fatalf("failed messages must remain, size : %d", client.size())
+	}
+	if maxRunning.Load() > 3 {
+		t.Fatalf("concurrency must be limited by workers, max running : %d", maxRunning.Load())
+	}
+	if client.count("ChangeMessageVisibility") == 0 {
+		t.Fatal("long running message must extend visibility")
+	}
+	if client.count("DeleteMessageBatch") >= 23 {
+		t.Fatalf("deletes must be batched, calls : %d", client.count("DeleteMessageBatch"))
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_ConsumerFifoGroup 는 fifo queue 에서 같은 group 의 메시지가 한번에 여러개 와도 순서대로 하나씩 처리하고,
+// 중간에 실패하면 같은 group 의 뒤 메시지는 처리도 삭제도 안하는지 검사
+func Test_ConsumerFifoGroup(t *testing.T) {
+	client := newMemoryQueue()
+	for i := 0; i < 5; i++ {
+		for _, group := range []string{"a", "b"} {
+			client.push(fmt.Sprintf("%s-%d", group, i), nil)
+			client.messages[len(client.messages)-1].message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)] = group
+		}
+	}
+
+	var mu sync.Mutex
+	handled := map[string][]string{}
+	running := map[string]int{}
+	overlapped := false
+	handler := func(c context.Context, m types.Message) error {
+		group := m.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+		mu.Lock()
+		running[group]++
+		overlapped = overlapped || running[group] > 1
+		handled[group] = append(handled[group], aws.ToString(m.Body))
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running[group]--
+		mu.Unlock()
+
+		if aws.ToString(m.Body) == "a-2" {
+			return fmt.Errorf("handle failed")
+		}
+		return nil
+	}
+
+	c, cancel := context.WithCancel(context.TODO())
+	consumer := NewConsumer(NewWithClient(client, test_queue_name+fifo_queue_suffix), handler).
+		Workers(10).
+		WaitTime(time.Second).
+		VisibilityTimeout(time.Second).
+		DeleteInterval(50 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		done <- consumer.Run(c)
+	}()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for client.size() > 3 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if overlapped {
+		t.Fatal("messages of same group must not be handled concurrently")
+	}
+	if fmt.Sprint(handled["b"]) != "[b-0 b-1 b-2 b-3 b-4]" {
+		t.Fatalf("group b must be handled in order, %v", handled["b"])
+	}
+	if fmt.Sprint(handled["a"][:3]) != "[a-0 a-1 a-2]" {
+		t.Fatalf("group a must be handled in order, %v", handled["a"])
+	}
+	for _, body := range handled["a"] {
+		if body == "a-3" || body == "a-4" {
+			t.Fatalf("messages after failed message must not be handled, %v", handled["a"])
+		}
+	}
+	if client.size() != 3 {
+		t.Fatalf("failed message and later messages of group must remain, size : %d", client.size())
+	}
+
+	log.Debug().Interface("handled", handled).Msgf(test_success_msg_format, common.FunctionName())
+}
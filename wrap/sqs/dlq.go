@@ -0,0 +1,362 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// dlq 이름은 원래 queue 이름 뒤에 붙임, fifo 면 .fifo 앞에 붙임
+	dlq_suffix = "-dlq"
+
+	// dlq 는 원래 queue 보다 오래 보관 해야 메시지가 사라지지 않음, 최대 14일
+	dlq_message_retention_period = "1209600"
+
+	// 조회, redrive 하는 동안 같은 메시지를 다시 받지 않도록 잠깐 안 보이게 하는 시간
+	browse_visibility_timeout = 5 * time.Minute
+
+	// 더 받을 메시지가 있는지 기다리는 시간
+	browse_wait_time = time.Second
+
+	default_redrive_rate = 10
+)
+
+var (
+	// errStopBrowse 는 browse 의 fn 이 더 이상 받지 않아도 될 때 전달, browse 는 오류 없이 끝남
+	errStopBrowse = errors.New("stop browse")
+)
+
+// DLQName 는 queue 이름에 맞는 dlq 이름을 전달
+// ex) portfolio-dev-stats.fifo -> portfolio-dev-stats-dlq.fifo
+func DLQName(queueName string) string {
+	if strings.HasSuffix(queueName, fifo_queue_suffix) {
+		return strings.TrimSuffix(queueName, fifo_queue_suffix) + dlq_suffix + fifo_queue_suffix
+	}
+	return queueName + dlq_suffix
+}
+
+// CreateWithDLQ 는 dlq 를 먼저 만들고, maxReceiveCount 번 받고도 안 지워진 메시지가 dlq 로 가도록 RedrivePolicy 를 걸어서 queue 를 생성
+// schema 가 nil 이면 CREATE_SQS_SCHEMA 를 사용, 만든 dlq 를 전달
+func (q Queue) CreateWithDLQ(c context.Context, schema *sqs.CreateQueueInput, maxReceiveCount int) (Queue, error) {
+	if maxReceiveCount < 1 || maxReceiveCount > 1000 {
+		return Queue{}, fmt.Errorf("invalid max receive count, %d", maxReceiveCount)
+	}
+	if schema == nil {
+		schema = CREATE_SQS_SCHEMA
+	}
+
+	dlq := Queue{queueName: DLQName(q.queueName), client: q.client}
+	err := dlq.Create(c, q.queueSchema(schema, dlq.queueName, map[string]string{
+		"MessageRetentionPeriod": dlq_message_retention_period,
+	}))
+	if err != nil {
+		return Queue{}, fmt.Errorf("create dlq failed, %w", err)
+	}
+
+	arn, err := dlq.GetArn(c)
+	if err != nil {
+		return Queue{}, err
+	}
+	policy, err := json.Marshal(map[string]string{
+		"deadLetterTargetArn": arn,
+		"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+	})
+	if err != nil {
+		return Queue{}, fmt.Errorf("redrive policy json marshaling failed, %w", err)
+	}
+
+	err = q.Create(c, q.queueSchema(schema, q.queueName, map[string]string{
+		"RedrivePolicy": string(policy),
+	}))
+	if err != nil {
+		return Queue{}, err
+	}
+
+	log.Debug().Interface("queue", q.queueName).Interface("dlq", dlq.queueName).Msg("create queue with dlq success")
+
+	return dlq, nil
+}
+
+// queueSchema 는 schema 를 복사해서 이름과 attribute 를 채워 줌, fifo 이름이면 FifoQueue 도 켜 줌
+func (q Queue) queueSchema(schema *sqs.CreateQueueInput, queueName string, attributes map[string]string) *sqs.CreateQueueInput {
+	input := *schema
+	input.QueueName = aws.String(queueName)
+	input.Attributes = make(map[string]string, len(schema.Attributes)+len(attributes)+1)
+	for k, v := range schema.Attributes {
+		input.Attributes[k] = v
+	}
+	for k, v := range attributes {
+		input.Attributes[k] = v
+	}
+	if strings.HasSuffix(queueName, fifo_queue_suffix) {
+		input.Attributes["FifoQueue"] = "true"
+	}
+
+	return &input
+}
+
+// ExportMessage 는 Export 로 내보내는 메시지 한 줄
+type ExportMessage struct {
+	MessageId         string                                 `json:"message_id"`
+	Body              string                                 `json:"body"`
+	Attributes        map[string]string                      `json:"attributes,omitempty"`
+	MessageAttributes map[string]types.MessageAttributeValue `json:"message_attributes,omitempty"`
+}
+
+// Peek 는 메시지를 지우지 않고 최대 max 개를 조회, 조회가 끝나면 다시 바로 보이게 돌려 놓음
+// 받을 때마다 ApproximateReceiveCount 는 올라가니 redrive policy 가 걸린 queue 에는 쓰지 않는 게 좋음
+func (q *Queue) Peek(c context.Context, max int) ([]types.Message, error) {
+	var messages []types.Message
+	err := q.browse(c, max, func(m types.Message) (bool, error) {
+		messages = append(messages, m)
+		return false, nil
+	})
+
+	return messages, err
+}
+
+// Export 는 queue 의 모든 메시지를 지우지 않고 w 에 json line 으로 내보내고 내보낸 수를 전달
+// dlq 에 쌓인 메시지를 확인 하거나 백업 할 때 사용
+func (q *Queue) Export(c context.Context, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	err := q.browse(c, 0, func(m types.Message) (bool, error) {
+		err := encoder.Encode(ExportMessage{
+			MessageId:         aws.ToString(m.MessageId),
+			Body:              aws.ToString(m.Body),
+			Attributes:        m.Attributes,
+			MessageAttributes: m.MessageAttributes,
+		})
+		if err != nil {
+			return false, fmt.Errorf("export message failed, %w", err)
+		}
+		count++
+		return false, nil
+	})
+
+	return count, err
+}
+
+// browse 는 더 받을 메시지가 없을 때까지(max 가 0 보다 크면 max 개 까지) 메시지를 받아서 fn 을 호출
+// 같은 메시지를 다시 받으면 MessageId 로 걸러서 fn 은 메시지마다 한번만 부름
+// fn 이 consumed 를 true 로 주면 지워진 메시지라서 돌려 놓지 않고, errStopBrowse 를 주면 더 받지 않고 끝냄
+//
+// standard queue 는 받은 메시지를 끝날 때까지 안 보이게 잡아 두고, 오래 걸리면 다시 보이기 전에 visibility 를 늘려 줌
+// fifo queue 는 잡아 둔 메시지가 있으면 같은 group 의 뒤 메시지를 받을 수 없어서 page 를 처리하고 바로 돌려 놓음
+// 돌려 놓은 메시지는 group 의 맨 앞이라 다시 받게 되는데, 이건 이미 본 메시지라 끝날 때까지 잡아 두고 다음 group 을 받음
+// 지우지 않고는 group 마다 한번에 받을 수 있는 메시지(최대 10개) 뒤로는 볼 수 없음
+func (q *Queue) browse(c context.Context, max int, fn func(m types.Message) (consumed bool, err error)) error {
+	if err := q.ensureUrl(c); err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{})
+	var held []string
+	defer func() {
+		q.release(context.WithoutCancel(c), held)
+	}()
+
+	extended := time.Now()
+	for count := 0; max <= 0 || count < max; {
+		// 잡아 둔 메시지가 browse_visibility_timeout 이 지나서 다시 보이지 않도록 절반쯤 지나면 늘려 줌
+		if len(held) > 0 && time.Since(extended) > browse_visibility_timeout/2 {
+			if err := q.ChangeVisibilityBatch(c, held, browse_visibility_timeout); err != nil {
+				log.Error().Err(err).Interface("queue", q.queueName).Msg("extend browse visibility failed")
+			}
+			extended = time.Now()
+		}
+
+		n := max_count_batch_entry
+		if max > 0 {
+			n = min(n, max-count)
+		}
+
+		messages, err := q.receive(c, n, browse_wait_time, browse_visibility_timeout)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		var page []string
+		fresh := 0
+		for i, m := range messages {
+			handle := aws.ToString(m.ReceiptHandle)
+			if _, ok := seen[aws.ToString(m.MessageId)]; ok {
+				held = append(held, handle)
+				continue
+			}
+			seen[aws.ToString(m.MessageId)] = struct{}{}
+			fresh++
+
+			var consumed bool
+			consumed, err = fn(m)
+			if !consumed {
+				page = append(page, handle)
+			}
+			if err != nil {
+				// 아직 fn 을 안 부른 메시지도 돌려 놔야 함
+				for _, rest := range messages[i+1:] {
+					page = append(page, aws.ToString(rest.ReceiptHandle))
+				}
+				break
+			}
+		}
+
+		if q.isFifo() {
+			q.release(context.WithoutCancel(c), page)
+		} else {
+			held = append(held, page...)
+		}
+		if err != nil {
+			if errors.Is(err, errStopBrowse) {
+				return nil
+			}
+			return err
+		}
+		// 이미 본 메시지만 다시 받았으면 더 받을 메시지가 없음
+		if fresh == 0 {
+			break
+		}
+		count += fresh
+	}
+
+	return nil
+}
+
+// release 는 받아 놓은 메시지들을 10개씩 묶어서 바로 다시 보이게 함
+func (q *Queue) release(c context.Context, receiptHandles []string) {
+	if err := q.ChangeVisibilityBatch(c, receiptHandles, 0); err != nil {
+		log.Error().Err(err).Interface("queue", q.queueName).Msg("release message failed")
+	}
+}
+
+// Redrive 는 dlq 에 있는 메시지를 원래 queue 로 다시 보내는 작업
+//
+//	moved, err := sqs.NewRedrive(dlq, queue).Select(messageIds...).Rate(5).Run(c)
+type Redrive struct {
+	from   Queue
+	to     Queue
+	filter func(m types.Message) bool
+	rate   float64
+	max    int
+}
+
+// NewRedrive 는 from(dlq) 의 메시지를 to 로 옮기는 Redrive 를 생성, 기본으로 모든 메시지를 초당 10개씩 옮김
+func NewRedrive(from, to Queue) *Redrive {
+	return &Redrive{from: from, to: to, rate: default_redrive_rate}
+}
+
+// Select 는 지정한 message id 의 메시지만 옮김
+func (r *Redrive) Select(messageIds ...string) *Redrive {
+	ids := make(map[string]struct{}, len(messageIds))
+	for _, id := range messageIds {
+		ids[id] = struct{}{}
+	}
+
+	return r.Filter(func(m types.Message) bool {
+		_, ok := ids[aws.ToString(m.MessageId)]
+		return ok
+	})
+}
+
+// Filter 는 fn 이 true 를 준 메시지만 옮김
+func (r *Redrive) Filter(fn func(m types.Message) bool) *Redrive {
+	r.filter = fn
+	return r
+}
+
+// Rate 는 초당 옮길 메시지 수, 원래 queue 의 consumer 가 한번에 몰리지 않도록 제한
+func (r *Redrive) Rate(perSecond float64) *Redrive {
+	r.rate = perSecond
+	return r
+}
+
+// Max 는 최대로 옮길 메시지 수, 0 이면 제한 없음
+// max 개를 옮기면 dlq 를 더 받지 않고 끝냄
+func (r *Redrive) Max(n int) *Redrive {
+	r.max = n
+	return r
+}
+
+// Run 는 메시지를 옮기고 옮긴 수를 전달
+// 원래 queue 로 보낸 다음에 dlq 에서 지우기 때문에, 중간에 실패하면 같은 메시지가 두번 들어갈 수는 있음
+// 옮기지 않은 메시지는 끝나고 바로 다시 보이게 돌려 놓음
+func (r *Redrive) Run(c context.Context) (int, error) {
+	if r.rate <= 0 {
+		return 0, fmt.Errorf("invalid redrive rate, %v", r.rate)
+	}
+
+	from, to := r.from, r.to
+	if err := to.ensureUrl(c); err != nil {
+		return 0, err
+	}
+
+	limiter := common.NewRateLimiter(r.rate)
+	moved := 0
+	err := from.browse(c, 0, func(m types.Message) (bool, error) {
+		if r.filter != nil && !r.filter(m) {
+			return false, nil
+		}
+
+		if err := limiter.Wait(c); err != nil {
+			return false, err
+		}
+		limiter.Consume(1)
+
+		if err := to.resend(c, m); err != nil {
+			return false, err
+		}
+		if err := from.Delete(c, aws.ToString(m.ReceiptHandle)); err != nil {
+			return false, err
+		}
+		moved++
+		if r.max > 0 && moved >= r.max {
+			return true, errStopBrowse
+		}
+		return true, nil
+	})
+
+	log.Debug().Interface("from", from.queueName).Interface("to", to.queueName).Interface("moved", moved).Msg("redrive success")
+
+	return moved, err
+}
+
+// resend 는 받은 메시지를 body, message attribute 그대로 다시 보냄
+// fifo 면 원래 group 을 유지하고, 다시 시도해도 중복으로 들어가지 않도록 원래 message id 를 deduplication id 로 사용
+func (q *Queue) resend(c context.Context, m types.Message) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:          q.queueUrl,
+		MessageBody:       m.Body,
+		MessageAttributes: m.MessageAttributes,
+	}
+	if q.isFifo() {
+		group := m.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+		if group == "" {
+			group = aws.ToString(m.MessageId)
+		}
+		input.MessageGroupId = aws.String(group)
+		input.MessageDeduplicationId = m.MessageId
+	}
+
+	_, err := q.api().SendMessage(c, input)
+	if err != nil {
+		return fmt.Errorf("resend message failed, queue : %s, message id : %s, %w", q.queueName, aws.ToString(m.MessageId), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package sqs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_CreateWithDLQ 는 dlq 를 먼저 만들고 원래 queue 에 redrive policy 를 거는지 검사
+func Test_CreateWithDLQ(t *testing.T) {
+	client := newMemoryQueue()
+	queue := NewWithClient(client, test_queue_fifo_name)
+
+	dlq, err := queue.CreateWithDLQ(context.TODO(), nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dlq.queueName != "portfolio-dlq.fifo" || len(client.created) != 2 {
+		t.Fatalf("dlq must be created first, dlq : %s, created : %d", dlq.queueName, len(client.created))
+	}
+
+	source := client.created[1]
+	if aws.ToString(source.QueueName) != test_queue_fifo_name || source.Attributes["FifoQueue"] != "true" {
+		t.Fatalf("source queue must be fifo, %v", source)
+	}
+	var policy map[string]string
+	if err := json.Unmarshal([]byte(source.Attributes["RedrivePolicy"]), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy["deadLetterTargetArn"] != "arn:aws:sqs:local:000000000000:portfolio-dlq.fifo" || policy["maxReceiveCount"] != "3" {
+		t.Fatalf("redrive policy must point dlq, %v", policy)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_Redrive 는 dlq 메시지를 지우지 않고 조회, 내보내기 하고, 선택한 메시지만 원래 queue 로 옮기는지 검사
+func Test_Redrive(t *testing.T) {
+	c := context.TODO()
+	dlqClient, sourceClient := newMemoryQueue(), newMemoryQueue()
+	var ids []string
+	for i := 0; i < 15; i++ {
+		ids = append(ids, dlqClient.push(fmt.Sprintf("message-%d", i), nil))
+	}
+	dlq := NewWithClient(dlqClient, DLQName(test_queue_name))
+	source := NewWithClient(sourceClient, test_queue_name)
+
+	messages, err := dlq.Peek(c, 12)
+	if err != nil || len(messages) != 12 {
+		t.Fatalf("peek must return 12 messages, %d, %v", len(messages), err)
+	}
+
+	var buf bytes.Buffer
+	count, err := dlq.Export(c, &buf)
+	if err != nil || count != 15 {
+		t.Fatalf("peeked messages must be visible again and exported, %d, %v", count, err)
+	}
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 15 {
+		t.Fatalf("export must write json lines, lines : %d", lines)
+	}
+
+	moved, err := NewRedrive(dlq, source).Select(ids[1], ids[3]).Rate(100).Run(c)
+	if err != nil || moved != 2 {
+		t.Fatalf("selected messages must be moved, %d, %v", moved, err)
+	}
+	if dlqClient.size() != 13 || sourceClient.size() != 2 {
+		t.Fatalf("moved messages must be deleted from dlq, dlq : %d, source : %d", dlqClient.size(), sourceClient.size())
+	}
+
+	// max 만큼 옮기면 dlq 를 더 받지 않아야 하고, 옮긴(지운) 메시지는 다시 보이게 돌려 놓지 않아야 함
+	receives, changes := dlqClient.count("ReceiveMessage"), dlqClient.count("ChangeMessageVisibilityBatchEntry")
+	moved, err = NewRedrive(dlq, source).Max(3).Rate(100).Run(c)
+	if err != nil || moved != 3 || dlqClient.size() != 10 {
+		t.Fatalf("max messages must be moved, %d, %v", moved, err)
+	}
+	if n := dlqClient.count("ReceiveMessage") - receives; n != 1 {
+		t.Fatalf("redrive must stop browsing at max, receive calls : %d", n)
+	}
+	if n := dlqClient.count("ChangeMessageVisibilityBatchEntry") - changes; n != 7 {
+		t.Fatalf("only not moved messages must be released, change visibility calls : %d", n)
+	}
+
+	moved, err = NewRedrive(dlq, source).Rate(100).Run(c)
+	if err != nil || moved != 10 || dlqClient.size() != 0 || sourceClient.size() != 15 {
+		t.Fatalf("all messages must be moved, %d, %v", moved, err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_BrowseFifo 는 fifo dlq 를 조회 할 때 page 마다 돌려 놓고, 돌려 놓아서 다시 받은 메시지는 한번만 내보내는지 검사
+func Test_BrowseFifo(t *testing.T) {
+	c := context.TODO()
+	client := newMemoryQueue()
+	client.fifo = true
+	for i := 0; i < 6; i++ {
+		client.pushGroup(fmt.Sprintf("message-%d", i), fmt.Sprintf("group-%d", i%2))
+	}
+	dlq := NewWithClient(client, DLQName(test_queue_fifo_name))
+
+	var buf bytes.Buffer
+	count, err := dlq.Export(c, &buf)
+	if err != nil || count != 6 {
+		t.Fatalf("all messages must be exported once, %d, %v", count, err)
+	}
+	ids := map[string]bool{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var m ExportMessage
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatal(err)
+		}
+		if ids[m.MessageId] {
+			t.Fatalf("message must not be exported twice, %s", m.MessageId)
+		}
+		ids[m.MessageId] = true
+	}
+
+	// 돌려 놓는 건 batch 로만 하고, 끝나면 다시 전부 받을 수 있어야 함
+	if client.count("ChangeMessageVisibility") != 0 || client.count("ChangeMessageVisibilityBatch") == 0 {
+		t.Fatalf("messages must be released with batch, %d", client.count("ChangeMessageVisibility"))
+	}
+	messages, err := dlq.Peek(c, 0)
+	if err != nil || len(messages) != 6 {
+		t.Fatalf("released messages must be visible again, %d, %v", len(messages), err)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
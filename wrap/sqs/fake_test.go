@@ -0,0 +1,288 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// memoryQueue 는 테스트에서 사용할 메모리 queue, queue 하나만 다룸
+type memoryQueue struct {
+	Client
+
+	mu       sync.Mutex
+	seq      int
+	messages []*memoryMessage
+	calls    map[string]int
+
+	// true 면 fifo 처럼 안 보이는 메시지가 있는 group 의 메시지는 주지 않음
+	fifo bool
+
+	// SendMessage 로 받은 요청들
+	sent []*sqs.SendMessageInput
+	// SendMessageBatch 로 받은 entry 들
+	sentEntries []types.SendMessageBatchRequestEntry
+
+	// CreateQueue 로 만든 queue 들
+	created []*sqs.CreateQueueInput
+
+	// SendMessageBatch 에서 entry Id 별로 일시적으로 실패 시킬 횟수, 음수면 계속 실패
+	sendFaults map[string]int
+	// SendMessageBatch 에서 sender fault 로 거절할 entry Id
+	sendRejects map[string]bool
+}
+
+type memoryMessage struct {
+	message   types.Message
+	invisible time.Time
+	receipt   string
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{calls: map[string]int{}, sendFaults: map[string]int{}, sendRejects: map[string]bool{}}
+}
+
+func (f *memoryQueue) call(op string) {
+	f.calls[op]++
+}
+
+func (f *memoryQueue) count(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[op]
+}
+
+// size 는 아직 지워지지 않은 메시지 수
+func (f *memoryQueue) size() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.messages)
+}
+
+func (f *memoryQueue) push(body string, attributes map[string]types.MessageAttributeValue) string {
+	f.seq++
+	id := fmt.Sprintf("message-%d", f.seq)
+	f.messages = append(f.messages, &memoryMessage{message: types.Message{
+		MessageId:         aws.String(id),
+		Body:              aws.String(body),
+		MessageAttributes: attributes,
+		Attributes:        map[string]string{},
+	}})
+	return id
+}
+
+// pushGroup 는 fifo group 을 지정해서 메시지를 넣음
+func (f *memoryQueue) pushGroup(body, group string) string {
+	id := f.push(body, nil)
+	f.messages[len(f.messages)-1].message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)] = group
+	return id
+}
+
+func (f *memoryQueue) GetQueueUrl(c context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.local/000000000000/" + *params.QueueName)}, nil
+}
+
+func (f *memoryQueue) CreateQueue(c context.Context, params *sqs.CreateQueueInput, optFns ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("CreateQueue")
+	f.created = append(f.created, params)
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String("https://sqs.local/000000000000/" + *params.QueueName)}, nil
+}
+
+func (f *memoryQueue) GetQueueAttributes(c context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	name := aws.ToString(params.QueueUrl)
+	name = name[strings.LastIndex(name, "/")+1:]
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{"QueueArn": "arn:aws:sqs:local:000000000000:" + name}}, nil
+}
+
+func (f *memoryQueue) SendMessage(c context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("SendMessage")
+	f.sent = append(f.sent, params)
+	id := f.push(aws.ToString(params.MessageBody), params.MessageAttributes)
+	return &sqs.SendMessageOutput{MessageId: aws.String(id)}, nil
+}
+
+func (f *memoryQueue) SendMessageBatch(c context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("SendMessageBatch")
+	if len(params.Entries) > max_count_batch_entry {
+		return nil, fmt.Errorf("too many entries in batch request, %d", len(params.Entries))
+	}
+	size := 0
+	for _, e := range params.Entries {
+		size += entrySize(e)
+	}
+	if size > max_batch_payload_size {
+		return nil, fmt.Errorf("batch request too long, %d", size)
+	}
+
+	r := &sqs.SendMessageBatchOutput{}
+	for _, e := range params.Entries {
+		f.sentEntries = append(f.sentEntries, e)
+		id := aws.ToString(e.Id)
+		if f.sendRejects[id] {
+			r.Failed = append(r.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InvalidParameterValue"), SenderFault: true})
+			continue
+		}
+		if n := f.sendFaults[id]; n != 0 {
+			f.sendFaults[id] = n - 1
+			r.Failed = append(r.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("InternalError")})
+			continue
+		}
+		r.Successful = append(r.Successful, types.SendMessageBatchResultEntry{Id: e.Id, MessageId: aws.String(f.push(aws.ToString(e.MessageBody), e.MessageAttributes))})
+	}
+	return r, nil
+}
+
+func (f *memoryQueue) ReceiveMessage(c context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	deadline := time.Now().Add(time.Duration(params.WaitTimeSeconds) * time.Second)
+	for {
+		if messages := f.receive(params); len(messages) > 0 || time.Now().After(deadline) {
+			return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+		}
+
+		// long polling 흉내
+		select {
+		case <-c.Done():
+			return nil, c.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (f *memoryQueue) receive(params *sqs.ReceiveMessageInput) []types.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("ReceiveMessage")
+
+	visibility := time.Duration(params.VisibilityTimeout) * time.Second
+	if visibility == 0 {
+		visibility = default_visibility_timeout
+	}
+
+	var messages []types.Message
+	now := time.Now()
+	locked := map[string]bool{}
+	if f.fifo {
+		for _, m := range f.messages {
+			if now.Before(m.invisible) {
+				locked[m.message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]] = true
+			}
+		}
+	}
+	for _, m := range f.messages {
+		if len(messages) == int(params.MaxNumberOfMessages) {
+			break
+		}
+		if now.Before(m.invisible) || locked[m.message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]] {
+			continue
+		}
+
+		f.seq++
+		m.receipt = fmt.Sprintf("receipt-%d", f.seq)
+		m.invisible = now.Add(visibility)
+		count, _ := strconv.Atoi(m.message.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+		m.message.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)] = strconv.Itoa(count + 1)
+
+		received := m.message
+		received.ReceiptHandle = aws.String(m.receipt)
+		messages = append(messages, received)
+	}
+
+	return messages
+}
+
+func (f *memoryQueue) find(receipt string) (int, *memoryMessage) {
+	for i, m := range f.messages {
+		if m.receipt == receipt {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+func (f *memoryQueue) DeleteMessage(c context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("DeleteMessage")
+	if i, _ := f.find(aws.ToString(params.ReceiptHandle)); i >= 0 {
+		f.messages = append(f.messages[:i], f.messages[i+1:]...)
+	}
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *memoryQueue) DeleteMessageBatch(c context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("DeleteMessageBatch")
+	if len(params.Entries) > max_count_batch_entry {
+		return nil, fmt.Errorf("too many entries in batch request, %d", len(params.Entries))
+	}
+
+	r := &sqs.DeleteMessageBatchOutput{}
+	for _, e := range params.Entries {
+		i, _ := f.find(aws.ToString(e.ReceiptHandle))
+		if i < 0 {
+			r.Failed = append(r.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("ReceiptHandleIsInvalid"), SenderFault: true})
+			continue
+		}
+		f.messages = append(f.messages[:i], f.messages[i+1:]...)
+		r.Successful = append(r.Successful, types.DeleteMessageBatchResultEntry{Id: e.Id})
+	}
+	return r, nil
+}
+
+func (f *memoryQueue) ChangeMessageVisibility(c context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("ChangeMessageVisibility")
+	_, m := f.find(aws.ToString(params.ReceiptHandle))
+	if m == nil {
+		return nil, fmt.Errorf("receipt handle is invalid")
+	}
+	m.invisible = time.Now().Add(time.Duration(params.VisibilityTimeout) * time.Second)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *memoryQueue) ChangeMessageVisibilityBatch(c context.Context, params *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("ChangeMessageVisibilityBatch")
+	if len(params.Entries) > max_count_batch_entry {
+		return nil, fmt.Errorf("too many entries in batch request, %d", len(params.Entries))
+	}
+
+	r := &sqs.ChangeMessageVisibilityBatchOutput{}
+	for _, e := range params.Entries {
+		f.call("ChangeMessageVisibilityBatchEntry")
+		_, m := f.find(aws.ToString(e.ReceiptHandle))
+		if m == nil {
+			r.Failed = append(r.Failed, types.BatchResultErrorEntry{Id: e.Id, Code: aws.String("ReceiptHandleIsInvalid"), SenderFault: true})
+			continue
+		}
+		m.invisible = time.Now().Add(time.Duration(e.VisibilityTimeout) * time.Second)
+		r.Successful = append(r.Successful, types.ChangeMessageVisibilityBatchResultEntry{Id: e.Id})
+	}
+	return r, nil
+}
@@ -0,0 +1,102 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/dalpengida/portfolio-go-aws/wrap/blob"
+	"github.com/rs/zerolog/log"
+)
+
+// RecordHandler 는 lambda 로 들어온 sqs record 하나를 처리하는 함수
+type RecordHandler func(c context.Context, record events.SQSMessage) error
+
+// BatchHandler 는 record 를 하나씩 따로 처리하고 실패한 record 만 BatchItemFailures 로 알려 주는 lambda handler 를 만듦
+// 하나가 실패해도 batch 전체가 다시 들어오지 않고 실패한 record 만 다시 들어옴
+// event source mapping 에 FunctionResponseTypes: ReportBatchItemFailures 가 설정 되어 있어야 함
+// fifo queue 는 순서를 지키기 위해서 처음 실패한 record 부터 나머지는 처리하지 않고 모두 실패로 돌려 줌
+//
+//	lambda.Start(sqs.BatchHandler(handler))
+func BatchHandler(handler RecordHandler) func(context.Context, events.SQSEvent) (events.SQSEventResponse, error) {
+	return func(c context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+		var response events.SQSEventResponse
+
+		for i, record := range event.Records {
+			if isFifoRecord(record) && len(response.BatchItemFailures) > 0 {
+				for _, rest := range event.Records[i:] {
+					response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: rest.MessageId})
+				}
+				break
+			}
+
+			if err := handleRecord(c, handler, record); err != nil {
+				log.Error().Err(err).Interface("message_id", record.MessageId).Msg("handle sqs record failed")
+				response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+			}
+		}
+
+		log.Debug().Interface("count", len(event.Records)).Interface("failed", len(response.BatchItemFailures)).Msg("handle sqs batch success")
+
+		return response, nil
+	}
+}
+
+// handleRecord 는 handler 에서 panic 이 나도 다른 record 는 처리 되도록 오류로 바꿔 줌
+func handleRecord(c context.Context, handler RecordHandler, record events.SQSMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sqs record handler panic, %v", r)
+		}
+	}()
+
+	return handler(c, record)
+}
+
+// isFifoRecord 는 fifo queue 에서 온 record 인지 확인
+func isFifoRecord(record events.SQSMessage) bool {
+	if _, ok := record.Attributes["MessageGroupId"]; ok {
+		return true
+	}
+	return strings.HasSuffix(record.EventSourceARN, fifo_queue_suffix)
+}
+
+// BlobHandler 는 body 가 blob pointer 인 record 를 store 에서 원래 body 로 바꿔서 handler 에 넘겨 줌
+// payload 는 지우지 않음, sns 로 여러 queue 에 같이 간 payload 일 수 있어서 bucket 의 lifecycle 로 만료 시켜야 함
+//
+//	lambda.Start(sqs.BatchHandler(sqs.BlobHandler(store, handler)))
+func BlobHandler(store blob.Store, handler RecordHandler) RecordHandler {
+	return blobHandler(store, handler, false)
+}
+
+// DeleteBlobHandler 는 BlobHandler 와 같고, handler 가 성공하면 record 는 lambda 가 지우기 때문에 payload 도 같이 지움
+// payload 를 이 queue 에서만 받는 경우(queue 로 바로 보낸 메시지)에만 사용, sns 를 구독하는 queue 에서 쓰면 다른 구독자가 payload 를 못 읽음
+func DeleteBlobHandler(store blob.Store, handler RecordHandler) RecordHandler {
+	return blobHandler(store, handler, true)
+}
+
+func blobHandler(store blob.Store, handler RecordHandler, delete bool) RecordHandler {
+	return func(c context.Context, record events.SQSMessage) error {
+		body, pointer, err := blob.Resolve(c, store, record.Body)
+		if err != nil {
+			return err
+		}
+		if pointer == nil {
+			return handler(c, record)
+		}
+
+		record.Body = body
+		if err := handler(c, record); err != nil {
+			return err
+		}
+		if !delete {
+			return nil
+		}
+
+		if err := store.Delete(c, pointer.Key); err != nil {
+			log.Error().Err(err).Interface("key", pointer.Key).Msg("delete blob failed")
+		}
+		return nil
+	}
+}
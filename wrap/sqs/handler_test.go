@@ -0,0 +1,56 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/rs/zerolog/log"
+)
+
+// Test_BatchHandler 는 실패한 record 만 BatchItemFailures 로 돌려 주고, fifo 는 실패 뒤의 record 를 처리하지 않는지 검사
+func Test_BatchHandler(t *testing.T) {
+	var handled []string
+	handler := BatchHandler(func(c context.Context, record events.SQSMessage) error {
+		switch record.Body {
+		case "fail":
+			return fmt.Errorf("handle failed")
+		case "panic":
+			panic("handler panic")
+		}
+		handled = append(handled, record.MessageId)
+		return nil
+	})
+
+	records := func(arn string) []events.SQSMessage {
+		var r []events.SQSMessage
+		for i, body := range []string{"ok", "fail", "ok", "panic", "ok"} {
+			r = append(r, events.SQSMessage{MessageId: fmt.Sprintf("id-%d", i), Body: body, EventSourceARN: arn})
+		}
+		return r
+	}
+
+	r, err := handler(context.TODO(), events.SQSEvent{Records: records("arn:aws:sqs:ap-northeast-2:000000000000:" + test_queue_name)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.BatchItemFailures) != 2 || r.BatchItemFailures[0].ItemIdentifier != "id-1" || r.BatchItemFailures[1].ItemIdentifier != "id-3" {
+		t.Fatalf("only failed records must be reported, %v", r.BatchItemFailures)
+	}
+	if len(handled) != 3 {
+		t.Fatalf("other records must be handled, %v", handled)
+	}
+
+	handled = nil
+	r, err = handler(context.TODO(), events.SQSEvent{Records: records("arn:aws:sqs:ap-northeast-2:000000000000:" + test_queue_fifo_name)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.BatchItemFailures) != 4 || len(handled) != 1 {
+		t.Fatalf("fifo records after failure must not be handled, failures : %v, handled : %v", r.BatchItemFailures, handled)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
@@ -0,0 +1,171 @@
+package sqs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// DelaySeconds 최대 값, fifo queue 는 메시지 별 delay 를 지원하지 않음
+	max_delay = 15 * time.Minute
+
+	// message attribute 는 메시지 하나에 최대 10개
+	max_count_message_attribute = 10
+
+	attribute_type_string = "String"
+	attribute_type_number = "Number"
+	attribute_type_binary = "Binary"
+)
+
+// SendOption 는 Send 할 때 메시지에 붙일 값들을 지정
+//
+//	err := queue.Send(c, noti, sqs.WithGroupField("user_id"), sqs.WithStringAttribute("event_type", "MODIFY"))
+type SendOption func(*sendOptions)
+
+type sendOptions struct {
+	attributes   map[string]types.MessageAttributeValue
+	delay        time.Duration
+	groupId      string
+	groupField   string
+	dedupId      string
+	contentDedup bool
+}
+
+// WithAttribute 는 message attribute 를 하나 추가, 같은 이름이면 덮어 씀
+func WithAttribute(name string, value types.MessageAttributeValue) SendOption {
+	return func(o *sendOptions) {
+		if o.attributes == nil {
+			o.attributes = make(map[string]types.MessageAttributeValue)
+		}
+		o.attributes[name] = value
+	}
+}
+
+// WithStringAttribute 는 String 타입의 message attribute 를 추가
+func WithStringAttribute(name, value string) SendOption {
+	return WithAttribute(name, types.MessageAttributeValue{
+		DataType:    aws.String(attribute_type_string),
+		StringValue: aws.String(value),
+	})
+}
+
+// WithNumberAttribute 는 Number 타입의 message attribute 를 추가
+func WithNumberAttribute(name string, value int64) SendOption {
+	return WithAttribute(name, types.MessageAttributeValue{
+		DataType:    aws.String(attribute_type_number),
+		StringValue: aws.String(strconv.FormatInt(value, 10)),
+	})
+}
+
+// WithBinaryAttribute 는 Binary 타입의 message attribute 를 추가
+func WithBinaryAttribute(name string, value []byte) SendOption {
+	return WithAttribute(name, types.MessageAttributeValue{
+		DataType:    aws.String(attribute_type_binary),
+		BinaryValue: value,
+	})
+}
+
+// WithDelay 는 메시지가 queue 에 들어가고 d 만큼 지난 뒤에 보이게 함, 최대 15분
+// fifo queue 는 메시지 별로 지정할 수 없어서 오류
+func WithDelay(d time.Duration) SendOption {
+	return func(o *sendOptions) {
+		o.delay = d
+	}
+}
+
+// WithGroupId 는 fifo queue 의 MessageGroupId 를 지정, 같은 group 안에서만 순서가 지켜짐
+func WithGroupId(id string) SendOption {
+	return func(o *sendOptions) {
+		o.groupId = id
+	}
+}
+
+// WithGroupField 는 보내는 obj 를 json 으로 바꿨을 때 최상위 field 값을 MessageGroupId 로 사용
+// 예를 들어 "user_id" 를 주면 유저 별로 순서가 지켜짐, field 가 없거나 비어 있으면 오류
+func WithGroupField(field string) SendOption {
+	return func(o *sendOptions) {
+		o.groupField = field
+	}
+}
+
+// WithDeduplicationId 는 fifo queue 의 MessageDeduplicationId 를 지정, 5분 안에 같은 값으로 들어온 메시지는 버려짐
+func WithDeduplicationId(id string) SendOption {
+	return func(o *sendOptions) {
+		o.dedupId = id
+	}
+}
+
+// WithContentDeduplication 는 MessageDeduplicationId 를 안 보내고 body 의 sha-256 으로 중복을 거르게 함
+// queue 에 ContentBasedDeduplication 이 켜져 있어야 함
+func WithContentDeduplication() SendOption {
+	return func(o *sendOptions) {
+		o.contentDedup = true
+	}
+}
+
+// apply 는 옵션을 검사하고 fifo 여부에 맞게 group id, deduplication id 를 정해 줌
+// fifo queue 에서 group 을 지정하지 않으면 예전처럼 메시지 마다 새 group 을 사용해서 순서가 지켜지지 않음
+func (o *sendOptions) apply(body []byte, fifo bool) error {
+	if len(o.attributes) > max_count_message_attribute {
+		return fmt.Errorf("invalid message attributes, too many attributes %d", len(o.attributes))
+	}
+	if o.delay < 0 || o.delay > max_delay {
+		return fmt.Errorf("invalid delay, %v", o.delay)
+	}
+
+	if !fifo {
+		if o.groupId != "" || o.groupField != "" || o.dedupId != "" || o.contentDedup {
+			return fmt.Errorf("invalid send option, group and deduplication are only for fifo queue")
+		}
+		return nil
+	}
+
+	if o.delay > 0 {
+		return fmt.Errorf("invalid send option, fifo queue does not support per message delay")
+	}
+	if o.groupId == "" && o.groupField != "" {
+		id, err := groupIdFromField(body, o.groupField)
+		if err != nil {
+			return err
+		}
+		o.groupId = id
+	}
+	if o.groupId == "" {
+		o.groupId = uuid.NewString()
+	}
+	if o.dedupId == "" && !o.contentDedup {
+		o.dedupId = uuid.NewString()
+	}
+
+	return nil
+}
+
+// groupIdFromField 는 json body 의 최상위 field 값을 문자열로 전달
+func groupIdFromField(body []byte, field string) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", fmt.Errorf("group id from field failed, body is not json object, %w", err)
+	}
+
+	raw, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("group id from field failed, field %s not found", field)
+	}
+
+	var id string
+	if err := json.Unmarshal(raw, &id); err != nil {
+		// 문자열이 아니면 숫자 등 json 값을 그대로 사용
+		id = string(raw)
+	}
+	if id == "" || id == "null" {
+		return "", fmt.Errorf("group id from field failed, field %s is empty", field)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,192 @@
+package sqs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ReceiveMessage, DeleteMessageBatch, ChangeMessageVisibilityBatch 한번에 최대 10개 까지
+	max_count_batch_entry = 10
+
+	// long polling 최대 대기 시간
+	max_wait_time = 20 * time.Second
+
+	// visibility timeout 최대 값
+	max_visibility_timeout = 12 * time.Hour
+)
+
+// ensureUrl 는 queue url 이 없으면 조회해서 넣어 줌
+func (q *Queue) ensureUrl(c context.Context) error {
+	if q.queueUrl != nil {
+		return nil
+	}
+
+	_, err := q.getUrl(c)
+	return err
+}
+
+// Receive 는 queue 에서 최대 max 개의 메시지를 가져옴, wait 만큼 메시지가 들어오길 기다림(long polling)
+// 받은 메시지는 visibility timeout 동안 다른 곳에서 안 보이고, 그 안에 Delete 를 안하면 다시 들어옴
+func (q *Queue) Receive(c context.Context, max int, wait time.Duration) ([]types.Message, error) {
+	return q.receive(c, max, wait, 0)
+}
+
+// receive 는 visibilityTimeout 을 지정해서 메시지를 가져옴, 0 이면 queue 에 설정된 값을 사용
+func (q *Queue) receive(c context.Context, max int, wait, visibilityTimeout time.Duration) ([]types.Message, error) {
+	if max < 1 || max > max_count_batch_entry {
+		return nil, fmt.Errorf("invalid receive count, %d", max)
+	}
+	if wait < 0 || wait > max_wait_time {
+		return nil, fmt.Errorf("invalid wait time, %v", wait)
+	}
+	if err := q.ensureUrl(c); err != nil {
+		return nil, err
+	}
+
+	r, err := q.api().ReceiveMessage(c, &sqs.ReceiveMessageInput{
+		QueueUrl:              q.queueUrl,
+		MaxNumberOfMessages:   int32(max),
+		WaitTimeSeconds:       int32(wait / time.Second),
+		VisibilityTimeout:     int32(visibilityTimeout / time.Second),
+		AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+		MessageAttributeNames: []string{"All"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("receive message failed, queue : %s, %w", q.queueName, err)
+	}
+
+	return r.Messages, nil
+}
+
+// Delete 는 처리가 끝난 메시지를 queue 에서 지움
+func (q *Queue) Delete(c context.Context, receiptHandle string) error {
+	if err := q.ensureUrl(c); err != nil {
+		return err
+	}
+
+	_, err := q.api().DeleteMessage(c, &sqs.DeleteMessageInput{
+		QueueUrl:      q.queueUrl,
+		ReceiptHandle: aws.String(receiptHandle),
+	})
+	if err != nil {
+		return fmt.Errorf("delete message failed, queue : %s, %w", q.queueName, err)
+	}
+
+	return nil
+}
+
+// DeleteBatch 는 여러 메시지를 10개씩 나눠서 지움
+// 실패한 entry 가 있으면 *BatchError 로 전달, entry 의 Id 는 receiptHandles 의 index
+func (q *Queue) DeleteBatch(c context.Context, receiptHandles []string) error {
+	if len(receiptHandles) == 0 {
+		return nil
+	}
+	if err := q.ensureUrl(c); err != nil {
+		return err
+	}
+
+	batchErr := &BatchError{Op: "delete message batch"}
+	for start := 0; start < len(receiptHandles); start += max_count_batch_entry {
+		end := min(start+max_count_batch_entry, len(receiptHandles))
+
+		entries := make([]types.DeleteMessageBatchRequestEntry, 0, end-start)
+		for i := start; i < end; i++ {
+			entries = append(entries, types.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(strconv.Itoa(i)),
+				ReceiptHandle: aws.String(receiptHandles[i]),
+			})
+		}
+
+		r, err := q.api().DeleteMessageBatch(c, &sqs.DeleteMessageBatchInput{
+			QueueUrl: q.queueUrl,
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf("delete message batch failed, queue : %s, %w", q.queueName, err)
+		}
+
+		batchErr.Failed = append(batchErr.Failed, batchFailures(r.Failed)...)
+	}
+
+	if len(batchErr.Failed) > 0 {
+		return batchErr
+	}
+
+	log.Debug().Interface("count", len(receiptHandles)).Msg("delete message batch success")
+
+	return nil
+}
+
+// ChangeVisibility 는 메시지가 다시 보이게 될 때까지의 시간을 지금부터 timeout 으로 바꿈
+// 처리가 오래 걸릴 때 늘려 주거나, 0 으로 해서 바로 다시 받을 수 있게 할 때 사용
+func (q *Queue) ChangeVisibility(c context.Context, receiptHandle string, timeout time.Duration) error {
+	if timeout < 0 || timeout > max_visibility_timeout {
+		return fmt.Errorf("invalid visibility timeout, %v", timeout)
+	}
+	if err := q.ensureUrl(c); err != nil {
+		return err
+	}
+
+	_, err := q.api().ChangeMessageVisibility(c, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          q.queueUrl,
+		ReceiptHandle:     aws.String(receiptHandle),
+		VisibilityTimeout: int32(timeout / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("change message visibility failed, queue : %s, %w", q.queueName, err)
+	}
+
+	return nil
+}
+
+// ChangeVisibilityBatch 는 여러 메시지의 visibility timeout 을 10개씩 나눠서 바꿈
+// 실패한 entry 가 있으면 *BatchError 로 전달, entry 의 Id 는 receiptHandles 의 index
+func (q *Queue) ChangeVisibilityBatch(c context.Context, receiptHandles []string, timeout time.Duration) error {
+	if len(receiptHandles) == 0 {
+		return nil
+	}
+	if timeout < 0 || timeout > max_visibility_timeout {
+		return fmt.Errorf("invalid visibility timeout, %v", timeout)
+	}
+	if err := q.ensureUrl(c); err != nil {
+		return err
+	}
+
+	batchErr := &BatchError{Op: "change message visibility batch"}
+	for start := 0; start < len(receiptHandles); start += max_count_batch_entry {
+		end := min(start+max_count_batch_entry, len(receiptHandles))
+
+		entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, 0, end-start)
+		for i := start; i < end; i++ {
+			entries = append(entries, types.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     aws.String(receiptHandles[i]),
+				VisibilityTimeout: int32(timeout / time.Second),
+			})
+		}
+
+		r, err := q.api().ChangeMessageVisibilityBatch(c, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: q.queueUrl,
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf("change message visibility batch failed, queue : %s, %w", q.queueName, err)
+		}
+
+		batchErr.Failed = append(batchErr.Failed, batchFailures(r.Failed)...)
+	}
+
+	if len(batchErr.Failed) > 0 {
+		return batchErr
+	}
+
+	return nil
+}
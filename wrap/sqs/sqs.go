@@ -2,17 +2,19 @@ package sqs
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
-	"github.com/dalpengida/portfolio-go-aws/common"
-	"github.com/dalpengida/portfolio-go-aws/config"
 
-	"github.com/google/uuid"
+	"github.com/dalpengida/portfolio-go-aws/common"
+	"github.com/dalpengida/portfolio-go-aws/wrap/blob"
 	"github.com/rs/zerolog/log"
 )
 
@@ -21,17 +23,15 @@ const (
 	fifo_queue_suffix = ".fifo"
 )
 
-var (
-	client *sqs.Client
-)
-
 type Queue struct {
 	queueName string
 	queueUrl  *string
-}
 
-func init() {
-	client = sqs.NewFromConfig(config.GetAws())
+	// client 는 nil 이면 default client 를 사용
+	client Client
+
+	// blobs 는 256KB 가 넘는 body 를 대신 저장할 곳, nil 이면 큰 메시지는 보낼 수 없음
+	blobs blob.Store
 }
 
 func New(queueName string) Queue {
@@ -40,6 +40,27 @@ func New(queueName string) Queue {
 	}
 }
 
+// NewWithClient 는 외부에서 만든 client 를 사용하는 Queue 를 생성
+// local 에 띄운 sqs 호환 서버나 테스트용 fake 를 넣을 때 사용
+func NewWithClient(client Client, queueName string) Queue {
+	return Queue{
+		queueName: queueName,
+		client:    client,
+	}
+}
+
+// NewFromConfig 는 aws config 로 client 를 만들어서 Queue 를 생성
+func NewFromConfig(cfg aws.Config, queueName string) Queue {
+	return NewWithClient(sqs.NewFromConfig(cfg), queueName)
+}
+
+// WithBlobStore 는 256KB 가 넘는 메시지의 body 를 store 에 저장하고 pointer 만 보내는 Queue 를 전달
+// 받는 쪽도 같은 store 를 지정해야 Consumer 가 원래 body 를 가져옴, payload 는 Consumer.DeleteBlobs 를 켰을 때만 지움
+func (q Queue) WithBlobStore(store blob.Store) Queue {
+	q.blobs = store
+	return q
+}
+
 // GetArn queue url 정보를 가지고 arn 정보를 다시 조회를 함, 평상시엔 쓸일 없지만, queue 를 sns 구독에 붙여 보기 위함
 // cloudformation으로 해야 하는 게 맞지만, sdk 에 기능이 있어서 한번 해봄
 func (q Queue) GetArn(c context.Context) (string, error) {
@@ -50,7 +71,7 @@ func (q Queue) GetArn(c context.Context) (string, error) {
 		}
 	}
 
-	r, err := client.GetQueueAttributes(c, &sqs.GetQueueAttributesInput{
+	r, err := q.api().GetQueueAttributes(c, &sqs.GetQueueAttributesInput{
 		QueueUrl: q.queueUrl,
 		AttributeNames: []types.QueueAttributeName{
 			types.QueueAttributeName(*aws.String("All")),
@@ -70,7 +91,7 @@ func (q Queue) Create(c context.Context, schema *sqs.CreateQueueInput) error {
 	if schema == nil {
 		schema = CREATE_SQS_SCHEMA
 	}
-	r, err := client.CreateQueue(c, schema)
+	r, err := q.api().CreateQueue(c, schema)
 	if err != nil {
 		return fmt.Errorf("create queue faild, %w", err)
 	}
@@ -83,7 +104,7 @@ func (q Queue) Create(c context.Context, schema *sqs.CreateQueueInput) error {
 // getUrl 는 지정한 큐의 url 정보를 조회하여 reciver 한테 저장을 해줌
 // 단순하게 조회만 하는 것이 아니라 저장도 해주기 때문에 차라리 setUrl 로 함수명 변경해야 하나 고민 됨
 func (q *Queue) getUrl(c context.Context) (string, error) {
-	r, err := client.GetQueueUrl(c, &sqs.GetQueueUrlInput{
+	r, err := q.api().GetQueueUrl(c, &sqs.GetQueueUrlInput{
 		QueueName: aws.String(q.queueName),
 	})
 	if err != nil {
@@ -100,9 +121,9 @@ func (q *Queue) getUrl(c context.Context) (string, error) {
 //
 // 내부를 확인을 해보면 전송 타입에 따른 정보가 있지만, 아직은 v2에서는 제대로 구현이 되어 있지 않은 것으로 보임
 // 그래서 data 구조체를 넘겨야 할 경우 json 으로 marshaling 해서 전달을 하고 받는 쪽에서 다시 unmarshaling 하는 것으로
-// // This member is required.
-// // DataType *string,BinaryListValues [][]byte,	BinaryValue []byte, StringListValues []string
-func (q *Queue) Send(c context.Context, obj interface{}) error {
+// message attribute, delay, fifo 의 group id, deduplication id 는 SendOption 으로 지정
+// fifo queue 에서 순서가 필요하면 WithGroupId 나 WithGroupField 로 group 을 꼭 지정 해야 함
+func (q *Queue) Send(c context.Context, obj interface{}, opts ...SendOption) error {
 	if obj == nil {
 		return fmt.Errorf("invalid obj or obj is nil")
 	}
@@ -119,82 +140,81 @@ func (q *Queue) Send(c context.Context, obj interface{}) error {
 		return fmt.Errorf("queue message json marshaling faeild, %w", err)
 	}
 
-	if strings.Contains(q.queueName, fifo_queue_suffix) {
-		return q.sendQueueFifo(c, string(json))
-	} else {
-		return q.sendQueue(c, string(json))
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.apply(json, q.isFifo()); err != nil {
+		return err
 	}
-}
 
-// sendQueue 일반 queue 에 메시지를 전송을 해줌
-func (q *Queue) sendQueue(c context.Context, message string) error {
-	r, err := client.SendMessage(c, &sqs.SendMessageInput{
-		QueueUrl:    q.queueUrl,
-		MessageBody: aws.String(message), // message body 값의 length 가 0 이어도 오류가 남
-		//  DelaySeconds: 0, // 0: 즉시 노출, 이외: 시간 만큼 있다가 노출
-		//MessageDeduplicationId: aws.String(""), // FIFO 타입에서는 필수, 중복 방지를 위하여 사용되는 값
-		// MessageGroupId:         aws.String(""), // FIFO 타입에서는 필수
-	})
+	body, err := q.offload(c, string(json), &o)
 	if err != nil {
-		return fmt.Errorf("queue send message faeild, %w", err)
+		return err
 	}
 
-	log.Debug().Interface("response", r).Msg("queue send success")
-
-	return nil
+	return q.send(c, body, o)
 }
 
-// sendQueueFifo fifo queue에 맞게 메시지를 전송을 해줌
-func (q *Queue) sendQueueFifo(c context.Context, message string) error {
-	messageId := uuid.NewString()
+// offload 는 메시지가 256KB 를 넘으면 body 를 blob store 에 저장하고 pointer 를 body 로 전달
+// pointer 는 blob key 가 매번 달라서 content based deduplication 이 안 되기 때문에
+// fifo queue 에서 deduplication id 를 안 정했으면 원래 body 의 sha-256 을 deduplication id 로 지정
+func (q *Queue) offload(c context.Context, body string, o *sendOptions) (string, error) {
+	size := messageSize(body, o.attributes)
+	if size <= max_batch_payload_size {
+		return body, nil
+	}
+	if q.blobs == nil {
+		return "", fmt.Errorf("message too large, size : %d, %w", size, common.ErrorRequestParameterExceed)
+	}
 
-	r, err := client.SendMessage(c, &sqs.SendMessageInput{
-		QueueUrl:    q.queueUrl,
-		MessageBody: aws.String(message), // message body 값의 length 가 0 이어도 오류가 남
-		//  DelaySeconds: 0, // 0: 즉시 노출, 이외: 시간 만큼 있다가 노출
-		MessageDeduplicationId: aws.String(messageId), // FIFO 타입에서는 필수, 중복 방지를 위하여 사용되는 값
-		MessageGroupId:         aws.String(messageId), // FIFO 타입에서는 필수
-	})
+	pointer, err := blob.Offload(c, q.blobs, []byte(body))
 	if err != nil {
-		return fmt.Errorf("fifo queue send message faeild, %w", err)
+		return "", err
+	}
+	WithNumberAttribute(blob.POINTER_ATTRIBUTE, int64(len(body)))(o)
+	if len(o.attributes) > max_count_message_attribute {
+		return "", fmt.Errorf("invalid message attributes, too many attributes %d", len(o.attributes))
+	}
+	if q.isFifo() && o.dedupId == "" {
+		o.dedupId = contentDeduplicationId(body)
 	}
 
-	log.Debug().Interface("response", r).Msg("fifo queue send success")
-
-	return nil
+	return pointer, nil
 }
 
-// BulkSend 한번에 여러 메시지를 전송을 요청을 하는 기능
-// 최대 10개 까지만 가능, 그래서 내부적으로 10개가 넘을 경우 10개만 보내고 나머지는 나중에 보내는 형식으로 함
-func (q *Queue) BulkSend(c context.Context, entries []types.SendMessageBatchRequestEntry) error {
-	const MAX_SQS_REQUEST_COUNT = 10
+// contentDeduplicationId 는 ContentBasedDeduplication 과 같이 body 의 sha-256 으로 deduplication id 를 만듦
+func contentDeduplicationId(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
 
-	for {
-		requestCount := MAX_SQS_REQUEST_COUNT
-		entryCount := len(entries)
-		if entryCount == 0 {
-			break
-		}
+// isFifo 는 이름을 보고 fifo queue 인지 확인
+func (q *Queue) isFifo() bool {
+	return strings.HasSuffix(q.queueName, fifo_queue_suffix)
+}
 
-		var requestEnties []types.SendMessageBatchRequestEntry
-		if entryCount < MAX_SQS_REQUEST_COUNT {
-			requestCount = entryCount
-		}
+// send 는 옵션을 붙여서 메시지를 전송을 해줌, fifo 가 아니면 group id, deduplication id 는 비어 있음
+func (q *Queue) send(c context.Context, message string, o sendOptions) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:          q.queueUrl,
+		MessageBody:       aws.String(message),          // message body 값의 length 가 0 이어도 오류가 남
+		DelaySeconds:      int32(o.delay / time.Second), // 0: 즉시 노출, 이외: 시간 만큼 있다가 노출
+		MessageAttributes: o.attributes,
+	}
+	if o.groupId != "" {
+		input.MessageGroupId = aws.String(o.groupId) // FIFO 타입에서는 필수
+	}
+	if o.dedupId != "" {
+		input.MessageDeduplicationId = aws.String(o.dedupId) // FIFO 타입에서 content based deduplication 을 안 쓰면 필수
+	}
 
-		//entries, requestEnties = common.SliceShift[types.SendMessageBatchRequestEntry](entries, requestCount)
-		entries, requestEnties = common.SliceShift[types.SendMessageBatchRequestEntry](entries, requestCount)
-		r, err := client.SendMessageBatch(c, &sqs.SendMessageBatchInput{
-			Entries:  requestEnties,
-			QueueUrl: q.queueUrl,
-		})
-		if err != nil {
-			fmt.Println(err)
-			//return err
-		}
-		if len(r.Failed) > 0 {
-			fmt.Printf("send sqs message batch failed, %v", r.Failed)
-		}
+	r, err := q.api().SendMessage(c, input)
+	if err != nil {
+		return fmt.Errorf("queue send message faeild, %w", err)
 	}
 
+	log.Debug().Interface("response", r).Msg("queue send success")
+
 	return nil
 }
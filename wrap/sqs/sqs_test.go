@@ -3,6 +3,10 @@ package sqs
 import (
 	"context"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 
 	"github.com/dalpengida/portfolio-go-aws/common"
 	"github.com/rs/zerolog/log"
@@ -77,3 +81,80 @@ func Test_GetAttribute(t *testing.T) {
 
 	log.Debug().Interface("arn", arn).Msgf(test_success_msg_format, common.FunctionName())
 }
+
+type fakeClient struct {
+	Client
+	sent []*sqs.SendMessageInput
+}
+
+func (f *fakeClient) GetQueueUrl(c context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) {
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.local/000000000000/" + *params.QueueName)}, nil
+}
+
+func (f *fakeClient) SendMessage(c context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.sent = append(f.sent, params)
+	return &sqs.SendMessageOutput{MessageId: aws.String("id")}, nil
+}
+
+// Test_SendWithClient 는 외부에서 넣어 준 client 로 메시지를 전송하는 기능 검사
+func Test_SendWithClient(t *testing.T) {
+	client := &fakeClient{}
+	queue := NewWithClient(client, test_queue_fifo_name)
+
+	err := queue.Send(context.TODO(), testItem{PK: "pk", SK: "sk", Val: "val"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.sent) != 1 || client.sent[0].MessageGroupId == nil {
+		t.Fatalf("fifo message must have group id, %v", client.sent)
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}
+
+// Test_SendWithOption 는 message attribute, delay, group id 를 옵션으로 지정해서 보내는 기능 검사
+func Test_SendWithOption(t *testing.T) {
+	client := &fakeClient{}
+	fifo := NewWithClient(client, test_queue_fifo_name)
+	item := testItem{PK: "user-1", SK: "sk", Val: "val"}
+
+	err := fifo.Send(context.TODO(), item, WithGroupField("pk"), WithStringAttribute("type", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fifo.Send(context.TODO(), item, WithGroupId("group"), WithContentDeduplication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(client.sent[0].MessageGroupId) != "user-1" || client.sent[0].MessageDeduplicationId == nil {
+		t.Fatalf("group id must be derived from field, %v", client.sent[0])
+	}
+	if aws.ToString(client.sent[0].MessageAttributes["type"].StringValue) != "test" {
+		t.Fatalf("message attribute must be sent, %v", client.sent[0].MessageAttributes)
+	}
+	if aws.ToString(client.sent[1].MessageGroupId) != "group" || client.sent[1].MessageDeduplicationId != nil {
+		t.Fatalf("content based deduplication must not send deduplication id, %v", client.sent[1])
+	}
+
+	// fifo 는 메시지 별 delay 가 안되고, 없는 field 로 group 을 만들 수 없음
+	if err := fifo.Send(context.TODO(), item, WithDelay(time.Second)); err == nil {
+		t.Fatal("fifo queue must reject delay")
+	}
+	if err := fifo.Send(context.TODO(), item, WithGroupField("user_id")); err == nil {
+		t.Fatal("missing group field must fail")
+	}
+
+	standard := NewWithClient(client, test_queue_name)
+	err = standard.Send(context.TODO(), item, WithDelay(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.sent[2].DelaySeconds != 10 || client.sent[2].MessageGroupId != nil {
+		t.Fatalf("standard queue message must have delay only, %v", client.sent[2])
+	}
+	if err := standard.Send(context.TODO(), item, WithGroupId("group")); err == nil {
+		t.Fatal("standard queue must reject group id")
+	}
+
+	log.Debug().Msgf(test_success_msg_format, common.FunctionName())
+}